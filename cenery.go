@@ -3,48 +3,205 @@ package cenery
 import (
 	"context"
 	"io"
+	"io/fs"
 	"mime/multipart"
+	"net/textproto"
 )
 
+// FileData is an uploaded file read into memory.
 type FileData struct {
-	FileData        []byte `json:"fileData"`
-	FileName        string `json:"fileName"`
-	FileSize        int64  `json:"fileSize"`
+	FileData []byte `json:"fileData"`
+	FileName string `json:"fileName"`
+	FileSize int64  `json:"fileSize"`
+	// FileContentType is the type declared by the client, or
+	// "application/octet-stream".
 	FileContentType string `json:"fileContentType"`
+	// DetectedContentType is sniffed from the content.
+	DetectedContentType string `json:"detectedContentType"`
+	// Header holds the headers of the file's multipart part.
+	Header textproto.MIMEHeader `json:"-"`
 }
 
+// FileStream is an uploaded file opened for reading. Close it once done.
 type FileStream struct {
-	File            multipart.File
-	FileName        string
-	FileSize        int64
+	File     multipart.File
+	FileName string
+	FileSize int64
+	// FileContentType is the type declared by the client, or
+	// "application/octet-stream".
 	FileContentType string
+	// DetectedContentType is sniffed from the first 512 bytes of the file.
+	DetectedContentType string
+	// Header holds the headers of the file's multipart part.
+	Header textproto.MIMEHeader
 }
 
+// Close closes the file. It is safe to call on a nil FileStream.
+func (f *FileStream) Close() error {
+	if f == nil || f.File == nil {
+		return nil
+	}
+	return f.File.Close()
+}
+
+// MultipartForm is a parsed multipart/form-data body: its non-file fields
+// and its file headers, keyed by field name.
+type MultipartForm struct {
+	Value map[string][]string
+	File  map[string][]*multipart.FileHeader
+}
+
+// Ctx is the request context handed to a Handler. Engines take one Ctx from
+// a pool for a request's whole handler chain: the handlers run through Next
+// reuse it, with the caller's fields saved and restored once they return.
+// So a Ctx is only valid in the handler it was passed to, until that handler
+// returns; it must not be kept, or used from a goroutine or a body stream
+// writer that outlives the handler, since by then it serves another handler
+// or request. Ctxs made with an engine's NewServerCtx are not pooled.
 type Ctx interface {
 	Params(key string, defaultValue ...string) string
 	QueryParam(key string, defaultValue ...string) string
+	// IP returns the remote address of the connection. Proxy headers such as
+	// X-Forwarded-For are not consulted.
+	IP() string
+	// BodyParser decodes the request body into out with the codec
+	// registered for its Content-Type, JSON when there is none. Unknown
+	// types get a 415 *Error wrapping ErrUnsupportedMediaType.
 	BodyParser(out any) error
 	BodyParserStream(out any) error
+	// BodyParserNDJSON decodes a newline-delimited JSON body one line at a
+	// time into out, zeroed before each line, and calls fn after each. It
+	// stops at the first error of a line or of fn. A bad line is a 400
+	// whose BodyError field starts with the item index, such as "[3].name".
+	BodyParserNDJSON(out any, fn func() error) error
 	BodyStream() io.ReadCloser
+	// FormFile is FormFileE without the error: it returns nil on failure.
 	FormFile(fileKey string) *FileData
+	// FormFiles is FormFilesE without the error: it returns nil on failure.
 	FormFiles(fileKey string) *[]FileData
+	// FormFileE reads the first file uploaded as fileKey into memory. Its
+	// error wraps ErrNotMultipart for requests that are not
+	// multipart/form-data, ErrFileNotFound when there is no such file, and
+	// ErrTooManyFiles or ErrFileTooLarge when the form exceeds the
+	// request's Limits.
+	FormFileE(fileKey string) (*FileData, error)
+	// FormFilesE is FormFileE for every file uploaded as fileKey.
+	FormFilesE(fileKey string) ([]FileData, error)
 
+	// FormFileStream opens the first file uploaded as fileKey, with the
+	// errors of FormFileE. Close the stream once done.
 	FormFileStream(fileKey string) (*FileStream, error)
+	// FormFilesStream opens every file uploaded as fileKey. On failure no
+	// file is left open.
 	FormFilesStream(fileKey string) ([]*FileStream, error)
 
+	// FormValue returns the first value of the field key of a
+	// multipart/form-data or application/x-www-form-urlencoded body, or
+	// defaultValue when it is empty. Query parameters are not consulted.
+	FormValue(key string, defaultValue ...string) string
+	// FormValues returns every value of the body field key.
+	FormValues(key string) []string
+	// MultipartForm parses a multipart/form-data body within the request's
+	// Limits.
+	MultipartForm() (*MultipartForm, error)
+	// MultipartReader reads a multipart/form-data body part by part as it
+	// arrives, without buffering files in memory or temporary files. Its
+	// error wraps ErrNotMultipart for other requests. On fasthttp and fiber the
+	// body only streams with WithStreamRequestBody.
+	MultipartReader() (*MultipartReader, error)
+
 	SendString(status int, data string) error
 	Send(status int, data []byte) error
 	SendJSON(status int, data any) error
+	// Negotiate encodes data with the registered codec the Accept header
+	// prefers and sends it with status. It returns a 406 *Error wrapping
+	// ErrNotAcceptable when no codec is acceptable.
+	Negotiate(status int, data any) error
+	// SendJSONStream writes items, an iter.Seq[T] or a receive channel, as
+	// a JSON array or, with NDJSON, one JSON value per line, flushing after
+	// every item. Like SSE, on fasthttp and fiber the items are consumed
+	// after the handler chain has returned, and a client that disconnects
+	// is not reported as an error. Neither is an item that fails to
+	// marshal: the stream ends there and the error is logged.
+	SendJSONStream(status int, items any, format ...JSONStreamFormat) error
 
-	// Streaming response (no memory allocation)
+	// Streaming response (no memory allocation). The Content-Length is set
+	// when ReaderSize can tell the size; otherwise the response is chunked.
 	SendStream(status int, contentType string, reader io.Reader) error
+	// SendStreamSized is SendStream for a reader of size bytes. A negative
+	// size means unknown.
+	SendStreamSized(status int, contentType string, reader io.Reader, size int64) error
+
+	// SendFile responds with the file at path. The content type comes from
+	// the extension or the content; ETag and Last-Modified are set and
+	// conditional and Range requests are honoured.
+	SendFile(path string) error
+	// SendFileFS is SendFile for a file of fsys.
+	SendFileFS(fsys fs.FS, name string) error
+	// Download sends the file at path as an attachment named filename, or
+	// the base name of path when filename is empty.
+	Download(path, filename string) error
+	// SaveFile writes an uploaded file to path, creating missing
+	// directories. path only appears once the whole file is written; a
+	// failed copy leaves nothing behind. The copy stops when the request is
+	// done. The file is not closed.
+	SaveFile(file *FileStream, path string) error
+
+	// SSE responds with a Server-Sent Events stream written by fn. On chi,
+	// echo and gin it returns the error of fn once fn returns. On fasthttp
+	// and fiber fn runs after the handler chain has returned, so SSE
+	// returns nil and the error of fn only ends the stream. A client that
+	// disconnects is not reported as an error.
+	SSE(fn func(w EventWriter) error) error
+
+	// Redirect responds with a redirect to location. A zero status means
+	// 302 for GET and HEAD requests and 303 otherwise; use 307 or 308 to
+	// keep the method and body. Targets that fail the app's RedirectConfig
+	// are rejected with a 400 wrapping ErrUnsafeRedirect.
+	Redirect(status int, location string) error
+	// RedirectToRoute redirects to the URL of the route named name, with
+	// params filling its path parameters.
+	RedirectToRoute(name string, params map[string]string) error
+	// RedirectBack redirects to the Referer, or to fallback when there is
+	// none or it fails the app's RedirectConfig.
+	RedirectBack(fallback string) error
+
+	// Locals returns the request-scoped value stored under key, or stores
+	// value when one is given. Values are visible to later handlers.
+	Locals(key string, value ...any) any
+
+	// Context returns the request context.
+	Context() context.Context
+	// SetContext replaces the request context seen by this and later handlers.
+	SetContext(ctx context.Context)
 
 	Request() Request
 	Response() Response
+	// Next runs the next handler in the chain. It returns the error of a
+	// later handler after that error has been passed to the error handler,
+	// so returning it again does not produce a second response.
 	Next() error
 }
 
+// TimeoutNexter is implemented by engine contexts that can run the rest of
+// the chain under a deadline. If ctx is done before the chain returns,
+// NextTimeout returns ctx.Err() and writes the chain makes afterwards are
+// discarded; the error returned by the calling handler becomes the response.
+type TimeoutNexter interface {
+	NextTimeout(ctx context.Context) error
+}
+
 type Request interface {
+	Method() string
+	// Host returns the host the request was sent to, from the Host header
+	// or the request URI.
+	Host() string
+	// Scheme returns "https" for TLS connections and "http" otherwise.
+	// Proxy headers such as X-Forwarded-Proto are not consulted.
+	Scheme() string
+	// Path returns the unescaped path of the request URL.
+	Path() string
+
 	Body() []byte
 	SetBody(data []byte)
 
@@ -67,15 +224,25 @@ type Response interface {
 type Handler = func(Ctx) error
 
 type App interface {
-	Get(path string, handlers ...Handler)
-	Post(path string, handlers ...Handler)
-	Put(path string, handlers ...Handler)
-	Delete(path string, handlers ...Handler)
-	Head(path string, handlers ...Handler)
-	Options(path string, handlers ...Handler)
-	Connect(path string, handlers ...Handler)
-	Patch(path string, handlers ...Handler)
-	Trace(path string, handlers ...Handler)
+	// The route methods return the registered Route, which can be given a
+	// Name for Ctx.RedirectToRoute.
+	Get(path string, handlers ...Handler) *Route
+	Post(path string, handlers ...Handler) *Route
+	Put(path string, handlers ...Handler) *Route
+	Delete(path string, handlers ...Handler) *Route
+	Head(path string, handlers ...Handler) *Route
+	Options(path string, handlers ...Handler) *Route
+	Connect(path string, handlers ...Handler) *Route
+	Patch(path string, handlers ...Handler) *Route
+	Trace(path string, handlers ...Handler) *Route
+	// Static serves the files under root below prefix. Symlinks leading
+	// out of root are not followed.
+	Static(prefix, root string, config ...StaticConfig)
+	// StaticFS serves the files of fsys, such as an embed.FS, below prefix.
+	StaticFS(prefix string, fsys fs.FS, config ...StaticConfig)
+	// WebSocket upgrades GET requests to path and serves them with handler.
+	// A failed handshake is returned to the error handler as an *Error.
+	WebSocket(path string, handler WSHandler, config ...WSConfig)
 	Name() string
 	Use(handlers ...Handler)
 	Listen(addr string) error
@@ -0,0 +1,290 @@
+// Package cenerybench runs the same benchmark scenarios on every engine,
+// once through cenery and once with the framework's own API, to measure
+// what the abstraction costs. Each engine module runs the suite from its
+// tests:
+//
+//	func BenchmarkSuite(b *testing.B) { cenerybench.Run(b, engine) }
+//
+// The sub-benchmarks are named scenario=<name>/impl=<cenery|native>, so
+// benchstat can compare them:
+//
+//	go test -run '^$' -bench Suite -count 10 > chi.txt
+//	benchstat -col /impl chi.txt
+//
+// Requests are served in memory, without a network, so the results measure
+// the routing, context and encoding work only.
+package cenerybench
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/dreamph/cenery"
+)
+
+// User is the JSON payload of the json-encode and json-decode scenarios.
+type User struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Admin bool     `json:"admin"`
+	Tags  []string `json:"tags"`
+}
+
+// SampleUser is the User the JSON scenarios encode and decode.
+var SampleUser = User{
+	ID:    42,
+	Name:  "Ann Lee",
+	Email: "ann@example.com",
+	Tags:  []string{"billing", "support", "beta"},
+}
+
+// UploadSize is the size of the file the multipart scenario uploads.
+const UploadSize = 32 << 10
+
+// StreamData is the body the stream scenario responds with.
+var StreamData = bytes.Repeat([]byte("0123456789abcdef"), 4<<10)
+
+// Request is the request a scenario sends.
+type Request struct {
+	Method      string
+	Path        string
+	ContentType string
+	Body        []byte
+}
+
+// Serve serves req in memory and returns the response status and body.
+type Serve func(req Request) (status int, body []byte)
+
+// Scenario is one benchmark.
+type Scenario struct {
+	Name    string
+	Request Request
+	// Setup registers the scenario's routes on a cenery app. The native
+	// implementations register the same routes with the framework's API.
+	Setup func(app cenery.App)
+	// Middleware is the number of middleware the request passes through
+	// before the handler.
+	Middleware int
+	// Status and Body are the expected response. Body is compared with
+	// surrounding whitespace trimmed.
+	Status int
+	Body   string
+	// Bytes is reported with b.SetBytes when non-zero.
+	Bytes int64
+}
+
+// Engine is an engine under benchmark.
+type Engine struct {
+	// Cenery creates a cenery app, passes it to setup and returns a Serve
+	// for it.
+	Cenery func(setup func(app cenery.App)) Serve
+	// Native returns a Serve for s implemented with the framework directly,
+	// or nil when the engine has no native counterpart for s.
+	Native func(s Scenario) Serve
+}
+
+// Scenarios returns the scenarios of the suite.
+func Scenarios() []Scenario {
+	user, _ := json.Marshal(SampleUser)
+	upload, uploadType := multipartBody()
+	scenarios := []Scenario{
+		{
+			Name:    "static",
+			Request: Request{Method: http.MethodGet, Path: "/hello"},
+			Setup: func(app cenery.App) {
+				app.Get("/hello", func(c cenery.Ctx) error {
+					return c.SendString(http.StatusOK, "Hello, World!")
+				})
+			},
+			Status: http.StatusOK,
+			Body:   "Hello, World!",
+		},
+		{
+			Name:    "param",
+			Request: Request{Method: http.MethodGet, Path: "/users/123/posts/456"},
+			Setup: func(app cenery.App) {
+				app.Get("/users/:id/posts/:post", func(c cenery.Ctx) error {
+					return c.SendString(http.StatusOK, c.Params("id")+"/"+c.Params("post"))
+				})
+			},
+			Status: http.StatusOK,
+			Body:   "123/456",
+		},
+		{
+			Name:    "json-encode",
+			Request: Request{Method: http.MethodGet, Path: "/user"},
+			Setup: func(app cenery.App) {
+				app.Get("/user", func(c cenery.Ctx) error {
+					return c.SendJSON(http.StatusOK, SampleUser)
+				})
+			},
+			Status: http.StatusOK,
+			Body:   string(user),
+		},
+		{
+			Name:    "json-decode",
+			Request: Request{Method: http.MethodPost, Path: "/user", ContentType: "application/json", Body: user},
+			Setup: func(app cenery.App) {
+				app.Post("/user", func(c cenery.Ctx) error {
+					var u User
+					if err := c.BodyParser(&u); err != nil {
+						return err
+					}
+					return c.SendString(http.StatusOK, u.Name)
+				})
+			},
+			Status: http.StatusOK,
+			Body:   SampleUser.Name,
+			Bytes:  int64(len(user)),
+		},
+		{
+			Name:    "multipart",
+			Request: Request{Method: http.MethodPost, Path: "/upload", ContentType: uploadType, Body: upload},
+			Setup: func(app cenery.App) {
+				app.Post("/upload", func(c cenery.Ctx) error {
+					file, err := c.FormFileE("file")
+					if err != nil {
+						return err
+					}
+					return c.SendString(http.StatusOK, strconv.Itoa(len(file.FileData)))
+				})
+			},
+			Status: http.StatusOK,
+			Body:   strconv.Itoa(UploadSize),
+			Bytes:  int64(len(upload)),
+		},
+		{
+			Name:    "stream",
+			Request: Request{Method: http.MethodGet, Path: "/stream"},
+			Setup: func(app cenery.App) {
+				app.Get("/stream", func(c cenery.Ctx) error {
+					return c.SendStream(http.StatusOK, "application/octet-stream", bytes.NewReader(StreamData))
+				})
+			},
+			Status: http.StatusOK,
+			Body:   string(StreamData),
+			Bytes:  int64(len(StreamData)),
+		},
+	}
+	for _, depth := range []int{1, 5, 10} {
+		scenarios = append(scenarios, Scenario{
+			Name:    "middleware-" + strconv.Itoa(depth),
+			Request: Request{Method: http.MethodGet, Path: "/chain"},
+			Setup: func(app cenery.App) {
+				for range depth {
+					app.Use(func(c cenery.Ctx) error {
+						return c.Next()
+					})
+				}
+				app.Get("/chain", func(c cenery.Ctx) error {
+					return c.SendString(http.StatusOK, "ok")
+				})
+			},
+			Middleware: depth,
+			Status:     http.StatusOK,
+			Body:       "ok",
+		})
+	}
+	return scenarios
+}
+
+// UploadData returns the content of the file the multipart scenario
+// uploads.
+func UploadData() []byte {
+	return bytes.Repeat([]byte{'x'}, UploadSize)
+}
+
+func multipartBody() ([]byte, string) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	part, _ := w.CreateFormFile("file", "upload.bin")
+	part.Write(UploadData())
+	w.Close()
+	return buf.Bytes(), w.FormDataContentType()
+}
+
+// Run benchmarks every scenario on e through cenery and, where e has one,
+// through the native implementation.
+func Run(b *testing.B, e Engine) {
+	for _, s := range Scenarios() {
+		b.Run("scenario="+s.Name, func(b *testing.B) {
+			bench(b, "cenery", s, e.Cenery(s.Setup))
+			if e.Native == nil {
+				return
+			}
+			if serve := e.Native(s); serve != nil {
+				bench(b, "native", s, serve)
+			}
+		})
+	}
+}
+
+func bench(b *testing.B, impl string, s Scenario, serve Serve) {
+	b.Run("impl="+impl, func(b *testing.B) {
+		if err := check(s, serve); err != nil {
+			b.Fatal(err)
+		}
+		if s.Bytes > 0 {
+			b.SetBytes(s.Bytes)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			serve(s.Request)
+		}
+	})
+}
+
+// Verify checks that every scenario of e, cenery and native, responds as
+// expected. Engines call it from a test so that go test catches a broken
+// scenario without running the benchmarks.
+func Verify(t *testing.T, e Engine) {
+	for _, s := range Scenarios() {
+		t.Run(s.Name, func(t *testing.T) {
+			if err := check(s, e.Cenery(s.Setup)); err != nil {
+				t.Errorf("cenery: %v", err)
+			}
+			if e.Native == nil {
+				return
+			}
+			if serve := e.Native(s); serve != nil {
+				if err := check(s, serve); err != nil {
+					t.Errorf("native: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func check(s Scenario, serve Serve) error {
+	status, body := serve(s.Request)
+	if got := strings.TrimSpace(string(body)); status != s.Status || got != s.Body {
+		if len(got) > 64 {
+			got = got[:64] + "..."
+		}
+		return fmt.Errorf("%v: status %v, body %q; want %v", s.Name, status, got, s.Status)
+	}
+	return nil
+}
+
+// ServeHandler returns a Serve for an http.Handler, such as the chi, echo
+// and gin apps.
+func ServeHandler(h http.Handler) Serve {
+	return func(r Request) (int, []byte) {
+		req := httptest.NewRequest(r.Method, r.Path, bytes.NewReader(r.Body))
+		if r.ContentType != "" {
+			req.Header.Set("Content-Type", r.ContentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code, rec.Body.Bytes()
+	}
+}
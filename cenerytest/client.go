@@ -0,0 +1,368 @@
+// Package cenerytest issues requests to a cenery.App in-process, without
+// listening on a port, and checks the responses:
+//
+//	client := cenerytest.NewClient(app)
+//	defer client.Close()
+//
+//	client.Post("/users").
+//		JSON(map[string]any{"name": "Ann"}).
+//		Header("Authorization", "Bearer token").
+//		Do(t).
+//		AssertStatus(http.StatusCreated).
+//		AssertJSON(map[string]any{"id": 1, "name": "Ann"})
+//
+// The chi, echo and gin apps are served through their http.Handler and a
+// ResponseRecorder; the fiber and fasthttp apps through an in-memory
+// listener, so requests go through the same routing, middleware and
+// handlers as in production on every engine.
+package cenerytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dreamph/cenery"
+)
+
+// BaseURL is the URL requests are sent to. Its host is what Request.Host
+// reports.
+const BaseURL = "http://example.com"
+
+// listenerServer is implemented by apps that do not serve net/http
+// requests, such as the fiber and fasthttp apps.
+type listenerServer interface {
+	Serve(ln net.Listener) error
+}
+
+// Client sends requests to an app. It keeps the cookies the app sets, like
+// a browser, and does not follow redirects.
+type Client struct {
+	http *http.Client
+	ln   *pipeListener
+}
+
+// NewClient returns a Client for app. It panics when app can be served
+// neither as an http.Handler nor from a net.Listener.
+func NewClient(app cenery.App) *Client {
+	jar, _ := cookiejar.New(nil)
+	c := &Client{http: &http.Client{
+		Jar: jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+	switch a := app.(type) {
+	case http.Handler:
+		c.http.Transport = handlerTransport{a}
+	case listenerServer:
+		c.ln = newPipeListener()
+		c.http.Transport = &http.Transport{DialContext: c.ln.Dial, DisableCompression: true}
+		go func() { _ = a.Serve(c.ln) }()
+	default:
+		panic("cenerytest: cannot serve " + app.Name() + " apps")
+	}
+	return c
+}
+
+// HTTPClient returns the underlying http.Client, for requests the builders
+// do not cover. URLs must start with BaseURL. The fiber and fasthttp apps
+// send it a streamed response, such as SSE or NDJSON, as it is written; the
+// chi, echo and gin apps only once the handler has returned, as they are
+// served into a ResponseRecorder, which cannot be hijacked for WebSocket
+// upgrades either.
+func (c *Client) HTTPClient() *http.Client {
+	return c.http
+}
+
+// Close closes the idle connections and stops serving the app.
+func (c *Client) Close() {
+	c.http.CloseIdleConnections()
+	if c.ln != nil {
+		c.ln.Close()
+	}
+}
+
+// handlerTransport serves requests with an http.Handler and a
+// ResponseRecorder.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := req.Body
+	if body == nil {
+		body = http.NoBody
+	}
+	r := httptest.NewRequestWithContext(req.Context(), req.Method, req.URL.String(), body)
+	r.Header = req.Header.Clone()
+	r.ContentLength = req.ContentLength
+	if host := req.Host; host != "" {
+		r.Host = host
+	}
+	rec := httptest.NewRecorder()
+	t.handler.ServeHTTP(rec, r)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func (c *Client) Get(path string) *Request     { return c.Request(http.MethodGet, path) }
+func (c *Client) Post(path string) *Request    { return c.Request(http.MethodPost, path) }
+func (c *Client) Put(path string) *Request     { return c.Request(http.MethodPut, path) }
+func (c *Client) Patch(path string) *Request   { return c.Request(http.MethodPatch, path) }
+func (c *Client) Delete(path string) *Request  { return c.Request(http.MethodDelete, path) }
+func (c *Client) Head(path string) *Request    { return c.Request(http.MethodHead, path) }
+func (c *Client) Options(path string) *Request { return c.Request(http.MethodOptions, path) }
+
+// Request starts a request for method and path, which may include a query.
+func (c *Client) Request(method, path string) *Request {
+	return &Request{client: c, method: method, path: path, header: http.Header{}, query: url.Values{}}
+}
+
+// Request builds a request. Its methods return the Request for chaining;
+// Do sends it.
+type Request struct {
+	client *Client
+	method string
+	path   string
+	header http.Header
+	query  url.Values
+	body   io.Reader
+	err    error
+
+	fields []formField
+	files  []File
+}
+
+// Header sets a request header.
+func (r *Request) Header(key, value string) *Request {
+	r.header.Set(key, value)
+	return r
+}
+
+// Query adds a query parameter.
+func (r *Request) Query(key, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// Cookie adds a cookie to the request.
+func (r *Request) Cookie(name, value string) *Request {
+	r.header.Add("Cookie", (&http.Cookie{Name: name, Value: value}).String())
+	return r
+}
+
+// Body sends body with the given content type.
+func (r *Request) Body(contentType string, body io.Reader) *Request {
+	r.body = body
+	if contentType != "" {
+		r.header.Set("Content-Type", contentType)
+	}
+	return r
+}
+
+// Text sends s as text/plain.
+func (r *Request) Text(s string) *Request {
+	return r.Body("text/plain; charset=utf-8", strings.NewReader(s))
+}
+
+// JSON sends v encoded as JSON.
+func (r *Request) JSON(v any) *Request {
+	data, err := json.Marshal(v)
+	if err != nil {
+		r.err = err
+	}
+	return r.Body("application/json", bytes.NewReader(data))
+}
+
+// Form sends values as an application/x-www-form-urlencoded body.
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body("application/x-www-form-urlencoded", strings.NewReader(values.Encode()))
+}
+
+// Field adds a field to a multipart/form-data body.
+func (r *Request) Field(name, value string) *Request {
+	r.fields = append(r.fields, formField{name, value})
+	return r
+}
+
+// File adds a file to a multipart/form-data body.
+func (r *Request) File(field, filename string, content []byte) *Request {
+	r.files = append(r.files, File{Field: field, Filename: filename, Content: content})
+	return r
+}
+
+func (r *Request) build() (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(r.fields) > 0 || len(r.files) > 0 {
+		body, contentType, err := writeMultipart(r.fields, r.files)
+		if err != nil {
+			return nil, err
+		}
+		r.Body(contentType, bytes.NewReader(body))
+	}
+
+	target := BaseURL + r.path
+	if len(r.query) > 0 {
+		sep := "?"
+		if strings.Contains(r.path, "?") {
+			sep = "&"
+		}
+		target += sep + r.query.Encode()
+	}
+	req, err := http.NewRequest(r.method, target, r.body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.header {
+		req.Header[k] = v
+	}
+	if host := r.header.Get("Host"); host != "" {
+		req.Host = host
+	}
+	return req, nil
+}
+
+// Do sends the request and reads the response. It stops the test when the
+// request cannot be built or sent.
+func (r *Request) Do(t testing.TB) *Response {
+	t.Helper()
+	req, err := r.build()
+	if err != nil {
+		t.Fatalf("cenerytest: %v %v: %v", r.method, r.path, err)
+	}
+	resp, err := r.client.http.Do(req)
+	if err != nil {
+		t.Fatalf("cenerytest: %v %v: %v", r.method, r.path, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("cenerytest: %v %v: reading body: %v", r.method, r.path, err)
+	}
+	return &Response{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+		t:          t,
+		name:       r.method + " " + r.path,
+	}
+}
+
+// Response is a received response. Its Assert methods report failures with
+// t.Errorf and return the Response for chaining.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+
+	t    testing.TB
+	name string
+}
+
+// String returns the body.
+func (r *Response) String() string {
+	return string(r.Body)
+}
+
+// DecodeJSON decodes the body into out. It stops the test when the body is
+// not valid JSON for out.
+func (r *Response) DecodeJSON(out any) {
+	r.t.Helper()
+	if err := json.Unmarshal(r.Body, out); err != nil {
+		r.t.Fatalf("%v: decoding %q: %v", r.name, r.Body, err)
+	}
+}
+
+// AssertStatus checks the status code.
+func (r *Response) AssertStatus(want int) *Response {
+	r.t.Helper()
+	if r.StatusCode != want {
+		r.t.Errorf("%v: status = %v, want %v; body %q", r.name, r.StatusCode, want, r.Body)
+	}
+	return r
+}
+
+// AssertHeader checks the first value of a header. An empty want checks
+// that the header is absent.
+func (r *Response) AssertHeader(key, want string) *Response {
+	r.t.Helper()
+	if got := r.Header.Get(key); got != want {
+		r.t.Errorf("%v: %v = %q, want %q", r.name, key, got, want)
+	}
+	return r
+}
+
+// AssertBody checks the whole body.
+func (r *Response) AssertBody(want string) *Response {
+	r.t.Helper()
+	if string(r.Body) != want {
+		r.t.Errorf("%v: body = %q, want %q", r.name, r.Body, want)
+	}
+	return r
+}
+
+// AssertBodyContains checks that the body contains sub.
+func (r *Response) AssertBodyContains(sub string) *Response {
+	r.t.Helper()
+	if !strings.Contains(string(r.Body), sub) {
+		r.t.Errorf("%v: body = %q, want it to contain %q", r.name, r.Body, sub)
+	}
+	return r
+}
+
+// AssertJSON checks that the body is the JSON encoding of want, ignoring
+// formatting and key order. A json.RawMessage or []byte want is compared
+// as JSON text.
+func (r *Response) AssertJSON(want any) *Response {
+	r.t.Helper()
+	var got, expected any
+	if err := json.Unmarshal(r.Body, &got); err != nil {
+		r.t.Errorf("%v: body %q is not JSON: %v", r.name, r.Body, err)
+		return r
+	}
+	if err := normalizeJSON(want, &expected); err != nil {
+		r.t.Errorf("%v: encoding the expected JSON: %v", r.name, err)
+		return r
+	}
+	if !reflect.DeepEqual(got, expected) {
+		r.t.Errorf("%v: body = %s, want %s", r.name, r.Body, jsonString(expected))
+	}
+	return r
+}
+
+func normalizeJSON(v any, out *any) error {
+	var data []byte
+	switch v := v.(type) {
+	case json.RawMessage:
+		data = v
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, out)
+}
+
+func jsonString(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
@@ -0,0 +1,161 @@
+package cenerytest
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dreamph/cenery"
+)
+
+// handlerApp stands in for the chi, echo and gin apps.
+type handlerApp struct {
+	cenery.App
+	http.Handler
+}
+
+// listenerApp stands in for the fiber and fasthttp apps.
+type listenerApp struct {
+	cenery.App
+	handler http.Handler
+}
+
+func (a listenerApp) Serve(ln net.Listener) error {
+	return (&http.Server{Handler: a.handler}).Serve(ln)
+}
+
+type unsupportedApp struct {
+	cenery.App
+}
+
+func (unsupportedApp) Name() string { return "Unsupported" }
+
+func testMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		w.Header().Set("X-Query", r.URL.RawQuery)
+		w.Header().Set("X-Host", r.Host)
+		w.Write(body)
+	})
+	mux.HandleFunc("POST /upload", func(w http.ResponseWriter, r *http.Request) {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, _ := io.ReadAll(file)
+		json.NewEncoder(w).Encode(map[string]string{"name": r.FormValue("name"), "file": header.Filename, "content": string(data)})
+	})
+	mux.HandleFunc("GET /login", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+		http.Redirect(w, r, "/me", http.StatusFound)
+	})
+	mux.HandleFunc("GET /me", func(w http.ResponseWriter, r *http.Request) {
+		var names []string
+		for _, c := range r.Cookies() {
+			names = append(names, c.Name+"="+c.Value)
+		}
+		io.WriteString(w, strings.Join(names, ";"))
+	})
+	return mux
+}
+
+func TestClient(t *testing.T) {
+	apps := map[string]cenery.App{
+		"handler":  handlerApp{Handler: testMux()},
+		"listener": listenerApp{handler: testMux()},
+	}
+	for name, app := range apps {
+		t.Run(name, func(t *testing.T) {
+			client := NewClient(app)
+			defer client.Close()
+
+			client.Post("/echo?a=1").
+				Query("b", "2").
+				JSON(map[string]any{"name": "Ann", "tags": []string{"x"}}).
+				Do(t).
+				AssertStatus(http.StatusOK).
+				AssertHeader("Content-Type", "application/json").
+				AssertHeader("X-Query", "a=1&b=2").
+				AssertHeader("X-Host", "example.com").
+				AssertJSON(json.RawMessage(`{"tags": ["x"], "name": "Ann"}`))
+
+			res := client.Post("/echo").Form(url.Values{"q": {"a b"}}).Do(t)
+			res.AssertBody("q=a+b").AssertHeader("Content-Type", "application/x-www-form-urlencoded")
+
+			var upload map[string]string
+			client.Post("/upload").
+				Field("name", "report").
+				File("file", "a.txt", []byte("hello")).
+				Do(t).
+				AssertStatus(http.StatusOK).
+				DecodeJSON(&upload)
+			if upload["name"] != "report" || upload["file"] != "a.txt" || upload["content"] != "hello" {
+				t.Errorf("upload = %v", upload)
+			}
+
+			client.Get("/login").Do(t).AssertStatus(http.StatusFound).AssertHeader("Location", "/me")
+			client.Get("/me").Cookie("theme", "dark").Do(t).AssertBody("theme=dark;session=s1")
+
+			client.Get("/missing").Do(t).AssertStatus(http.StatusNotFound).AssertBodyContains("not found")
+		})
+	}
+}
+
+// tbRecorder captures the failures reported through testing.TB.
+type tbRecorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *tbRecorder) Helper() {}
+
+func (r *tbRecorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, format)
+}
+
+func TestAssertions(t *testing.T) {
+	rec := &tbRecorder{TB: t}
+	res := &Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"X-Id": {"1"}},
+		Body:       []byte(`{"id":1,"tags":["a"]}`),
+		t:          rec,
+		name:       "GET /",
+	}
+	res.AssertStatus(http.StatusOK).
+		AssertHeader("X-Id", "1").
+		AssertHeader("X-Missing", "").
+		AssertBodyContains(`"id"`).
+		AssertJSON(map[string]any{"tags": []string{"a"}, "id": 1}).
+		AssertJSON([]byte(`{"id": 1, "tags": ["a"]}`))
+	if len(rec.errors) != 0 {
+		t.Errorf("passing assertions failed: %v", rec.errors)
+	}
+
+	res.AssertStatus(http.StatusCreated).
+		AssertHeader("X-Id", "2").
+		AssertBody("{}").
+		AssertBodyContains("name").
+		AssertJSON(map[string]any{"id": 2}).
+		AssertJSON(func() {})
+	if len(rec.errors) != 6 {
+		t.Errorf("failing assertions reported %v errors, want 6", len(rec.errors))
+	}
+}
+
+func TestUnsupportedApp(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewClient() of an unsupported app did not panic")
+		}
+	}()
+	NewClient(unsupportedApp{})
+}
@@ -0,0 +1,585 @@
+package cenerytest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dreamph/cenery"
+)
+
+// CtxOptions presets the request of a Ctx.
+type CtxOptions struct {
+	// Method defaults to GET.
+	Method string
+	// Path defaults to "/". It may include a query, to which Query is
+	// added.
+	Path   string
+	Query  url.Values
+	Params map[string]string
+	Header http.Header
+	// IP is what Ctx.IP returns. Defaults to "192.0.2.1".
+	IP string
+
+	// Body is the request body. JSON, Form and Files build one instead and
+	// set its Content-Type: JSON is encoded as JSON, Form alone as
+	// application/x-www-form-urlencoded, and Files, with Form as its
+	// fields, as multipart/form-data.
+	Body  io.Reader
+	JSON  any
+	Form  url.Values
+	Files []File
+
+	// Context is the request context. Defaults to context.Background.
+	Context context.Context
+	Locals  map[string]any
+	// Options configure the app the request is served by, such as its
+	// codecs, limits and redirect policy.
+	Options []cenery.Option
+
+	// Next is run by Ctx.Next, with the Ctx, to stand in for the rest of
+	// the chain. Next returns its error as is. When nil, Next returns nil.
+	Next cenery.Handler
+}
+
+// Ctx is a cenery.Ctx served in memory, for unit tests of handlers and
+// middleware. It records the response written through it:
+//
+//	c := cenerytest.NewCtx(cenerytest.CtxOptions{
+//		Method: http.MethodPost,
+//		Params: map[string]string{"id": "7"},
+//		JSON:   map[string]string{"name": "Ann"},
+//	})
+//	err := updateUser(c)
+//	// check err, c.Status() and c.ResponseBody()
+type Ctx struct {
+	r      *http.Request
+	rec    *recorder
+	resp   *response
+	params map[string]string
+	ip     string
+	locals map[string]any
+	config *cenery.Config
+	limits cenery.Limits
+	next   cenery.Handler
+
+	bodyLimited bool
+	nextCalls   int
+}
+
+// NewCtx returns a Ctx for options. It panics when the request body
+// cannot be built, such as for a JSON value that does not encode.
+func NewCtx(options ...CtxOptions) *Ctx {
+	var o CtxOptions
+	if len(options) > 0 {
+		o = options[0]
+	}
+	if o.Method == "" {
+		o.Method = http.MethodGet
+	}
+	if o.Path == "" {
+		o.Path = "/"
+	}
+	if o.IP == "" {
+		o.IP = "192.0.2.1"
+	}
+	if o.Context == nil {
+		o.Context = context.Background()
+	}
+
+	target := BaseURL + o.Path
+	if len(o.Query) > 0 {
+		sep := "?"
+		if strings.Contains(o.Path, "?") {
+			sep = "&"
+		}
+		target += sep + o.Query.Encode()
+	}
+	body, contentType, err := requestBody(o)
+	if err != nil {
+		panic("cenerytest: " + err.Error())
+	}
+	r := httptest.NewRequestWithContext(o.Context, o.Method, target, body)
+	for k, v := range o.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	r.RemoteAddr = o.IP + ":1234"
+
+	config := cenery.NewConfig(o.Options...)
+	rec := &recorder{ResponseRecorder: httptest.NewRecorder()}
+	locals := make(map[string]any, len(o.Locals))
+	for k, v := range o.Locals {
+		locals[k] = v
+	}
+	return &Ctx{
+		r:      r,
+		rec:    rec,
+		resp:   &response{rec: rec},
+		params: o.Params,
+		ip:     o.IP,
+		locals: locals,
+		config: config,
+		limits: config.Limits,
+		next:   o.Next,
+	}
+}
+
+func requestBody(o CtxOptions) (io.Reader, string, error) {
+	switch {
+	case o.JSON != nil:
+		data, err := json.Marshal(o.JSON)
+		return bytes.NewReader(data), "application/json", err
+	case len(o.Files) > 0:
+		keys := make([]string, 0, len(o.Form))
+		for k := range o.Form {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var fields []formField
+		for _, k := range keys {
+			for _, v := range o.Form[k] {
+				fields = append(fields, formField{k, v})
+			}
+		}
+		data, contentType, err := writeMultipart(fields, o.Files)
+		return bytes.NewReader(data), contentType, err
+	case o.Form != nil:
+		return strings.NewReader(o.Form.Encode()), "application/x-www-form-urlencoded", nil
+	}
+	return o.Body, "", nil
+}
+
+// Status returns the status written, or 0 when nothing was.
+func (c *Ctx) Status() int {
+	if !c.rec.written {
+		return 0
+	}
+	return c.rec.Code
+}
+
+// ResponseHeader returns the response headers.
+func (c *Ctx) ResponseHeader() http.Header {
+	return c.rec.Header()
+}
+
+// ResponseBody returns the response body written so far.
+func (c *Ctx) ResponseBody() []byte {
+	return c.rec.Body.Bytes()
+}
+
+// ResponseString returns the response body written so far as a string.
+func (c *Ctx) ResponseString() string {
+	return c.rec.Body.String()
+}
+
+// NextCalls returns how many times Next was called.
+func (c *Ctx) NextCalls() int {
+	return c.nextCalls
+}
+
+func (c *Ctx) Params(key string, defaultValue ...string) string {
+	val := c.params[key]
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (c *Ctx) QueryParam(key string, defaultValue ...string) string {
+	val := c.r.URL.Query().Get(key)
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (c *Ctx) IP() string {
+	return c.ip
+}
+
+func (c *Ctx) BodyParser(out any) error {
+	c.limitBody()
+	data, err := io.ReadAll(c.r.Body)
+	if err != nil {
+		return err
+	}
+	c.r.Body = io.NopCloser(bytes.NewReader(data))
+	return cenery.DecodeBody(c, c.config, data, out)
+}
+
+func (c *Ctx) BodyParserStream(out any) error {
+	c.limitBody()
+	return cenery.DecodeJSON(c, c.config, c.r.Body, out)
+}
+
+func (c *Ctx) BodyParserNDJSON(out any, fn func() error) error {
+	c.limitBody()
+	return cenery.DecodeNDJSON(c, c.config, c.r.Body, out, fn)
+}
+
+func (c *Ctx) BodyStream() io.ReadCloser {
+	c.limitBody()
+	return c.r.Body
+}
+
+func (c *Ctx) FormFile(fileKey string) *cenery.FileData {
+	file, _ := c.FormFileE(fileKey)
+	return file
+}
+
+func (c *Ctx) FormFiles(fileKey string) *[]cenery.FileData {
+	files, err := c.FormFilesE(fileKey)
+	if err != nil {
+		return nil
+	}
+	return &files
+}
+
+func (c *Ctx) FormFileE(fileKey string) (*cenery.FileData, error) {
+	headers, err := c.formFileHeaders(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cenery.ReadFormFile(headers[0])
+}
+
+func (c *Ctx) FormFilesE(fileKey string) ([]cenery.FileData, error) {
+	form, err := c.multipartForm()
+	if err != nil {
+		return nil, err
+	}
+	return cenery.FormFileData(form, fileKey)
+}
+
+func (c *Ctx) FormFileStream(fileKey string) (*cenery.FileStream, error) {
+	headers, err := c.formFileHeaders(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	return cenery.OpenFormFile(headers[0])
+}
+
+func (c *Ctx) FormFilesStream(fileKey string) ([]*cenery.FileStream, error) {
+	form, err := c.multipartForm()
+	if err != nil {
+		return nil, err
+	}
+	return cenery.FormFileStreams(form, fileKey)
+}
+
+func (c *Ctx) FormValue(key string, defaultValue ...string) string {
+	var val string
+	if values, _ := c.formValues(); len(values[key]) > 0 {
+		val = values[key][0]
+	}
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (c *Ctx) FormValues(key string) []string {
+	values, _ := c.formValues()
+	return values[key]
+}
+
+func (c *Ctx) MultipartForm() (*cenery.MultipartForm, error) {
+	form, err := c.multipartForm()
+	if err != nil {
+		return nil, err
+	}
+	return &cenery.MultipartForm{Value: form.Value, File: form.File}, nil
+}
+
+func (c *Ctx) MultipartReader() (*cenery.MultipartReader, error) {
+	c.limitBody()
+	mr, err := c.r.MultipartReader()
+	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, cenery.RequestError(cenery.ErrNotMultipart)
+		}
+		return nil, err
+	}
+	return cenery.NewMultipartReader(mr, c.limits), nil
+}
+
+func (c *Ctx) SendString(status int, data string) error {
+	c.rec.WriteHeader(status)
+	_, err := io.WriteString(c.rec, data)
+	return err
+}
+
+func (c *Ctx) Send(status int, data []byte) error {
+	c.rec.WriteHeader(status)
+	_, err := c.rec.Write(data)
+	return err
+}
+
+func (c *Ctx) SendJSON(status int, data any) error {
+	payload, err := c.config.JSONCodec().Marshal(data)
+	if err != nil {
+		return err
+	}
+	c.rec.Header().Set("Content-Type", "application/json")
+	c.rec.WriteHeader(status)
+	_, err = c.rec.Write(payload)
+	return err
+}
+
+func (c *Ctx) Negotiate(status int, data any) error {
+	return cenery.Negotiate(c, c.config, status, data)
+}
+
+func (c *Ctx) SendJSONStream(status int, items any, format ...cenery.JSONStreamFormat) error {
+	write, err := cenery.JSONStream(c, c.config, items, format...)
+	if err != nil {
+		return err
+	}
+	c.rec.WriteHeader(status)
+	return write(c.r.Context(), c.rec, c.flush)
+}
+
+func (c *Ctx) SendStream(status int, contentType string, reader io.Reader) error {
+	return c.SendStreamSized(status, contentType, reader, cenery.ReaderSize(reader))
+}
+
+func (c *Ctx) SendStreamSized(status int, contentType string, reader io.Reader, size int64) error {
+	c.rec.Header().Set("Content-Type", contentType)
+	if size >= 0 {
+		c.rec.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	c.rec.WriteHeader(status)
+	_, err := io.Copy(c.rec, reader)
+	if closer, ok := reader.(io.Closer); ok {
+		closer.Close()
+	}
+	return err
+}
+
+func (c *Ctx) SendFile(path string) error {
+	return cenery.SendFile(c, path)
+}
+
+func (c *Ctx) SendFileFS(fsys fs.FS, name string) error {
+	return cenery.SendFileFS(c, fsys, name)
+}
+
+func (c *Ctx) Download(path, filename string) error {
+	return cenery.Download(c, path, filename)
+}
+
+func (c *Ctx) SaveFile(file *cenery.FileStream, path string) error {
+	return cenery.SaveFile(c, file, path)
+}
+
+func (c *Ctx) SSE(fn func(w cenery.EventWriter) error) error {
+	cenery.SetEventStreamHeaders(c.resp)
+	c.rec.WriteHeader(http.StatusOK)
+	w, cancel := cenery.NewEventWriter(c.r.Context(), c.rec, c.flush, c.r.Header.Get("Last-Event-ID"))
+	defer cancel()
+	if err := fn(w); err != nil && w.Context().Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (c *Ctx) Redirect(status int, location string) error {
+	return cenery.Redirect(c, c.config, status, location)
+}
+
+func (c *Ctx) RedirectToRoute(name string, params map[string]string) error {
+	return cenery.RedirectToRoute(c, c.config, name, params)
+}
+
+func (c *Ctx) RedirectBack(fallback string) error {
+	return cenery.RedirectBack(c, c.config, fallback)
+}
+
+func (c *Ctx) Locals(key string, value ...any) any {
+	if len(value) > 0 {
+		c.locals[key] = value[0]
+		return value[0]
+	}
+	return c.locals[key]
+}
+
+func (c *Ctx) Context() context.Context {
+	return c.r.Context()
+}
+
+func (c *Ctx) SetContext(ctx context.Context) {
+	c.r = c.r.WithContext(ctx)
+}
+
+func (c *Ctx) SetLimits(l cenery.Limits) {
+	c.limits = c.limits.Merge(l)
+}
+
+func (c *Ctx) Request() cenery.Request {
+	return &request{r: c.r}
+}
+
+func (c *Ctx) Response() cenery.Response {
+	return c.resp
+}
+
+func (c *Ctx) Next() error {
+	c.nextCalls++
+	if c.next == nil {
+		return nil
+	}
+	return c.next(c)
+}
+
+func (c *Ctx) flush() error {
+	c.rec.Flush()
+	return nil
+}
+
+// limitBody caps the body at the current BodyLimit, once.
+func (c *Ctx) limitBody() {
+	if c.bodyLimited {
+		return
+	}
+	c.r.Body = c.limits.LimitBody(c.r.Body, c.r.ContentLength)
+	c.bodyLimited = true
+}
+
+func (c *Ctx) formFileHeaders(fileKey string) ([]*multipart.FileHeader, error) {
+	form, err := c.multipartForm()
+	if err != nil {
+		return nil, err
+	}
+	return cenery.FormFileHeaders(form, fileKey)
+}
+
+func (c *Ctx) multipartForm() (*multipart.Form, error) {
+	if c.r.MultipartForm == nil {
+		c.limitBody()
+		if err := c.r.ParseMultipartForm(c.limits.MultipartMemory); err != nil {
+			if errors.Is(err, cenery.ErrBodyTooLarge) {
+				return nil, cenery.RequestError(cenery.ErrBodyTooLarge)
+			}
+			if errors.Is(err, http.ErrNotMultipart) {
+				return nil, cenery.RequestError(cenery.ErrNotMultipart)
+			}
+			return nil, err
+		}
+	}
+	if err := c.limits.CheckForm(c.r.MultipartForm); err != nil {
+		return nil, err
+	}
+	return c.r.MultipartForm, nil
+}
+
+func (c *Ctx) formValues() (map[string][]string, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		form, err := c.multipartForm()
+		if err != nil {
+			return nil, err
+		}
+		return form.Value, nil
+	}
+	if c.r.PostForm == nil {
+		c.limitBody()
+		if err := c.r.ParseForm(); err != nil {
+			if errors.Is(err, cenery.ErrBodyTooLarge) {
+				return nil, cenery.RequestError(cenery.ErrBodyTooLarge)
+			}
+			return nil, err
+		}
+	}
+	return c.r.PostForm, nil
+}
+
+type request struct {
+	r *http.Request
+}
+
+func (h *request) Method() string { return h.r.Method }
+func (h *request) Host() string   { return h.r.Host }
+func (h *request) Path() string   { return h.r.URL.Path }
+
+func (h *request) Scheme() string {
+	if h.r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func (h *request) Body() []byte {
+	data, err := io.ReadAll(h.r.Body)
+	if err != nil {
+		data = nil
+	}
+	h.SetBody(data)
+	return data
+}
+
+func (h *request) SetBody(data []byte) {
+	h.r.Body = io.NopCloser(bytes.NewReader(data))
+	h.r.ContentLength = int64(len(data))
+}
+
+func (h *request) BodyStream() io.ReadCloser {
+	return h.r.Body
+}
+
+func (h *request) GetHeader(key string) string      { return h.r.Header.Get(key) }
+func (h *request) SetHeader(key string, val string) { h.r.Header.Set(key, val) }
+func (h *request) AddHeader(key string, val string) { h.r.Header.Add(key, val) }
+
+// recorder notes whether anything was written.
+type recorder struct {
+	*httptest.ResponseRecorder
+	written bool
+}
+
+func (w *recorder) WriteHeader(status int) {
+	w.written = true
+	w.ResponseRecorder.WriteHeader(status)
+}
+
+func (w *recorder) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseRecorder.Write(b)
+}
+
+func (w *recorder) WriteString(s string) (int, error) {
+	w.written = true
+	return w.ResponseRecorder.WriteString(s)
+}
+
+type response struct {
+	rec *recorder
+}
+
+func (h *response) Body() []byte                     { return h.rec.Body.Bytes() }
+func (h *response) SetBody(data []byte)              { _, _ = h.rec.Write(data) }
+func (h *response) GetHeader(key string) string      { return h.rec.Header().Get(key) }
+func (h *response) SetHeader(key string, val string) { h.rec.Header().Set(key, val) }
+func (h *response) AddHeader(key string, val string) { h.rec.Header().Add(key, val) }
+
+var _ cenery.Ctx = (*Ctx)(nil)
+var _ cenery.LimitSetter = (*Ctx)(nil)
@@ -0,0 +1,151 @@
+package cenerytest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dreamph/cenery"
+	"github.com/dreamph/cenery/middleware/ratelimit"
+)
+
+type ctxKey struct{}
+
+func TestCtxRequest(t *testing.T) {
+	c := NewCtx(CtxOptions{
+		Method:  http.MethodPut,
+		Path:    "/users/7?sort=name",
+		Query:   url.Values{"page": {"2"}},
+		Params:  map[string]string{"id": "7"},
+		Header:  http.Header{"Authorization": {"Bearer t"}},
+		IP:      "10.0.0.1",
+		Context: context.WithValue(context.Background(), ctxKey{}, "v"),
+		Locals:  map[string]any{"user": "ann"},
+	})
+
+	req := c.Request()
+	if req.Method() != http.MethodPut || req.Path() != "/users/7" || req.Host() != "example.com" || req.Scheme() != "http" {
+		t.Errorf("Method/Path/Host/Scheme = %v %v %v %v", req.Method(), req.Path(), req.Host(), req.Scheme())
+	}
+	if got := req.GetHeader("Authorization"); got != "Bearer t" {
+		t.Errorf("Authorization = %q", got)
+	}
+	if c.Params("id") != "7" || c.Params("missing", "x") != "x" {
+		t.Errorf("Params = %q %q", c.Params("id"), c.Params("missing", "x"))
+	}
+	if c.QueryParam("sort") != "name" || c.QueryParam("page") != "2" || c.QueryParam("missing", "1") != "1" {
+		t.Errorf("QueryParam = %q %q", c.QueryParam("sort"), c.QueryParam("page"))
+	}
+	if c.IP() != "10.0.0.1" {
+		t.Errorf("IP = %q", c.IP())
+	}
+	if c.Context().Value(ctxKey{}) != "v" || c.Locals("user") != "ann" {
+		t.Errorf("Context value = %v, Locals = %v", c.Context().Value(ctxKey{}), c.Locals("user"))
+	}
+	if NewCtx().IP() != "192.0.2.1" || NewCtx().Request().Method() != http.MethodGet {
+		t.Error("NewCtx() defaults not set")
+	}
+}
+
+func TestCtxBody(t *testing.T) {
+	var user struct {
+		Name string `json:"name"`
+	}
+	c := NewCtx(CtxOptions{Method: http.MethodPost, JSON: map[string]string{"name": "Ann"}})
+	if err := c.BodyParser(&user); err != nil || user.Name != "Ann" {
+		t.Errorf("BodyParser() = %+v, %v", user, err)
+	}
+	if got := string(c.Request().Body()); got != `{"name":"Ann"}` {
+		t.Errorf("Body() after BodyParser() = %q", got)
+	}
+
+	c = NewCtx(CtxOptions{Method: http.MethodPost, Form: url.Values{"name": {"Ann", "Bob"}}})
+	if c.FormValue("name") != "Ann" || len(c.FormValues("name")) != 2 || c.FormValue("missing", "x") != "x" {
+		t.Errorf("FormValue = %q, FormValues = %v", c.FormValue("name"), c.FormValues("name"))
+	}
+	if _, err := c.FormFileE("file"); !errors.Is(err, cenery.ErrNotMultipart) {
+		t.Errorf("FormFileE() of a urlencoded body error = %v", err)
+	}
+
+	c = NewCtx(CtxOptions{
+		Method: http.MethodPost,
+		Form:   url.Values{"title": {"report"}},
+		Files: []File{
+			{Field: "file", Filename: "a.txt", ContentType: "text/plain", Content: []byte("hello")},
+			{Field: "file", Filename: "b.txt", Content: []byte("world")},
+		},
+	})
+	file, err := c.FormFileE("file")
+	if err != nil || file.FileName != "a.txt" || string(file.FileData) != "hello" || file.FileContentType != "text/plain" {
+		t.Fatalf("FormFileE() = %+v, %v", file, err)
+	}
+	if files := c.FormFiles("file"); files == nil || len(*files) != 2 || c.FormValue("title") != "report" {
+		t.Errorf("FormFiles() = %v, FormValue = %q", files, c.FormValue("title"))
+	}
+	if _, err := c.FormFileE("missing"); !errors.Is(err, cenery.ErrFileNotFound) {
+		t.Errorf("FormFileE(missing) error = %v", err)
+	}
+
+	c = NewCtx(CtxOptions{Method: http.MethodPost, Body: strings.NewReader("too long"), Options: []cenery.Option{cenery.WithBodyLimit(4)}})
+	if _, err := io.ReadAll(c.BodyStream()); !errors.Is(err, cenery.ErrBodyTooLarge) {
+		t.Errorf("BodyStream() over the limit error = %v", err)
+	}
+}
+
+func TestCtxResponse(t *testing.T) {
+	c := NewCtx()
+	if c.Status() != 0 {
+		t.Errorf("Status() before writing = %v", c.Status())
+	}
+	c.Response().SetHeader("X-Id", "7")
+	if err := c.SendJSON(http.StatusCreated, map[string]int{"id": 7}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Status() != http.StatusCreated || c.ResponseString() != `{"id":7}` ||
+		c.ResponseHeader().Get("Content-Type") != "application/json" || c.ResponseHeader().Get("X-Id") != "7" {
+		t.Errorf("SendJSON() = %v %v %q", c.Status(), c.ResponseHeader(), c.ResponseBody())
+	}
+	if got := string(c.Response().Body()); got != `{"id":7}` {
+		t.Errorf("Response().Body() = %q", got)
+	}
+
+	c = NewCtx(CtxOptions{Method: http.MethodPost})
+	if err := c.Redirect(0, "/done"); err != nil || c.Status() != http.StatusSeeOther || c.ResponseHeader().Get("Location") != "/done" {
+		t.Errorf("Redirect() = %v %q, %v", c.Status(), c.ResponseHeader().Get("Location"), err)
+	}
+	if err := NewCtx().Redirect(0, "https://evil.example"); !errors.Is(err, cenery.ErrUnsafeRedirect) {
+		t.Errorf("Redirect() to another host error = %v", err)
+	}
+
+	c = NewCtx()
+	err := c.SSE(func(w cenery.EventWriter) error {
+		return w.Event("", "tick", "1")
+	})
+	if err != nil || c.ResponseHeader().Get("Content-Type") != "text/event-stream" || !strings.Contains(c.ResponseString(), "event: tick\ndata: 1\n\n") {
+		t.Errorf("SSE() = %q %q, %v", c.ResponseHeader().Get("Content-Type"), c.ResponseString(), err)
+	}
+}
+
+func TestCtxNext(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{Algorithm: ratelimit.SlidingWindow{Limit: 1, Window: time.Minute}})
+	errDownstream := errors.New("downstream")
+	next := func(c cenery.Ctx) error { return errDownstream }
+
+	c := NewCtx(CtxOptions{Next: next})
+	if err := limiter(c); !errors.Is(err, errDownstream) || c.NextCalls() != 1 {
+		t.Errorf("first request: error = %v, NextCalls() = %v", err, c.NextCalls())
+	}
+	if c.ResponseHeader().Get("RateLimit-Remaining") != "0" {
+		t.Errorf("RateLimit-Remaining = %q", c.ResponseHeader().Get("RateLimit-Remaining"))
+	}
+
+	c = NewCtx(CtxOptions{Next: next})
+	if err := limiter(c); err != nil || c.NextCalls() != 0 || c.Status() != http.StatusTooManyRequests {
+		t.Errorf("second request: error = %v, NextCalls() = %v, Status() = %v", err, c.NextCalls(), c.Status())
+	}
+}
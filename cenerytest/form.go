@@ -0,0 +1,59 @@
+package cenerytest
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+)
+
+// File is a file of a multipart/form-data body.
+type File struct {
+	Field    string
+	Filename string
+	// ContentType defaults to application/octet-stream.
+	ContentType string
+	Content     []byte
+}
+
+type formField struct {
+	name, value string
+}
+
+// writeMultipart encodes fields and files as a multipart/form-data body and
+// returns it with its content type.
+func writeMultipart(fields []formField, files []File) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, f := range fields {
+		if err := w.WriteField(f.name, f.value); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, f := range files {
+		contentType := f.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", `form-data; name="`+escapeQuotes(f.Field)+`"; filename="`+escapeQuotes(f.Filename)+`"`)
+		header.Set("Content-Type", contentType)
+		part, err := w.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(f.Content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), w.FormDataContentType(), nil
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
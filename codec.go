@@ -0,0 +1,255 @@
+package cenery
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrNotAcceptable is wrapped by the 406 error returned when no
+	// registered encoder matches the Accept header.
+	ErrNotAcceptable = errors.New("cenery: not acceptable")
+	// ErrUnsupportedMediaType is wrapped by the 415 error returned when no
+	// registered decoder matches the Content-Type of a request.
+	ErrUnsupportedMediaType = errors.New("cenery: unsupported media type")
+)
+
+// Codec encodes responses and decodes request bodies of one media type.
+type Codec struct {
+	// MediaType is the Content-Type of encoded responses, such as
+	// "application/json".
+	MediaType string
+	// Aliases are further media types the codec is chosen for, such as
+	// "text/xml" for XML.
+	Aliases []string
+	// Marshal encodes a response. A codec without one only decodes.
+	Marshal func(v any) ([]byte, error)
+	// Unmarshal decodes a request body. A codec without one only encodes.
+	Unmarshal func(data []byte, v any) error
+}
+
+// JSON, XML and Form are the codecs every Codecs starts with. Form only
+// decodes, application/x-www-form-urlencoded bodies.
+var (
+	JSON = Codec{MediaType: "application/json", Marshal: StdJSON.Marshal, Unmarshal: StdJSON.Unmarshal}
+	XML  = Codec{MediaType: "application/xml", Aliases: []string{"text/xml"}, Marshal: xml.Marshal, Unmarshal: xml.Unmarshal}
+	Form = Codec{MediaType: "application/x-www-form-urlencoded", Unmarshal: UnmarshalForm}
+)
+
+// Codecs is the registry of codecs used by Ctx.Negotiate and BodyParser.
+// Codecs are preferred in registration order when the client accepts
+// several equally.
+type Codecs struct {
+	mu     sync.RWMutex
+	codecs []Codec
+}
+
+// NewCodecs returns a registry of JSON, XML, Form and codecs.
+func NewCodecs(codecs ...Codec) *Codecs {
+	cs := &Codecs{}
+	cs.Register(JSON, XML, Form)
+	cs.Register(codecs...)
+	return cs
+}
+
+var defaultCodecs = NewCodecs()
+
+// Register adds codecs, replacing any registered for the same MediaType.
+func (cs *Codecs) Register(codecs ...Codec) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	for _, codec := range codecs {
+		codec.MediaType = strings.ToLower(codec.MediaType)
+		replaced := false
+		for i := range cs.codecs {
+			if cs.codecs[i].MediaType == codec.MediaType {
+				cs.codecs[i] = codec
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			cs.codecs = append(cs.codecs, codec)
+		}
+	}
+}
+
+// Lookup returns the decoding codec for contentType. A structured syntax
+// suffix such as "application/problem+json" falls back to
+// "application/json".
+func (cs *Codecs) Lookup(contentType string) (Codec, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return Codec{}, false
+	}
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	if codec, ok := cs.find(mediaType); ok {
+		return codec, true
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		return cs.find("application/" + mediaType[i+1:])
+	}
+	return Codec{}, false
+}
+
+func (cs *Codecs) find(mediaType string) (Codec, bool) {
+	for _, codec := range cs.codecs {
+		if codec.Unmarshal == nil {
+			continue
+		}
+		if codec.MediaType == mediaType {
+			return codec, true
+		}
+		for _, alias := range codec.Aliases {
+			if strings.EqualFold(alias, mediaType) {
+				return codec, true
+			}
+		}
+	}
+	return Codec{}, false
+}
+
+// Negotiate returns the encoding codec the Accept header prefers: the one
+// with the highest quality value, then the most specific matching range,
+// then the first registered. An empty or malformed Accept selects the
+// first codec.
+func (cs *Codecs) Negotiate(accept string) (Codec, bool) {
+	ranges := parseAccept(accept)
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	var best Codec
+	bestQ, bestSpec := 0.0, -1
+	for _, codec := range cs.codecs {
+		if codec.Marshal == nil {
+			continue
+		}
+		if len(ranges) == 0 {
+			return codec, true
+		}
+		q, spec := 0.0, -1
+		for _, mediaType := range append([]string{codec.MediaType}, codec.Aliases...) {
+			for _, r := range ranges {
+				if s := r.match(strings.ToLower(mediaType)); s > spec {
+					q, spec = r.q, s
+				}
+			}
+		}
+		if spec >= 0 && q > 0 && (q > bestQ || q == bestQ && spec > bestSpec) {
+			best, bestQ, bestSpec = codec, q, spec
+		}
+	}
+	return best, bestQ > 0
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// match returns how specifically r matches mediaType: 2 for an exact
+// match, 1 for "type/*", 0 for "*/*" and -1 for none.
+func (r acceptRange) match(mediaType string) int {
+	switch {
+	case r.mediaType == mediaType:
+		return 2
+	case r.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(mediaType, r.mediaType[:len(r.mediaType)-1]):
+		return 1
+	}
+	return -1
+}
+
+// parseAccept parses an Accept header, skipping malformed ranges.
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(v, 64)
+			if err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
+
+func configCodecs(cfg *Config) *Codecs {
+	if cfg == nil || cfg.Codecs == nil {
+		return defaultCodecs
+	}
+	return cfg.Codecs
+}
+
+// Negotiate implements Ctx.Negotiate for engines. cfg is the config of the
+// app serving c and may be nil.
+func Negotiate(c Ctx, cfg *Config, status int, v any) error {
+	c.Response().AddHeader("Vary", "Accept")
+	codec, ok := configCodecs(cfg).Negotiate(c.Request().GetHeader("Accept"))
+	if !ok {
+		return &Error{Code: http.StatusNotAcceptable, Message: http.StatusText(http.StatusNotAcceptable), Err: ErrNotAcceptable}
+	}
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.Response().SetHeader("Content-Type", codec.MediaType)
+	return c.Send(status, data)
+}
+
+// DecodeBody implements Ctx.BodyParser for engines: it decodes body into
+// out with the codec registered for the Content-Type of c, JSON when there
+// is none. JSON honours the route's JSONDecodeOptions. multipart/form-data
+// bodies are decoded like forms, ignoring files. An empty body leaves out
+// unchanged. Malformed bodies are 400 errors wrapping a *BodyError.
+func DecodeBody(c Ctx, cfg *Config, body []byte, out any) error {
+	contentType := c.Request().GetHeader("Content-Type")
+	if contentType == "" {
+		contentType = JSON.MediaType
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == "multipart/form-data" {
+		values, err := multipartValues(body, params["boundary"])
+		if err != nil {
+			return badBody("", err.Error(), err)
+		}
+		return decodeForm(values, out)
+	}
+	codec, ok := configCodecs(cfg).Lookup(contentType)
+	if !ok {
+		return &Error{Code: http.StatusUnsupportedMediaType, Message: http.StatusText(http.StatusUnsupportedMediaType), Err: ErrUnsupportedMediaType}
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	if codec.MediaType == JSON.MediaType {
+		if o := jsonDecodeOptions(c, cfg); o != (JSONDecodeOptions{}) {
+			return decodeJSON(cfg.JSONCodec(), o, bytes.NewReader(body), out)
+		}
+		if err := codec.Unmarshal(body, out); err != nil {
+			return jsonError(err)
+		}
+		return nil
+	}
+	if err := codec.Unmarshal(body, out); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return err
+		}
+		return badBody("", err.Error(), err)
+	}
+	return nil
+}
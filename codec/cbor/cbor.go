@@ -0,0 +1,40 @@
+// Package cbor provides a CBOR (RFC 8949) codec for cenery content
+// negotiation and body parsing.
+package cbor
+
+import (
+	"reflect"
+
+	"github.com/dreamph/cenery"
+	"github.com/ugorji/go/codec"
+)
+
+// MediaType is the Content-Type of CBOR responses.
+const MediaType = "application/cbor"
+
+var handle = &codec.CborHandle{}
+
+func init() {
+	handle.RawToString = true
+	handle.MapType = reflect.TypeFor[map[string]any]()
+}
+
+// Codec registers CBOR with cenery.WithCodec.
+var Codec = cenery.Codec{
+	MediaType: MediaType,
+	Marshal:   Marshal,
+	Unmarshal: Unmarshal,
+}
+
+// Marshal encodes v as CBOR. Struct fields use their codec tag, or their
+// json tag.
+func Marshal(v any) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, handle).Encode(v)
+	return data, err
+}
+
+// Unmarshal decodes CBOR data into v.
+func Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, handle).Decode(v)
+}
@@ -0,0 +1,12 @@
+module github.com/dreamph/cenery/codec
+
+go 1.24.0
+
+replace github.com/dreamph/cenery => ../
+
+require (
+	github.com/dreamph/cenery v1.0.1
+	github.com/ugorji/go/codec v1.3.0
+	google.golang.org/protobuf v1.36.9
+	gopkg.in/yaml.v3 v3.0.1
+)
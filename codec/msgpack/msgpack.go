@@ -0,0 +1,41 @@
+// Package msgpack provides a MessagePack codec for cenery content
+// negotiation and body parsing.
+package msgpack
+
+import (
+	"reflect"
+
+	"github.com/dreamph/cenery"
+	"github.com/ugorji/go/codec"
+)
+
+// MediaType is the Content-Type of MessagePack responses.
+const MediaType = "application/vnd.msgpack"
+
+var handle = &codec.MsgpackHandle{WriteExt: true}
+
+func init() {
+	handle.RawToString = true
+	handle.MapType = reflect.TypeFor[map[string]any]()
+}
+
+// Codec registers MessagePack with cenery.WithCodec.
+var Codec = cenery.Codec{
+	MediaType: MediaType,
+	Aliases:   []string{"application/msgpack", "application/x-msgpack"},
+	Marshal:   Marshal,
+	Unmarshal: Unmarshal,
+}
+
+// Marshal encodes v as MessagePack. Struct fields use their codec tag, or
+// their json tag.
+func Marshal(v any) ([]byte, error) {
+	var data []byte
+	err := codec.NewEncoderBytes(&data, handle).Encode(v)
+	return data, err
+}
+
+// Unmarshal decodes MessagePack data into v.
+func Unmarshal(data []byte, v any) error {
+	return codec.NewDecoderBytes(data, handle).Decode(v)
+}
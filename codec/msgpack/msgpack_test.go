@@ -0,0 +1,35 @@
+package msgpack
+
+import (
+	"reflect"
+	"testing"
+)
+
+type item struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+	N    int      `json:"n"`
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := item{Name: "a", Tags: []string{"x", "y"}, N: 42}
+	data, err := Codec.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	var out item
+	if err := Codec.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+
+	var m map[string]any
+	if err := Codec.Unmarshal(data, &m); err != nil {
+		t.Fatalf("Unmarshal() into map error = %v", err)
+	}
+	if m["name"] != "a" {
+		t.Errorf("map[name] = %#v, want %q", m["name"], "a")
+	}
+}
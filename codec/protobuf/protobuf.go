@@ -0,0 +1,43 @@
+// Package protobuf provides a Protocol Buffers codec for cenery content
+// negotiation and body parsing.
+package protobuf
+
+import (
+	"errors"
+
+	"github.com/dreamph/cenery"
+	"google.golang.org/protobuf/proto"
+)
+
+// MediaType is the Content-Type of Protocol Buffers responses.
+const MediaType = "application/x-protobuf"
+
+// ErrNotMessage is returned for values that are not a proto.Message.
+var ErrNotMessage = errors.New("protobuf: value is not a proto.Message")
+
+// Codec registers Protocol Buffers with cenery.WithCodec.
+var Codec = cenery.Codec{
+	MediaType: MediaType,
+	Aliases:   []string{"application/protobuf", "application/vnd.google.protobuf"},
+	Marshal:   Marshal,
+	Unmarshal: Unmarshal,
+}
+
+// Marshal encodes v, which must be a proto.Message, in the binary wire
+// format.
+func Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, ErrNotMessage
+	}
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes data into v, which must be a proto.Message.
+func Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrNotMessage
+	}
+	return proto.Unmarshal(data, m)
+}
@@ -0,0 +1,23 @@
+package protobuf
+
+import (
+	"errors"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestRoundTrip(t *testing.T) {
+	data, err := Codec.Marshal(wrapperspb.String("hello"))
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	out := &wrapperspb.StringValue{}
+	if err := Codec.Unmarshal(data, out); err != nil || out.GetValue() != "hello" {
+		t.Errorf("Unmarshal() = %q, %v", out.GetValue(), err)
+	}
+
+	if _, err := Codec.Marshal(map[string]string{}); !errors.Is(err, ErrNotMessage) {
+		t.Errorf("Marshal(map) error = %v, want ErrNotMessage", err)
+	}
+}
@@ -0,0 +1,20 @@
+// Package yaml provides a YAML codec for cenery content negotiation and
+// body parsing.
+package yaml
+
+import (
+	"github.com/dreamph/cenery"
+	"gopkg.in/yaml.v3"
+)
+
+// MediaType is the Content-Type of YAML responses.
+const MediaType = "application/yaml"
+
+// Codec registers YAML with cenery.WithCodec. Struct fields use their yaml
+// tag.
+var Codec = cenery.Codec{
+	MediaType: MediaType,
+	Aliases:   []string{"application/x-yaml", "text/yaml"},
+	Marshal:   yaml.Marshal,
+	Unmarshal: yaml.Unmarshal,
+}
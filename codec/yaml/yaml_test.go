@@ -0,0 +1,21 @@
+package yaml
+
+import "testing"
+
+func TestRoundTrip(t *testing.T) {
+	type item struct {
+		Name string `yaml:"name"`
+		N    int    `yaml:"count"`
+	}
+	data, err := Codec.Marshal(item{Name: "a", N: 42})
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+	if string(data) != "name: a\ncount: 42\n" {
+		t.Errorf("Marshal() = %q", data)
+	}
+	var out item
+	if err := Codec.Unmarshal(data, &out); err != nil || out.Name != "a" || out.N != 42 {
+		t.Errorf("Unmarshal() = %+v, %v", out, err)
+	}
+}
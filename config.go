@@ -0,0 +1,110 @@
+package cenery
+
+// Config holds the engine-independent settings shared by every engine.
+type Config struct {
+	// ErrorHandler writes the response for errors returned by handlers.
+	// Defaults to DefaultErrorHandler.
+	ErrorHandler ErrorHandler
+
+	// Limits bounds request body sizes. Defaults to DefaultLimits().
+	Limits Limits
+
+	// Redirect validates the targets of Ctx.Redirect and friends.
+	Redirect RedirectConfig
+
+	// Routes records the named routes of the app.
+	Routes *Routes
+
+	// Codecs encode Ctx.Negotiate responses and decode BodyParser bodies.
+	// Defaults to NewCodecs().
+	Codecs *Codecs
+
+	// JSON is the JSON implementation of the app. Defaults to StdJSON.
+	JSON JSONCodec
+
+	// JSONDecode makes JSON request bodies stricter. RouteJSONOptions
+	// overrides it per route.
+	JSONDecode JSONDecodeOptions
+
+	// StreamRequestBody makes the fasthttp and fiber servers hand request
+	// bodies to handlers as they arrive instead of buffering them first, so
+	// Ctx.MultipartReader and BodyStream read straight off the connection.
+	// The net/http engines always stream.
+	StreamRequestBody bool
+
+	// NativeBodyParser makes Ctx.BodyParser on gin and echo use the
+	// framework's own binding, gin's ShouldBind and echo's Bind, which also
+	// validate binding tags or fill path and query values. By default they
+	// decode with Codecs and JSON, honouring JSONDecode, like the other
+	// engines.
+	NativeBodyParser bool
+}
+
+type Option func(*Config)
+
+// NewConfig applies opts over the default configuration. It does not check
+// JSONDecode against the JSON codec; see ErrDecodeOption.
+func NewConfig(opts ...Option) *Config {
+	cfg := &Config{
+		ErrorHandler: DefaultErrorHandler,
+		Limits:       DefaultLimits(),
+		Routes:       &Routes{},
+		Codecs:       NewCodecs(),
+		JSON:         StdJSON,
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithErrorHandler sets the handler that turns handler errors into responses.
+func WithErrorHandler(h ErrorHandler) Option {
+	return func(c *Config) {
+		if h != nil {
+			c.ErrorHandler = h
+		}
+	}
+}
+
+// WithLimits applies the set fields of l over the default limits.
+func WithLimits(l Limits) Option {
+	return func(c *Config) {
+		c.Limits = c.Limits.Merge(l)
+	}
+}
+
+// WithBodyLimit sets the maximum request body size in bytes. A negative
+// value disables the limit.
+func WithBodyLimit(n int64) Option {
+	return WithLimits(Limits{BodyLimit: n})
+}
+
+// WithRedirect sets the validation applied to redirect targets.
+func WithRedirect(rc RedirectConfig) Option {
+	return func(c *Config) {
+		c.Redirect = rc
+	}
+}
+
+// WithCodec registers codecs for content negotiation and body parsing,
+// replacing the built-in codec of the same media type.
+func WithCodec(codecs ...Codec) Option {
+	return func(c *Config) {
+		c.Codecs.Register(codecs...)
+	}
+}
+
+// WithStreamRequestBody enables StreamRequestBody.
+func WithStreamRequestBody() Option {
+	return func(c *Config) {
+		c.StreamRequestBody = true
+	}
+}
+
+// WithNativeBodyParser enables NativeBodyParser.
+func WithNativeBodyParser() Option {
+	return func(c *Config) {
+		c.NativeBodyParser = true
+	}
+}
@@ -0,0 +1,94 @@
+package chi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/dreamph/cenery"
+	"github.com/dreamph/cenery/cenerybench"
+	"github.com/go-chi/chi/v5"
+)
+
+var benchEngine = cenerybench.Engine{
+	Cenery: func(setup func(app cenery.App)) cenerybench.Serve {
+		app := New(chi.NewRouter())
+		setup(app)
+		return cenerybench.ServeHandler(app.(http.Handler))
+	},
+	Native: nativeScenario,
+}
+
+// nativeScenario implements s with chi and net/http directly.
+func nativeScenario(s cenerybench.Scenario) cenerybench.Serve {
+	r := chi.NewRouter()
+	for range s.Middleware {
+		r.Use(func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				next.ServeHTTP(w, r)
+			})
+		})
+	}
+	switch s.Name {
+	case "static":
+		r.Get("/hello", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			io.WriteString(w, "Hello, World!")
+		})
+	case "param":
+		r.Get("/users/{id}/posts/{post}", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			io.WriteString(w, chi.URLParam(r, "id")+"/"+chi.URLParam(r, "post"))
+		})
+	case "json-encode":
+		r.Get("/user", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(cenerybench.SampleUser)
+		})
+	case "json-decode":
+		r.Post("/user", func(w http.ResponseWriter, r *http.Request) {
+			var u cenerybench.User
+			if err := json.NewDecoder(r.Body).Decode(&u); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			io.WriteString(w, u.Name)
+		})
+	case "multipart":
+		r.Post("/upload", func(w http.ResponseWriter, r *http.Request) {
+			file, _, err := r.FormFile("file")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			data, err := io.ReadAll(file)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			io.WriteString(w, strconv.Itoa(len(data)))
+		})
+	case "stream":
+		r.Get("/stream", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			io.Copy(w, bytes.NewReader(cenerybench.StreamData))
+		})
+	default:
+		r.Get("/chain", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		})
+	}
+	return cenerybench.ServeHandler(r)
+}
+
+func TestBenchSuite(t *testing.T) {
+	cenerybench.Verify(t, benchEngine)
+}
+
+func BenchmarkSuite(b *testing.B) {
+	cenerybench.Run(b, benchEngine)
+}
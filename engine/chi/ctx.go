@@ -2,10 +2,13 @@ package chi
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"io"
+	"io/fs"
+	"net"
 	"net/http"
+	"strconv"
 	"sync"
 	"sync/atomic"
 
@@ -14,20 +17,32 @@ import (
 )
 
 type serverCtx struct {
-	w    http.ResponseWriter
-	r    *http.Request
-	next http.Handler
-	resp cenery.Response
+	w       http.ResponseWriter
+	r       *http.Request
+	next    http.Handler
+	resp    cenery.Response
+	res     response // backs resp, so a pooled serverCtx allocates nothing
+	state   *requestState
+	pending *timeoutResponse
+	err     error
 }
 
 func NewServerCtx(w http.ResponseWriter, r *http.Request, next http.Handler) cenery.Ctx {
-	resp, writer := NewResponse(w)
-	return &serverCtx{
-		w:    writer,
-		r:    r,
-		next: next,
-		resp: resp,
-	}
+	r, state := withRequestState(r)
+	return newServerCtx(w, r, next, state)
+}
+
+func newServerCtx(w http.ResponseWriter, r *http.Request, next http.Handler, state *requestState) *serverCtx {
+	s := &serverCtx{}
+	s.reset(w, r, next, state)
+	return s
+}
+
+// reset prepares s for a handler, clearing what the previous one left.
+func (s *serverCtx) reset(w http.ResponseWriter, r *http.Request, next http.Handler, state *requestState) {
+	*s = serverCtx{r: r, next: next, state: state}
+	s.w = s.res.init(w)
+	s.resp = &s.res
 }
 
 func (s *serverCtx) Params(key string, defaultValue ...string) string {
@@ -50,37 +65,46 @@ func (s *serverCtx) QueryParam(key string, defaultValue ...string) string {
 	return val
 }
 
+func (s *serverCtx) IP() string {
+	host, _, err := net.SplitHostPort(s.r.RemoteAddr)
+	if err != nil {
+		return s.r.RemoteAddr
+	}
+	return host
+}
+
 func (s *serverCtx) BodyParser(out any) error {
 	if s.r.Body == nil {
 		return errors.New("request body can't be empty")
 	}
+	limitBody(s.r)
 	data, err := io.ReadAll(s.r.Body)
 	if err != nil {
 		return err
 	}
-	if len(data) == 0 {
-		return nil
-	}
 
 	s.r.Body = io.NopCloser(bytes.NewBuffer(data))
-	return jsonUnmarshal(data, out)
+	return cenery.DecodeBody(s, s.state.appConfig(), data, out)
 }
 
 func (s *serverCtx) BodyParserStream(out any) error {
 	if s.r.Body == nil {
 		return errors.New("request body can't be empty")
 	}
-	dec := json.NewDecoder(s.r.Body)
-	if err := dec.Decode(out); err != nil {
-		if errors.Is(err, io.EOF) {
-			return nil
-		}
-		return err
+	limitBody(s.r)
+	return cenery.DecodeJSON(s, s.state.appConfig(), s.r.Body, out)
+}
+
+func (s *serverCtx) BodyParserNDJSON(out any, fn func() error) error {
+	if s.r.Body == nil {
+		return errors.New("request body can't be empty")
 	}
-	return nil
+	limitBody(s.r)
+	return cenery.DecodeNDJSON(s, s.state.appConfig(), s.r.Body, out, fn)
 }
 
 func (s *serverCtx) BodyStream() io.ReadCloser {
+	limitBody(s.r)
 	return s.r.Body
 }
 
@@ -92,6 +116,14 @@ func (s *serverCtx) FormFiles(fileKey string) *[]cenery.FileData {
 	return FormFiles(s.r, fileKey)
 }
 
+func (s *serverCtx) FormFileE(fileKey string) (*cenery.FileData, error) {
+	return FormFileE(s.r, fileKey)
+}
+
+func (s *serverCtx) FormFilesE(fileKey string) ([]cenery.FileData, error) {
+	return FormFilesE(s.r, fileKey)
+}
+
 func (s *serverCtx) FormFileStream(fileKey string) (*cenery.FileStream, error) {
 	return FormFileStream(s.r, fileKey)
 }
@@ -100,6 +132,36 @@ func (s *serverCtx) FormFilesStream(fileKey string) ([]*cenery.FileStream, error
 	return FormFilesStream(s.r, fileKey)
 }
 
+func (s *serverCtx) FormValue(key string, defaultValue ...string) string {
+	var val string
+	if values, _ := formValues(s.r); len(values[key]) > 0 {
+		val = values[key][0]
+	}
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (s *serverCtx) FormValues(key string) []string {
+	values, _ := formValues(s.r)
+	return values[key]
+}
+
+func (s *serverCtx) MultipartForm() (*cenery.MultipartForm, error) {
+	form, err := multipartForm(s.r)
+	if err != nil {
+		return nil, err
+	}
+	return &cenery.MultipartForm{Value: form.Value, File: form.File}, nil
+}
+
+func (s *serverCtx) MultipartReader() (*cenery.MultipartReader, error) {
+	return multipartReader(s.r)
+}
+
 var enableSendBufferPooling atomic.Bool
 
 // EnableSendBufferPooling toggles pooling for Send() to reuse buffers
@@ -140,7 +202,7 @@ func (s *serverCtx) Send(status int, data []byte) error {
 }
 
 func (s *serverCtx) SendJSON(status int, data any) error {
-	payload, err := jsonMarshal(data)
+	payload, err := s.state.appConfig().JSONCodec().Marshal(data)
 	if err != nil {
 		return err
 	}
@@ -150,13 +212,99 @@ func (s *serverCtx) SendJSON(status int, data any) error {
 	return err
 }
 
+func (s *serverCtx) Negotiate(status int, data any) error {
+	return cenery.Negotiate(s, s.state.appConfig(), status, data)
+}
+
+func (s *serverCtx) SendJSONStream(status int, items any, format ...cenery.JSONStreamFormat) error {
+	write, err := cenery.JSONStream(s, s.state.appConfig(), items, format...)
+	if err != nil {
+		return err
+	}
+	s.w.WriteHeader(status)
+	rc := http.NewResponseController(s.w)
+	return write(s.r.Context(), s.w, rc.Flush)
+}
+
 func (s *serverCtx) SendStream(status int, contentType string, reader io.Reader) error {
+	return s.SendStreamSized(status, contentType, reader, cenery.ReaderSize(reader))
+}
+
+// SendStreamSized copies reader straight to the http.ResponseWriter, so an
+// *os.File is sent with sendfile when response capture is off.
+func (s *serverCtx) SendStreamSized(status int, contentType string, reader io.Reader, size int64) error {
 	s.w.Header().Set("Content-Type", contentType)
+	if size >= 0 {
+		s.w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
 	s.w.WriteHeader(status)
 	_, err := io.Copy(s.w, reader)
 	return err
 }
 
+func (s *serverCtx) SendFile(path string) error {
+	return cenery.SendFile(s, path)
+}
+
+func (s *serverCtx) SendFileFS(fsys fs.FS, name string) error {
+	return cenery.SendFileFS(s, fsys, name)
+}
+
+func (s *serverCtx) Download(path, filename string) error {
+	return cenery.Download(s, path, filename)
+}
+
+func (s *serverCtx) SaveFile(file *cenery.FileStream, path string) error {
+	return cenery.SaveFile(s, file, path)
+}
+
+func (s *serverCtx) Redirect(status int, location string) error {
+	return cenery.Redirect(s, s.state.appConfig(), status, location)
+}
+
+func (s *serverCtx) RedirectToRoute(name string, params map[string]string) error {
+	return cenery.RedirectToRoute(s, s.state.appConfig(), name, params)
+}
+
+func (s *serverCtx) RedirectBack(fallback string) error {
+	return cenery.RedirectBack(s, s.state.appConfig(), fallback)
+}
+
+func (s *serverCtx) SSE(fn func(w cenery.EventWriter) error) error {
+	cenery.SetEventStreamHeaders(s.resp)
+	s.w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(s.w)
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+	w, cancel := cenery.NewEventWriter(s.r.Context(), s.w, rc.Flush, s.r.Header.Get("Last-Event-ID"))
+	defer cancel()
+	if err := fn(w); err != nil && w.Context().Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (s *serverCtx) Locals(key string, value ...any) any {
+	if len(value) > 0 {
+		s.state.setLocal(key, value[0])
+		return value[0]
+	}
+	return s.state.local(key)
+}
+
+func (s *serverCtx) Context() context.Context {
+	return s.r.Context()
+}
+
+func (s *serverCtx) SetContext(ctx context.Context) {
+	s.r = s.r.WithContext(ctx)
+}
+
+func (s *serverCtx) SetLimits(l cenery.Limits) {
+	s.state.setLimits(requestLimits(s.r).Merge(l))
+}
+
 func (s *serverCtx) Request() cenery.Request {
 	return NewRequest(s.r)
 }
@@ -169,6 +317,58 @@ func (s *serverCtx) Next() error {
 	if s.next == nil {
 		return nil
 	}
+	seq := s.state.seqNum()
 	s.next.ServeHTTP(s.w, s.r)
-	return nil
+	return s.state.since(seq)
+}
+
+// NextTimeout runs the rest of the chain on its own goroutine, writing into
+// a buffer. If ctx is done first, the buffer is discarded and the error
+// response rendered by the caller is sent in its place. The request is not
+// released until the chain returns, since chi pools its routing context.
+func (s *serverCtx) NextTimeout(ctx context.Context) error {
+	if s.next == nil {
+		return nil
+	}
+
+	seq := s.state.seqNum()
+	r := s.r.WithContext(ctx)
+	tw := newTimeoutWriter(s.w)
+	next := s.next
+	s.state.detach()
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				tw.panicVal = p
+			}
+			close(done)
+		}()
+		next.ServeHTTP(tw, r)
+	}()
+
+	select {
+	case <-done:
+		if tw.panicVal != nil {
+			panic(tw.panicVal)
+		}
+		tw.writeTo(s.w)
+		return s.state.since(seq)
+	case <-ctx.Done():
+		tw.discard()
+		buf := newTimeoutWriter(s.w)
+		s.pending = &timeoutResponse{w: s.w, buf: buf, done: done}
+		s.resp, s.w = NewResponse(buf)
+		return ctx.Err()
+	}
+}
+
+// release finishes the response of a handler that returned err.
+func (s *serverCtx) release() {
+	if s.pending != nil {
+		s.pending.finish()
+	}
+	if s.err != nil {
+		s.state.setError(s.err)
+	}
 }
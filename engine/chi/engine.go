@@ -6,8 +6,8 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 )
 
-func NewApp() cenery.App {
+func NewApp(opts ...cenery.Option) cenery.App {
 	chiApp := chi.NewRouter()
 	chiApp.Use(middleware.Recoverer)
-	return New(chiApp)
+	return New(chiApp, opts...)
 }
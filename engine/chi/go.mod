@@ -8,3 +8,5 @@ require (
 	github.com/dreamph/cenery v1.0.1
 	github.com/go-chi/chi/v5 v5.2.2
 )
+
+require github.com/gorilla/websocket v1.5.3
@@ -1,11 +0,0 @@
-package chi
-
-import "encoding/json"
-
-func jsonMarshal(v any) ([]byte, error) {
-	return json.Marshal(v)
-}
-
-func jsonUnmarshal(data []byte, v any) error {
-	return json.Unmarshal(data, v)
-}
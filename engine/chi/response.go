@@ -65,20 +65,23 @@ func EnableResponseCapture(enabled bool) {
 }
 
 func NewResponse(w http.ResponseWriter) (cenery.Response, http.ResponseWriter) {
-	var resBodyBuffer *bytes.Buffer
+	h := &response{}
+	return h, h.init(w)
+}
+
+// init points h at w and returns the writer handlers should write to, which
+// also fills the captured body when capture is enabled.
+func (h *response) init(w http.ResponseWriter) http.ResponseWriter {
+	h.resBody = nil
 	if captureResponseBody.Load() {
-		resBodyBuffer = &bytes.Buffer{}
-		writer := &responseBodyWriter{
+		h.resBody = &bytes.Buffer{}
+		w = &responseBodyWriter{
 			ResponseWriter: w,
-			writer:         io.MultiWriter(w, resBodyBuffer),
+			writer:         io.MultiWriter(w, h.resBody),
 		}
-		w = writer
 	}
-
-	return &response{
-		resp:    w,
-		resBody: resBodyBuffer,
-	}, w
+	h.resp = w
+	return w
 }
 
 func (h *response) Body() []byte {
@@ -2,7 +2,9 @@ package chi
 
 import (
 	"context"
+	"io/fs"
 	"net/http"
+	"strings"
 
 	"github.com/dreamph/cenery"
 	"github.com/go-chi/chi/v5"
@@ -11,10 +13,11 @@ import (
 type app struct {
 	server     *chi.Mux
 	httpServer *http.Server
+	config     *cenery.Config
 }
 
-func New(server *chi.Mux) cenery.App {
-	return &app{server: server}
+func New(server *chi.Mux, opts ...cenery.Option) cenery.App {
+	return &app{server: server, config: cenery.NewConfig(opts...)}
 }
 
 func (a *app) Name() string {
@@ -29,54 +32,84 @@ func (a *app) Listen(addr string) error {
 	return a.httpServer.ListenAndServe()
 }
 
+// ServeHTTP serves r with the app, so it can be mounted in another
+// net/http server or driven by httptest and cenerytest.
+func (a *app) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.server.ServeHTTP(w, r)
+}
+
 func (a *app) Use(handlers ...cenery.Handler) {
 	middlewares := a.toMiddlewares(handlers...)
 	a.server.Use(middlewares...)
 }
 
-func (a *app) Get(path string, handlers ...cenery.Handler) {
+func (a *app) Get(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).Get(normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodGet, path)
 }
 
-func (a *app) Post(path string, handlers ...cenery.Handler) {
+func (a *app) Post(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).Post(normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodPost, path)
 }
 
-func (a *app) Put(path string, handlers ...cenery.Handler) {
+func (a *app) Put(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).Put(normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodPut, path)
 }
 
-func (a *app) Delete(path string, handlers ...cenery.Handler) {
+func (a *app) Delete(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).Delete(normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodDelete, path)
 }
 
-func (a *app) Head(path string, handlers ...cenery.Handler) {
+func (a *app) Head(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).Head(normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodHead, path)
 }
 
-func (a *app) Options(path string, handlers ...cenery.Handler) {
+func (a *app) Options(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).Options(normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodOptions, path)
 }
 
-func (a *app) Connect(path string, handlers ...cenery.Handler) {
+func (a *app) Connect(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).MethodFunc(http.MethodConnect, normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodConnect, path)
 }
 
-func (a *app) Patch(path string, handlers ...cenery.Handler) {
+func (a *app) Patch(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).Patch(normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodPatch, path)
 }
 
-func (a *app) Trace(path string, handlers ...cenery.Handler) {
+func (a *app) Trace(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewares := a.toHandlers(handlers...)
 	a.server.With(middlewares...).MethodFunc(http.MethodTrace, normalizePath(path), handler)
+	return a.config.Routes.Add(http.MethodTrace, path)
+}
+
+func (a *app) Static(prefix, root string, config ...cenery.StaticConfig) {
+	a.StaticFS(prefix, cenery.RootFS(root), config...)
+}
+
+func (a *app) StaticFS(prefix string, fsys fs.FS, config ...cenery.StaticConfig) {
+	handler := cenery.StaticHandler(prefix, fsys, config...)
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" {
+		a.Get(prefix, handler)
+		a.Head(prefix, handler)
+	}
+	a.Get(prefix+"/*", handler)
+	a.Head(prefix+"/*", handler)
 }
 
 func normalizePath(path string) string {
@@ -130,10 +163,7 @@ func (a *app) toHandlers(handlers ...cenery.Handler) (http.HandlerFunc, []func(h
 
 	h := handlers[len(handlers)-1]
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		svc := NewServerCtx(w, r, nil)
-		if err := h(svc); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		a.serve(h, w, r, nil)
 	}
 
 	middlewares := a.toMiddlewares(handlers[:len(handlers)-1]...)
@@ -150,12 +180,32 @@ func (a *app) toMiddlewares(handlers ...cenery.Handler) []func(http.Handler) htt
 		h := handler // Copy variable to avoid closure capture bug
 		middlewareHandlers[i] = func(next http.Handler) http.Handler {
 			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				svc := NewServerCtx(w, r, next)
-				if err := h(svc); err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-				}
+				a.serve(h, w, r, next)
 			})
 		}
 	}
 	return middlewareHandlers
 }
+
+func (a *app) serve(h cenery.Handler, w http.ResponseWriter, r *http.Request, next http.Handler) {
+	r, state := withRequestState(r)
+	state.init(a.config)
+	svc, caller := acquireCtx(w, r, next, state)
+	defer releaseCtx(svc, caller)
+	if err := h(svc); err != nil {
+		a.handleError(svc, err)
+	}
+	svc.release()
+}
+
+// handleError passes err to the error handler unless a later handler has
+// already done so and the error is only being returned up the chain.
+func (a *app) handleError(s *serverCtx, err error) {
+	if s.pending == nil && s.state.handled(err) {
+		return
+	}
+	s.err = err
+	if herr := a.config.ErrorHandler(s, err); herr != nil {
+		http.Error(s.w, herr.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,136 @@
+package chi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/dreamph/cenery"
+)
+
+type stateKey struct{}
+
+// requestState is shared by every handler serving one request. It is locked
+// because a timed-out chain keeps running next to the handlers above it.
+type requestState struct {
+	mu  sync.Mutex
+	err error // last error passed to the error handler
+	seq int   // bumped whenever err is set
+
+	locals      map[string]any
+	limits      *cenery.Limits // nil until set by the app or a route
+	config      *cenery.Config // app serving the request, nil until set
+	bodyLimited bool           // request body already wrapped
+
+	current  *serverCtx // shared by the handlers of the chain, see acquireCtx
+	detached bool       // the chain went on in another goroutine
+}
+
+func withRequestState(r *http.Request) (*http.Request, *requestState) {
+	if state, ok := r.Context().Value(stateKey{}).(*requestState); ok {
+		return r, state
+	}
+	state := &requestState{}
+	return r.WithContext(context.WithValue(r.Context(), stateKey{}, state)), state
+}
+
+func (st *requestState) setError(err error) {
+	st.mu.Lock()
+	st.err = err
+	st.seq++
+	st.mu.Unlock()
+}
+
+// handled reports whether err, or an error it wraps, was already handled.
+func (st *requestState) handled(err error) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.err != nil && errors.Is(err, st.err)
+}
+
+func (st *requestState) seqNum() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.seq
+}
+
+// since returns the handled error if one was set after seq.
+func (st *requestState) since(seq int) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.seq == seq {
+		return nil
+	}
+	return st.err
+}
+
+func (st *requestState) local(key string) any {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.locals[key]
+}
+
+func (st *requestState) setLocal(key string, val any) {
+	st.mu.Lock()
+	if st.locals == nil {
+		st.locals = make(map[string]any)
+	}
+	st.locals[key] = val
+	st.mu.Unlock()
+}
+
+// init records the config of the app serving the request, unless an outer
+// app already did.
+func (st *requestState) init(config *cenery.Config) {
+	st.mu.Lock()
+	if st.config == nil {
+		st.config = config
+	}
+	if st.limits == nil {
+		l := config.Limits
+		st.limits = &l
+	}
+	st.mu.Unlock()
+}
+
+func (st *requestState) appConfig() *cenery.Config {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.config
+}
+
+func (st *requestState) setLimits(l cenery.Limits) {
+	st.mu.Lock()
+	st.limits = &l
+	st.mu.Unlock()
+}
+
+// requestLimits returns the limits of the app serving r, or the defaults
+// when r is not served through one.
+func requestLimits(r *http.Request) cenery.Limits {
+	if state, ok := r.Context().Value(stateKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if state.limits != nil {
+			return *state.limits
+		}
+	}
+	return cenery.DefaultLimits()
+}
+
+// limitBody caps r.Body at the current BodyLimit. The body is only wrapped
+// once per request, so a limit set after it was first read has no effect.
+func limitBody(r *http.Request) {
+	state, ok := r.Context().Value(stateKey{}).(*requestState)
+	if ok {
+		state.mu.Lock()
+		limited := state.bodyLimited
+		state.bodyLimited = true
+		state.mu.Unlock()
+		if limited {
+			return
+		}
+	}
+	r.Body = requestLimits(r).LimitBody(r.Body, r.ContentLength)
+}
@@ -2,7 +2,7 @@ package chi
 
 import (
 	"errors"
-	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 
@@ -10,161 +10,121 @@ import (
 )
 
 func FormFile(r *http.Request, fileKey string) *cenery.FileData {
-	file, _ := formFile(r, fileKey, false)
+	file, _ := FormFileE(r, fileKey)
 	return file
 }
 
-func formFile(r *http.Request, fileKey string, errorIfNotFound bool) (*cenery.FileData, error) {
-	form, err := multipartForm(r)
+// FormFileE reads the first file uploaded as fileKey into memory.
+func FormFileE(r *http.Request, fileKey string) (*cenery.FileData, error) {
+	headers, err := formFileHeaders(r, fileKey)
 	if err != nil {
 		return nil, err
 	}
-
-	files, ok := form.File[fileKey]
-	if !ok {
-		if errorIfNotFound {
-			return nil, errors.New("fileKey not found:" + fileKey)
-		}
-		return nil, nil
-	}
-	file := files[0]
-	contentType := "application/octet-stream" // default
-	if ct := file.Header.Get("Content-Type"); ct != "" {
-		contentType = ct
-	}
-	return &cenery.FileData{
-		FileData:        fileHeaderToBytes(file),
-		FileSize:        file.Size,
-		FileContentType: contentType,
-		FileName:        file.Filename,
-	}, nil
+	return cenery.ReadFormFile(headers[0])
 }
 
 func FormFiles(r *http.Request, fileKey string) *[]cenery.FileData {
-	files, _ := formFiles(r, fileKey, false)
-	return files
+	files, err := FormFilesE(r, fileKey)
+	if err != nil {
+		return nil
+	}
+	return &files
 }
 
-func formFiles(r *http.Request, fileKey string, errorIfNotFound bool) (*[]cenery.FileData, error) {
+// FormFilesE reads every file uploaded as fileKey into memory.
+func FormFilesE(r *http.Request, fileKey string) ([]cenery.FileData, error) {
 	form, err := multipartForm(r)
 	if err != nil {
 		return nil, err
 	}
-
-	files, ok := form.File[fileKey]
-	if !ok {
-		if errorIfNotFound {
-			return nil, errors.New("fileKey not found:" + fileKey)
-		}
-		return nil, nil
-	}
-
-	uploadFiles := make([]cenery.FileData, len(files))
-	for i, file := range files {
-		contentType := "application/octet-stream" // default
-		if ct := file.Header.Get("Content-Type"); ct != "" {
-			contentType = ct
-		}
-		uploadFiles[i] = cenery.FileData{
-			FileData:        fileHeaderToBytes(file),
-			FileSize:        file.Size,
-			FileContentType: contentType,
-			FileName:        file.Filename,
-		}
-	}
-	return &uploadFiles, nil
+	return cenery.FormFileData(form, fileKey)
 }
 
-func fileHeaderToBytes(h *multipart.FileHeader) []byte {
-	file, err := h.Open()
-	if err != nil {
-		return nil
-	}
-	defer func(file multipart.File) {
-		_ = file.Close()
-	}(file)
-	data, err := io.ReadAll(file)
+// FormFileStream opens the first file uploaded as fileKey without reading
+// it into memory. Close the stream once done.
+func FormFileStream(r *http.Request, fileKey string) (*cenery.FileStream, error) {
+	headers, err := formFileHeaders(r, fileKey)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return data
+	return cenery.OpenFormFile(headers[0])
 }
 
-// FormFileStream returns a streaming file upload (no memory allocation)
-func FormFileStream(r *http.Request, fileKey string) (*cenery.FileStream, error) {
+// FormFilesStream opens every file uploaded as fileKey without reading
+// them into memory.
+func FormFilesStream(r *http.Request, fileKey string) ([]*cenery.FileStream, error) {
 	form, err := multipartForm(r)
 	if err != nil {
 		return nil, err
 	}
+	return cenery.FormFileStreams(form, fileKey)
+}
 
-	files, ok := form.File[fileKey]
-	if !ok {
-		return nil, errors.New("fileKey not found:" + fileKey)
-	}
-
-	fileHeader := files[0]
-	file, err := fileHeader.Open()
+func formFileHeaders(r *http.Request, fileKey string) ([]*multipart.FileHeader, error) {
+	form, err := multipartForm(r)
 	if err != nil {
 		return nil, err
 	}
+	return cenery.FormFileHeaders(form, fileKey)
+}
 
-	contentType := "application/octet-stream" // default
-	if ct := fileHeader.Header.Get("Content-Type"); ct != "" {
-		contentType = ct
+func multipartForm(r *http.Request) (*multipart.Form, error) {
+	limits := requestLimits(r)
+	if r.MultipartForm == nil {
+		limitBody(r)
+		if err := cenery.ParseMultipartForm(r, limits); err != nil {
+			if errors.Is(err, cenery.ErrBodyTooLarge) {
+				return nil, cenery.RequestError(cenery.ErrBodyTooLarge)
+			}
+			if errors.Is(err, http.ErrNotMultipart) {
+				return nil, cenery.RequestError(cenery.ErrNotMultipart)
+			}
+			return nil, err
+		}
 	}
-
-	return &cenery.FileStream{
-		File:            file,
-		FileName:        fileHeader.Filename,
-		FileSize:        fileHeader.Size,
-		FileContentType: contentType,
-	}, nil
+	if err := limits.CheckForm(r.MultipartForm); err != nil {
+		return nil, err
+	}
+	return r.MultipartForm, nil
 }
 
-// FormFilesStream returns multiple streaming file uploads (no memory allocation)
-func FormFilesStream(r *http.Request, fileKey string) ([]*cenery.FileStream, error) {
-	form, err := multipartForm(r)
+// multipartReader reads the multipart body of r part by part, within the
+// request's limits.
+func multipartReader(r *http.Request) (*cenery.MultipartReader, error) {
+	limitBody(r)
+	mr, err := r.MultipartReader()
 	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, cenery.RequestError(cenery.ErrNotMultipart)
+		}
 		return nil, err
 	}
+	return cenery.NewMultipartReader(mr, requestLimits(r)), nil
+}
 
-	fileHeaders, ok := form.File[fileKey]
-	if !ok {
-		return nil, errors.New("fileKey not found:" + fileKey)
-	}
-
-	streams := make([]*cenery.FileStream, 0, len(fileHeaders))
-	for _, fileHeader := range fileHeaders {
-		file, err := fileHeader.Open()
+// formValues returns the fields of a multipart/form-data or
+// application/x-www-form-urlencoded body.
+func formValues(r *http.Request) (map[string][]string, error) {
+	if isMultipart(r.Header.Get("Content-Type")) {
+		form, err := multipartForm(r)
 		if err != nil {
-			// Close previously opened files on error
-			for _, s := range streams {
-				_ = s.File.Close()
-			}
 			return nil, err
 		}
-
-		contentType := "application/octet-stream" // default
-		if ct := fileHeader.Header.Get("Content-Type"); ct != "" {
-			contentType = ct
-		}
-
-		streams = append(streams, &cenery.FileStream{
-			File:            file,
-			FileName:        fileHeader.Filename,
-			FileSize:        fileHeader.Size,
-			FileContentType: contentType,
-		})
+		return form.Value, nil
 	}
-
-	return streams, nil
-}
-
-func multipartForm(r *http.Request) (*multipart.Form, error) {
-	if r.MultipartForm == nil {
-		if err := r.ParseMultipartForm(32 << 20); err != nil {
+	if r.PostForm == nil {
+		limitBody(r)
+		if err := r.ParseForm(); err != nil {
+			if errors.Is(err, cenery.ErrBodyTooLarge) {
+				return nil, cenery.RequestError(cenery.ErrBodyTooLarge)
+			}
 			return nil, err
 		}
 	}
-	return r.MultipartForm, nil
+	return r.PostForm, nil
+}
+
+func isMultipart(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "multipart/form-data"
 }
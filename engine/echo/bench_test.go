@@ -0,0 +1,90 @@
+package echo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/dreamph/cenery"
+	"github.com/dreamph/cenery/cenerybench"
+	"github.com/labstack/echo/v4"
+)
+
+var benchEngine = cenerybench.Engine{
+	Cenery: func(setup func(app cenery.App)) cenerybench.Serve {
+		app := New(echo.New())
+		setup(app)
+		return cenerybench.ServeHandler(app.(http.Handler))
+	},
+	Native: nativeScenario,
+}
+
+// nativeScenario implements s with echo directly.
+func nativeScenario(s cenerybench.Scenario) cenerybench.Serve {
+	e := echo.New()
+	for range s.Middleware {
+		e.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+			return func(c echo.Context) error {
+				return next(c)
+			}
+		})
+	}
+	switch s.Name {
+	case "static":
+		e.GET("/hello", func(c echo.Context) error {
+			return c.String(http.StatusOK, "Hello, World!")
+		})
+	case "param":
+		e.GET("/users/:id/posts/:post", func(c echo.Context) error {
+			return c.String(http.StatusOK, c.Param("id")+"/"+c.Param("post"))
+		})
+	case "json-encode":
+		e.GET("/user", func(c echo.Context) error {
+			return c.JSON(http.StatusOK, cenerybench.SampleUser)
+		})
+	case "json-decode":
+		e.POST("/user", func(c echo.Context) error {
+			var u cenerybench.User
+			if err := c.Bind(&u); err != nil {
+				return err
+			}
+			return c.String(http.StatusOK, u.Name)
+		})
+	case "multipart":
+		e.POST("/upload", func(c echo.Context) error {
+			header, err := c.FormFile("file")
+			if err != nil {
+				return err
+			}
+			file, err := header.Open()
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			data, err := io.ReadAll(file)
+			if err != nil {
+				return err
+			}
+			return c.String(http.StatusOK, strconv.Itoa(len(data)))
+		})
+	case "stream":
+		e.GET("/stream", func(c echo.Context) error {
+			return c.Stream(http.StatusOK, "application/octet-stream", bytes.NewReader(cenerybench.StreamData))
+		})
+	default:
+		e.GET("/chain", func(c echo.Context) error {
+			return c.String(http.StatusOK, "ok")
+		})
+	}
+	return cenerybench.ServeHandler(e)
+}
+
+func TestBenchSuite(t *testing.T) {
+	cenerybench.Verify(t, benchEngine)
+}
+
+func BenchmarkSuite(b *testing.B) {
+	cenerybench.Run(b, benchEngine)
+}
@@ -2,9 +2,13 @@ package echo
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"strconv"
 	"sync"
 	"sync/atomic"
 
@@ -13,18 +17,30 @@ import (
 )
 
 type serverCtx struct {
-	ctx  echo.Context
-	next echo.HandlerFunc
-	resp cenery.Response
+	ctx     echo.Context
+	next    echo.HandlerFunc
+	resp    cenery.Response
+	res     response // backs resp, so a pooled serverCtx allocates nothing
+	state   *requestState
+	pending *timeoutResponse
+	err     error
 }
 
 func NewServerCtx(ctx echo.Context, next echo.HandlerFunc) cenery.Ctx {
-	resp := NewResponse(ctx.Response())
-	return &serverCtx{
-		ctx:  ctx,
-		next: next,
-		resp: resp,
-	}
+	return newServerCtx(ctx, next)
+}
+
+func newServerCtx(ctx echo.Context, next echo.HandlerFunc) *serverCtx {
+	s := &serverCtx{}
+	s.reset(ctx, next, getRequestState(ctx))
+	return s
+}
+
+// reset prepares s for a handler, clearing what the previous one left.
+func (s *serverCtx) reset(ctx echo.Context, next echo.HandlerFunc, state *requestState) {
+	*s = serverCtx{ctx: ctx, next: next, state: state}
+	s.res.init(ctx.Response())
+	s.resp = &s.res
 }
 
 func (s *serverCtx) Params(key string, defaultValue ...string) string {
@@ -47,25 +63,62 @@ func (s *serverCtx) QueryParam(key string, defaultValue ...string) string {
 	return val
 }
 
-func (s *serverCtx) BodyParser(out any) error {
-	return s.ctx.Bind(out)
+func (s *serverCtx) IP() string {
+	host, _, err := net.SplitHostPort(s.ctx.Request().RemoteAddr)
+	if err != nil {
+		return s.ctx.Request().RemoteAddr
+	}
+	return host
 }
 
-func (s *serverCtx) BodyParserStream(out any) error {
-	if s.ctx.Request().Body == nil {
+func (s *serverCtx) BodyParser(out any) error {
+	if cfg := s.state.appConfig(); cfg != nil && cfg.NativeBodyParser {
+		return s.bind(out)
+	}
+	req := s.ctx.Request()
+	if req.Body == nil {
 		return errors.New("request body can't be empty")
 	}
-	dec := json.NewDecoder(s.ctx.Request().Body)
-	if err := dec.Decode(out); err != nil {
-		if errors.Is(err, io.EOF) {
-			return nil
+	limitBody(s.ctx)
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+
+	req.Body = io.NopCloser(bytes.NewBuffer(data))
+	return cenery.DecodeBody(s, s.state.appConfig(), data, out)
+}
+
+// bind decodes the body with echo's Bind for Config.NativeBodyParser.
+func (s *serverCtx) bind(out any) error {
+	limitBody(s.ctx)
+	if err := s.ctx.Bind(out); err != nil {
+		if errors.Is(err, cenery.ErrBodyTooLarge) {
+			return cenery.RequestError(cenery.ErrBodyTooLarge)
 		}
 		return err
 	}
 	return nil
 }
 
+func (s *serverCtx) BodyParserStream(out any) error {
+	if s.ctx.Request().Body == nil {
+		return errors.New("request body can't be empty")
+	}
+	limitBody(s.ctx)
+	return cenery.DecodeJSON(s, s.state.appConfig(), s.ctx.Request().Body, out)
+}
+
+func (s *serverCtx) BodyParserNDJSON(out any, fn func() error) error {
+	if s.ctx.Request().Body == nil {
+		return errors.New("request body can't be empty")
+	}
+	limitBody(s.ctx)
+	return cenery.DecodeNDJSON(s, s.state.appConfig(), s.ctx.Request().Body, out, fn)
+}
+
 func (s *serverCtx) BodyStream() io.ReadCloser {
+	limitBody(s.ctx)
 	return s.ctx.Request().Body
 }
 
@@ -77,6 +130,14 @@ func (s *serverCtx) FormFiles(fileKey string) *[]cenery.FileData {
 	return FormFiles(s.ctx, fileKey)
 }
 
+func (s *serverCtx) FormFileE(fileKey string) (*cenery.FileData, error) {
+	return FormFileE(s.ctx, fileKey)
+}
+
+func (s *serverCtx) FormFilesE(fileKey string) ([]cenery.FileData, error) {
+	return FormFilesE(s.ctx, fileKey)
+}
+
 func (s *serverCtx) FormFileStream(fileKey string) (*cenery.FileStream, error) {
 	return FormFileStream(s.ctx, fileKey)
 }
@@ -85,6 +146,36 @@ func (s *serverCtx) FormFilesStream(fileKey string) ([]*cenery.FileStream, error
 	return FormFilesStream(s.ctx, fileKey)
 }
 
+func (s *serverCtx) FormValue(key string, defaultValue ...string) string {
+	var val string
+	if values, _ := formValues(s.ctx); len(values[key]) > 0 {
+		val = values[key][0]
+	}
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (s *serverCtx) FormValues(key string) []string {
+	values, _ := formValues(s.ctx)
+	return values[key]
+}
+
+func (s *serverCtx) MultipartForm() (*cenery.MultipartForm, error) {
+	form, err := multipartForm(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cenery.MultipartForm{Value: form.Value, File: form.File}, nil
+}
+
+func (s *serverCtx) MultipartReader() (*cenery.MultipartReader, error) {
+	return multipartReader(s.ctx)
+}
+
 var enableSendBufferPooling atomic.Bool
 
 // EnableSendBufferPooling toggles pooling for Send() to reuse buffers
@@ -124,16 +215,110 @@ func (s *serverCtx) Send(status int, data []byte) error {
 }
 
 func (s *serverCtx) SendJSON(status int, data any) error {
-	return s.ctx.JSON(status, data)
+	payload, err := s.state.appConfig().JSONCodec().Marshal(data)
+	if err != nil {
+		return err
+	}
+	s.ctx.Response().Header().Set("Content-Type", "application/json")
+	return s.Send(status, payload)
+}
+
+func (s *serverCtx) Negotiate(status int, data any) error {
+	return cenery.Negotiate(s, s.state.appConfig(), status, data)
+}
+
+func (s *serverCtx) SendJSONStream(status int, items any, format ...cenery.JSONStreamFormat) error {
+	write, err := cenery.JSONStream(s, s.state.appConfig(), items, format...)
+	if err != nil {
+		return err
+	}
+	res := s.ctx.Response()
+	res.WriteHeader(status)
+	rc := http.NewResponseController(res.Writer)
+	return write(s.ctx.Request().Context(), res, rc.Flush)
 }
 
 func (s *serverCtx) SendStream(status int, contentType string, reader io.Reader) error {
+	return s.SendStreamSized(status, contentType, reader, cenery.ReaderSize(reader))
+}
+
+// SendStreamSized copies reader straight to the http.ResponseWriter, so an
+// *os.File is sent with sendfile.
+func (s *serverCtx) SendStreamSized(status int, contentType string, reader io.Reader, size int64) error {
 	s.ctx.Response().Header().Set("Content-Type", contentType)
+	if size >= 0 {
+		s.ctx.Response().Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
 	s.ctx.Response().WriteHeader(status)
 	_, err := io.Copy(s.ctx.Response().Writer, reader)
 	return err
 }
 
+func (s *serverCtx) SendFile(path string) error {
+	return cenery.SendFile(s, path)
+}
+
+func (s *serverCtx) SendFileFS(fsys fs.FS, name string) error {
+	return cenery.SendFileFS(s, fsys, name)
+}
+
+func (s *serverCtx) Download(path, filename string) error {
+	return cenery.Download(s, path, filename)
+}
+
+func (s *serverCtx) SaveFile(file *cenery.FileStream, path string) error {
+	return cenery.SaveFile(s, file, path)
+}
+
+func (s *serverCtx) Redirect(status int, location string) error {
+	return cenery.Redirect(s, s.state.appConfig(), status, location)
+}
+
+func (s *serverCtx) RedirectToRoute(name string, params map[string]string) error {
+	return cenery.RedirectToRoute(s, s.state.appConfig(), name, params)
+}
+
+func (s *serverCtx) RedirectBack(fallback string) error {
+	return cenery.RedirectBack(s, s.state.appConfig(), fallback)
+}
+
+func (s *serverCtx) SSE(fn func(w cenery.EventWriter) error) error {
+	cenery.SetEventStreamHeaders(s.resp)
+	res := s.ctx.Response()
+	res.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(res.Writer)
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+	req := s.ctx.Request()
+	w, cancel := cenery.NewEventWriter(req.Context(), res, rc.Flush, req.Header.Get("Last-Event-ID"))
+	defer cancel()
+	if err := fn(w); err != nil && w.Context().Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (s *serverCtx) Locals(key string, value ...any) any {
+	if len(value) > 0 {
+		s.ctx.Set(key, value[0])
+		return value[0]
+	}
+	return s.ctx.Get(key)
+}
+
+func (s *serverCtx) Context() context.Context {
+	return s.ctx.Request().Context()
+}
+
+func (s *serverCtx) SetContext(ctx context.Context) {
+	s.ctx.SetRequest(s.ctx.Request().WithContext(ctx))
+}
+
+func (s *serverCtx) SetLimits(l cenery.Limits) {
+	s.state.setLimits(requestLimits(s.ctx).Merge(l))
+}
+
 func (s *serverCtx) Request() cenery.Request {
 	return NewRequest(s.ctx.Request())
 }
@@ -143,8 +328,75 @@ func (s *serverCtx) Response() cenery.Response {
 }
 
 func (s *serverCtx) Next() error {
-	if s.next != nil {
-		return s.next(s.ctx)
+	if s.next == nil {
+		return nil
+	}
+	seq := s.state.seqNum()
+	if err := s.next(s.ctx); err != nil {
+		return err
+	}
+	return s.state.since(seq)
+}
+
+// NextTimeout runs the rest of the chain on its own goroutine, writing into
+// a buffer. If ctx is done first, the buffer is discarded and the error
+// response rendered by the caller is sent in its place. The request is not
+// released until the chain returns, since echo pools its contexts.
+func (s *serverCtx) NextTimeout(ctx context.Context) error {
+	if s.next == nil {
+		return nil
+	}
+
+	seq := s.state.seqNum()
+	req := s.ctx.Request().WithContext(ctx)
+	s.ctx.SetRequest(req)
+	res := s.ctx.Response()
+	w := res.Writer
+	tw := newTimeoutWriter(w)
+	res.Writer = tw
+
+	ec := s.ctx
+	next := s.next
+	s.state.detach()
+	var nextErr error
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				tw.panicVal = p
+			}
+			close(done)
+		}()
+		nextErr = next(ec)
+	}()
+
+	select {
+	case <-done:
+		res.Writer = w
+		if tw.panicVal != nil {
+			panic(tw.panicVal)
+		}
+		tw.writeTo(w)
+		if nextErr != nil {
+			return nextErr
+		}
+		return s.state.since(seq)
+	case <-ctx.Done():
+		tw.discard()
+		buf := newTimeoutWriter(w)
+		s.pending = &timeoutResponse{w: w, buf: buf, done: done, res: res}
+		s.ctx = s.ctx.Echo().NewContext(req, buf)
+		s.resp = NewResponse(s.ctx.Response())
+		return ctx.Err()
+	}
+}
+
+// release finishes the response of a handler that returned err.
+func (s *serverCtx) release() {
+	if s.pending != nil {
+		s.pending.finish()
+	}
+	if s.err != nil {
+		s.state.setError(s.err)
 	}
-	return nil
 }
@@ -2,14 +2,20 @@ package echo
 
 import (
 	"github.com/dreamph/cenery"
+	gojson "github.com/goccy/go-json"
 	"github.com/labstack/echo/v4"
 	echomiddleware "github.com/labstack/echo/v4/middleware"
 )
 
-func NewApp() cenery.App {
+// goJSON is the JSON codec of apps made with NewApp unless they pick
+// another with cenery.WithJSONCodec.
+var goJSON = cenery.NewJSONCodec(gojson.Marshal, gojson.Unmarshal, gojson.NewEncoder, gojson.NewDecoder)
+
+func NewApp(opts ...cenery.Option) cenery.App {
+	opts = append([]cenery.Option{cenery.WithJSONCodec(goJSON)}, opts...)
+	cfg := cenery.NewConfig(opts...)
 	echoApp := echo.New()
-	echoApp.JSONSerializer = fastJSONSerializer{}
-	echoApp.Binder = &fastBinder{}
+	echoApp.JSONSerializer = jsonSerializer{json: cfg.JSONCodec()}
 	echoApp.Use(echomiddleware.Recover())
-	return New(echoApp)
+	return New(echoApp, opts...)
 }
@@ -7,6 +7,7 @@ replace github.com/dreamph/cenery => ../../
 require (
 	github.com/dreamph/cenery v1.0.1
 	github.com/goccy/go-json v0.10.5
+	github.com/gorilla/websocket v1.5.3
 	github.com/labstack/echo/v4 v4.14.0
 )
 
@@ -1,80 +1,32 @@
 package echo
 
 import (
-	"bytes"
-	"io"
 	"net/http"
-	"strings"
 
-	"github.com/goccy/go-json"
+	"github.com/dreamph/cenery"
 	"github.com/labstack/echo/v4"
 )
 
-// fastJSONSerializer uses goccy/go-json for faster encode/decode.
-type fastJSONSerializer struct{}
+// jsonSerializer makes echo's own c.JSON and c.Bind use the app's
+// cenery.JSONCodec.
+type jsonSerializer struct {
+	json cenery.JSONCodec
+}
 
-func (fastJSONSerializer) Serialize(c echo.Context, i any, indent string) error {
-	var (
-		b   []byte
-		err error
-	)
+func (s jsonSerializer) Serialize(c echo.Context, i any, indent string) error {
+	enc := s.json.NewEncoder(c.Response())
 	if indent != "" {
-		b, err = json.MarshalIndent(i, "", indent)
-	} else {
-		b, err = json.Marshal(i)
-	}
-	if err != nil {
-		return err
+		if ie, ok := enc.(interface{ SetIndent(prefix, indent string) }); ok {
+			ie.SetIndent("", indent)
+		}
 	}
-	_, err = c.Response().Writer.Write(b)
-	return err
+	return enc.Encode(i)
 }
 
-func (fastJSONSerializer) Deserialize(c echo.Context, i any) error {
-	req := c.Request()
-	if req.Body == nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Request body can't be empty")
-	}
-
-	data, err := io.ReadAll(req.Body)
+func (s jsonSerializer) Deserialize(c echo.Context, i any) error {
+	err := s.json.NewDecoder(c.Request().Body).Decode(i)
 	if err != nil {
-		return err
-	}
-	if len(data) == 0 {
-		return nil
-	}
-
-	req.Body = io.NopCloser(bytes.NewBuffer(data))
-	return json.Unmarshal(data, i)
-}
-
-// fastBinder replaces JSON decoding with goccy/go-json and falls back to the default binder.
-type fastBinder struct {
-	echo.DefaultBinder
-}
-
-func (b *fastBinder) Bind(i interface{}, c echo.Context) error {
-	req := c.Request()
-	ct := req.Header.Get(echo.HeaderContentType)
-	if strings.HasPrefix(ct, echo.MIMEApplicationJSON) {
-		if req.Body == nil {
-			return echo.NewHTTPError(http.StatusBadRequest, "Request body can't be empty")
-		}
-
-		data, err := io.ReadAll(req.Body)
-		if err != nil {
-			return err
-		}
-		if len(data) == 0 {
-			return nil
-		}
-
-		req.Body = io.NopCloser(bytes.NewBuffer(data))
-		if err := json.Unmarshal(data, i); err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-		}
-		return nil
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error()).SetInternal(err)
 	}
-
-	return b.DefaultBinder.Bind(i, c)
+	return nil
 }
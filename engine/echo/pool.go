@@ -0,0 +1,63 @@
+package echo
+
+import (
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ctxPool holds the serverCtxs shared along a request's handler chain,
+// under the lifetime rules of cenery.Ctx.
+var ctxPool = sync.Pool{
+	New: func() any {
+		return new(serverCtx)
+	},
+}
+
+// acquireCtx returns the serverCtx for a handler of state's request, with
+// the fields of the handler it is reused from, if any.
+func acquireCtx(ctx echo.Context, next echo.HandlerFunc, state *requestState) (*serverCtx, serverCtx) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	if state.detached {
+		s := ctxPool.Get().(*serverCtx)
+		s.reset(ctx, next, state)
+		return s, serverCtx{}
+	}
+	if s := state.current; s != nil {
+		caller := *s
+		s.reset(ctx, next, state)
+		return s, caller
+	}
+	s := ctxPool.Get().(*serverCtx)
+	s.reset(ctx, next, state)
+	state.current = s
+	return s, serverCtx{}
+}
+
+// releaseCtx hands s back to the handler it was reused from, or returns it
+// to the pool.
+func releaseCtx(s *serverCtx, caller serverCtx) {
+	if caller.state != nil {
+		*s = caller
+		return
+	}
+	state := s.state
+	state.mu.Lock()
+	if !state.detached {
+		state.current = nil
+	}
+	state.mu.Unlock()
+	*s = serverCtx{}
+	ctxPool.Put(s)
+}
+
+// detach stops sharing serverCtxs before NextTimeout runs the rest of the
+// chain on another goroutine, which may outlive the handler that called it.
+// From then on every handler takes its own serverCtx from the pool and
+// current is no longer written, as both goroutines read the state.
+func (st *requestState) detach() {
+	st.mu.Lock()
+	st.detached = true
+	st.mu.Unlock()
+}
@@ -16,6 +16,25 @@ func NewRequest(req *http.Request) cenery.Request {
 	return &request{req: req}
 }
 
+func (h *request) Method() string {
+	return h.req.Method
+}
+
+func (h *request) Host() string {
+	return h.req.Host
+}
+
+func (h *request) Scheme() string {
+	if h.req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+func (h *request) Path() string {
+	return h.req.URL.Path
+}
+
 func (h *request) Body() []byte {
 	if h.req.Body != nil {
 		data, err := io.ReadAll(h.req.Body)
@@ -63,20 +63,23 @@ func EnableResponseCapture(enabled bool) {
 }
 
 func NewResponse(resp *echo.Response) cenery.Response {
-	var resBodyBuffer *bytes.Buffer
+	h := &response{}
+	h.init(resp)
+	return h
+}
+
+// init points h at resp, wrapping its writer to fill the captured body when
+// capture is enabled.
+func (h *response) init(resp *echo.Response) {
+	h.resBody = nil
 	if captureResponseBody.Load() {
-		resBodyBuffer = &bytes.Buffer{}
-		writer := &responseBodyWriter{
-			Writer:         io.MultiWriter(resp.Writer, resBodyBuffer),
+		h.resBody = &bytes.Buffer{}
+		resp.Writer = &responseBodyWriter{
+			Writer:         io.MultiWriter(resp.Writer, h.resBody),
 			ResponseWriter: resp.Writer,
 		}
-		resp.Writer = writer
-	}
-
-	return &response{
-		resp:    resp,
-		resBody: resBodyBuffer,
 	}
+	h.resp = resp
 }
 
 func (h *response) Body() []byte {
@@ -2,6 +2,11 @@ package echo
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
 
 	"github.com/dreamph/cenery"
 	"github.com/labstack/echo/v4"
@@ -9,10 +14,11 @@ import (
 
 type app struct {
 	server *echo.Echo
+	config *cenery.Config
 }
 
-func New(server *echo.Echo) cenery.App {
-	return &app{server: server}
+func New(server *echo.Echo, opts ...cenery.Option) cenery.App {
+	return &app{server: server, config: cenery.NewConfig(opts...)}
 }
 
 func (a *app) Name() string {
@@ -23,6 +29,12 @@ func (a *app) Listen(addr string) error {
 	return a.server.Start(addr)
 }
 
+// ServeHTTP serves r with the app, so it can be mounted in another
+// net/http server or driven by httptest and cenerytest.
+func (a *app) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.server.ServeHTTP(w, r)
+}
+
 func (a *app) Use(handlers ...cenery.Handler) {
 	for _, handler := range handlers {
 		h := handler // Copy variable to avoid closure capture bug
@@ -34,49 +46,73 @@ func (a *app) Use(handlers ...cenery.Handler) {
 	}
 }
 
-func (a *app) Get(path string, handlers ...cenery.Handler) {
+func (a *app) Get(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.GET(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodGet, path)
 }
 
-func (a *app) Post(path string, handlers ...cenery.Handler) {
+func (a *app) Post(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.POST(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodPost, path)
 }
 
-func (a *app) Put(path string, handlers ...cenery.Handler) {
+func (a *app) Put(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.PUT(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodPut, path)
 }
 
-func (a *app) Delete(path string, handlers ...cenery.Handler) {
+func (a *app) Delete(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.DELETE(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodDelete, path)
 }
 
-func (a *app) Head(path string, handlers ...cenery.Handler) {
+func (a *app) Head(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.HEAD(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodHead, path)
 }
 
-func (a *app) Options(path string, handlers ...cenery.Handler) {
+func (a *app) Options(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.OPTIONS(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodOptions, path)
 }
 
-func (a *app) Connect(path string, handlers ...cenery.Handler) {
+func (a *app) Connect(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.CONNECT(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodConnect, path)
 }
 
-func (a *app) Patch(path string, handlers ...cenery.Handler) {
+func (a *app) Patch(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.PATCH(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodPatch, path)
 }
 
-func (a *app) Trace(path string, handlers ...cenery.Handler) {
+func (a *app) Trace(path string, handlers ...cenery.Handler) *cenery.Route {
 	handler, middlewareHandlers := a.toHandlers(handlers)
 	a.server.TRACE(path, handler, middlewareHandlers...)
+	return a.config.Routes.Add(http.MethodTrace, path)
+}
+
+func (a *app) Static(prefix, root string, config ...cenery.StaticConfig) {
+	a.StaticFS(prefix, cenery.RootFS(root), config...)
+}
+
+func (a *app) StaticFS(prefix string, fsys fs.FS, config ...cenery.StaticConfig) {
+	handler := cenery.StaticHandler(prefix, fsys, config...)
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" {
+		a.Get(prefix, handler)
+		a.Head(prefix, handler)
+	}
+	a.Get(prefix+"/*", handler)
+	a.Head(prefix+"/*", handler)
 }
 
 func (a *app) Shutdown(ctx context.Context) error {
@@ -113,6 +149,28 @@ func (a *app) toHandlers(handlers []cenery.Handler) (func(c echo.Context) error,
 }
 
 func (a *app) processHandler(c echo.Context, handler cenery.Handler, next echo.HandlerFunc) error {
-	svc := NewServerCtx(c, next)
-	return handler(svc)
+	svc, caller := acquireCtx(c, next, getRequestState(c))
+	defer releaseCtx(svc, caller)
+	svc.state.init(a.config)
+	if err := handler(svc); err != nil {
+		a.handleError(svc, err)
+	}
+	svc.release()
+	return nil
+}
+
+// handleError passes err to the error handler unless a later handler has
+// already done so and the error is only being returned up the chain.
+func (a *app) handleError(s *serverCtx, err error) {
+	if s.pending == nil && s.state.handled(err) {
+		return
+	}
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		err = &cenery.Error{Code: he.Code, Message: fmt.Sprint(he.Message), Err: err}
+	}
+	s.err = err
+	if herr := a.config.ErrorHandler(s, err); herr != nil {
+		_ = s.ctx.String(http.StatusInternalServerError, herr.Error())
+	}
 }
@@ -0,0 +1,122 @@
+package echo
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dreamph/cenery"
+	"github.com/labstack/echo/v4"
+)
+
+const stateKey = "cenery.state"
+
+// requestState is shared by every handler serving one request. It is locked
+// because a timed-out chain keeps running next to the handlers above it.
+type requestState struct {
+	mu  sync.Mutex
+	err error // last error passed to the error handler
+	seq int   // bumped whenever err is set
+
+	limits      *cenery.Limits // nil until set by the app or a route
+	config      *cenery.Config // app serving the request, nil until set
+	bodyLimited bool           // request body already wrapped
+
+	current  *serverCtx // shared by the handlers of the chain, see acquireCtx
+	detached bool       // the chain went on in another goroutine
+}
+
+func getRequestState(c echo.Context) *requestState {
+	if state, ok := c.Get(stateKey).(*requestState); ok {
+		return state
+	}
+	state := &requestState{}
+	c.Set(stateKey, state)
+	return state
+}
+
+func (st *requestState) setError(err error) {
+	st.mu.Lock()
+	st.err = err
+	st.seq++
+	st.mu.Unlock()
+}
+
+// handled reports whether err, or an error it wraps, was already handled.
+func (st *requestState) handled(err error) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.err != nil && errors.Is(err, st.err)
+}
+
+func (st *requestState) seqNum() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.seq
+}
+
+// since returns the handled error if one was set after seq.
+func (st *requestState) since(seq int) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.seq == seq {
+		return nil
+	}
+	return st.err
+}
+
+// init records the config of the app serving the request, unless an outer
+// app already did.
+func (st *requestState) init(config *cenery.Config) {
+	st.mu.Lock()
+	if st.config == nil {
+		st.config = config
+	}
+	if st.limits == nil {
+		l := config.Limits
+		st.limits = &l
+	}
+	st.mu.Unlock()
+}
+
+func (st *requestState) appConfig() *cenery.Config {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.config
+}
+
+func (st *requestState) setLimits(l cenery.Limits) {
+	st.mu.Lock()
+	st.limits = &l
+	st.mu.Unlock()
+}
+
+// requestLimits returns the limits of the app serving c, or the defaults
+// when c is not served through one.
+func requestLimits(c echo.Context) cenery.Limits {
+	if state, ok := c.Get(stateKey).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if state.limits != nil {
+			return *state.limits
+		}
+	}
+	return cenery.DefaultLimits()
+}
+
+// limitBody caps the request body at the current BodyLimit. The body is only
+// wrapped once per request, so a limit set after it was first read has no
+// effect.
+func limitBody(c echo.Context) {
+	state, ok := c.Get(stateKey).(*requestState)
+	if ok {
+		state.mu.Lock()
+		limited := state.bodyLimited
+		state.bodyLimited = true
+		state.mu.Unlock()
+		if limited {
+			return
+		}
+	}
+	r := c.Request()
+	r.Body = requestLimits(c).LimitBody(r.Body, r.ContentLength)
+}
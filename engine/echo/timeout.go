@@ -0,0 +1,112 @@
+package echo
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+)
+
+// timeoutWriter buffers a response so it can be dropped if the handler
+// writing it runs past its deadline.
+type timeoutWriter struct {
+	mu        sync.Mutex
+	header    http.Header
+	status    int
+	body      bytes.Buffer
+	discarded bool
+	panicVal  any
+}
+
+func newTimeoutWriter(w http.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{header: w.Header().Clone()}
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.discarded {
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.discarded || tw.status != 0 {
+		return
+	}
+	tw.status = code
+}
+
+// Flush is a no-op: the response is only sent once the handler returns.
+func (tw *timeoutWriter) Flush() {}
+
+func (tw *timeoutWriter) discard() {
+	tw.mu.Lock()
+	tw.discarded = true
+	tw.mu.Unlock()
+}
+
+// writeTo copies the buffered response to w with an explicit Content-Length,
+// so the client sees a complete response even while the handler is running.
+func (tw *timeoutWriter) writeTo(w http.ResponseWriter) {
+	dst := w.Header()
+	for k := range dst {
+		if _, ok := tw.header[k]; !ok {
+			delete(dst, k)
+		}
+	}
+	for k, vv := range tw.header {
+		dst[k] = vv
+	}
+
+	status := tw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if dst.Get("Content-Length") == "" && bodyAllowed(status) {
+		dst.Set("Content-Length", strconv.Itoa(tw.body.Len()))
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(tw.body.Bytes())
+}
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// timeoutResponse is the error response of a request whose handler timed out.
+type timeoutResponse struct {
+	w    http.ResponseWriter
+	buf  *timeoutWriter
+	done chan struct{}
+	res  *echo.Response
+}
+
+// finish sends the error response and waits for the timed-out handler.
+func (t *timeoutResponse) finish() {
+	if t.buf.status == 0 {
+		t.buf.status = http.StatusServiceUnavailable
+	}
+	t.buf.writeTo(t.w)
+	if flusher, ok := t.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+	<-t.done
+
+	t.res.Writer = t.w
+	t.res.Status = t.buf.status
+	t.res.Size = int64(t.buf.body.Len())
+	t.res.Committed = true
+}
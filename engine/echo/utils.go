@@ -2,160 +2,133 @@ package echo
 
 import (
 	"errors"
-	"io"
+	"mime"
 	"mime/multipart"
+	"net/http"
 
 	"github.com/dreamph/cenery"
 	"github.com/labstack/echo/v4"
 )
 
 func FormFile(c echo.Context, fileKey string) *cenery.FileData {
-	file, _ := formFile(c, fileKey, false)
+	file, _ := FormFileE(c, fileKey)
 	return file
 }
 
-func formFile(c echo.Context, fileKey string, errorIfNotFound bool) (*cenery.FileData, error) {
-	form, err := c.MultipartForm()
+// FormFileE reads the first file uploaded as fileKey into memory.
+func FormFileE(c echo.Context, fileKey string) (*cenery.FileData, error) {
+	headers, err := formFileHeaders(c, fileKey)
 	if err != nil {
 		return nil, err
 	}
-
-	files, ok := form.File[fileKey]
-	if !ok {
-		if errorIfNotFound {
-			return nil, errors.New("fileKey not found:" + fileKey)
-		}
-		return nil, nil
-	}
-	file := files[0]
-	contentType := "application/octet-stream" // default
-	if ct := file.Header.Get("Content-Type"); ct != "" {
-		contentType = ct
-	}
-	return &cenery.FileData{
-		FileData:        fileHeaderToBytes(file),
-		FileSize:        file.Size,
-		FileContentType: contentType,
-		FileName:        file.Filename,
-	}, nil
+	return cenery.ReadFormFile(headers[0])
 }
 
 func FormFiles(c echo.Context, fileKey string) *[]cenery.FileData {
-	files, _ := formFiles(c, fileKey, false)
-	return files
-}
-
-func formFiles(c echo.Context, fileKey string, errorIfNotFound bool) (*[]cenery.FileData, error) {
-	form, err := c.MultipartForm()
+	files, err := FormFilesE(c, fileKey)
 	if err != nil {
-		return nil, err
-	}
-
-	files, ok := form.File[fileKey]
-	if !ok {
-		if errorIfNotFound {
-			return nil, errors.New("fileKey not found:" + fileKey)
-		}
-		return nil, nil
-	}
-
-	uploadFiles := make([]cenery.FileData, len(files))
-	for i, file := range files {
-		contentType := "application/octet-stream" // default
-		if ct := file.Header.Get("Content-Type"); ct != "" {
-			contentType = ct
-		}
-		uploadFiles[i] = cenery.FileData{
-			FileData:        fileHeaderToBytes(file),
-			FileSize:        file.Size,
-			FileContentType: contentType,
-			FileName:        file.Filename,
-		}
+		return nil
 	}
-	return &uploadFiles, nil
+	return &files
 }
 
-func fileHeaderToBytes(h *multipart.FileHeader) []byte {
-	file, err := h.Open()
-	if err != nil {
-		return nil
-	}
-	defer func(file multipart.File) {
-		_ = file.Close()
-	}(file)
-	data, err := io.ReadAll(file)
+// FormFilesE reads every file uploaded as fileKey into memory.
+func FormFilesE(c echo.Context, fileKey string) ([]cenery.FileData, error) {
+	form, err := multipartForm(c)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return data
+	return cenery.FormFileData(form, fileKey)
 }
 
-// FormFileStream returns a streaming file upload (no memory allocation)
+// FormFileStream opens the first file uploaded as fileKey without reading
+// it into memory. Close the stream once done.
 func FormFileStream(c echo.Context, fileKey string) (*cenery.FileStream, error) {
-	form, err := c.MultipartForm()
+	headers, err := formFileHeaders(c, fileKey)
 	if err != nil {
 		return nil, err
 	}
+	return cenery.OpenFormFile(headers[0])
+}
 
-	files, ok := form.File[fileKey]
-	if !ok {
-		return nil, errors.New("fileKey not found:" + fileKey)
+// FormFilesStream opens every file uploaded as fileKey without reading
+// them into memory.
+func FormFilesStream(c echo.Context, fileKey string) ([]*cenery.FileStream, error) {
+	form, err := multipartForm(c)
+	if err != nil {
+		return nil, err
 	}
+	return cenery.FormFileStreams(form, fileKey)
+}
 
-	fileHeader := files[0]
-	file, err := fileHeader.Open()
+func formFileHeaders(c echo.Context, fileKey string) ([]*multipart.FileHeader, error) {
+	form, err := multipartForm(c)
 	if err != nil {
 		return nil, err
 	}
+	return cenery.FormFileHeaders(form, fileKey)
+}
 
-	contentType := "application/octet-stream" // default
-	if ct := fileHeader.Header.Get("Content-Type"); ct != "" {
-		contentType = ct
+func multipartForm(c echo.Context) (*multipart.Form, error) {
+	limits := requestLimits(c)
+	r := c.Request()
+	if r.MultipartForm == nil {
+		limitBody(c)
+		if err := cenery.ParseMultipartForm(r, limits); err != nil {
+			if errors.Is(err, cenery.ErrBodyTooLarge) {
+				return nil, cenery.RequestError(cenery.ErrBodyTooLarge)
+			}
+			if errors.Is(err, http.ErrNotMultipart) {
+				return nil, cenery.RequestError(cenery.ErrNotMultipart)
+			}
+			return nil, err
+		}
 	}
-
-	return &cenery.FileStream{
-		File:            file,
-		FileName:        fileHeader.Filename,
-		FileSize:        fileHeader.Size,
-		FileContentType: contentType,
-	}, nil
+	if err := limits.CheckForm(r.MultipartForm); err != nil {
+		return nil, err
+	}
+	return r.MultipartForm, nil
 }
 
-// FormFilesStream returns multiple streaming file uploads (no memory allocation)
-func FormFilesStream(c echo.Context, fileKey string) ([]*cenery.FileStream, error) {
-	form, err := c.MultipartForm()
+// multipartReader reads the multipart body of r part by part, within the
+// request's limits.
+func multipartReader(c echo.Context) (*cenery.MultipartReader, error) {
+	r := c.Request()
+	limitBody(c)
+	mr, err := r.MultipartReader()
 	if err != nil {
+		if errors.Is(err, http.ErrNotMultipart) {
+			return nil, cenery.RequestError(cenery.ErrNotMultipart)
+		}
 		return nil, err
 	}
+	return cenery.NewMultipartReader(mr, requestLimits(c)), nil
+}
 
-	fileHeaders, ok := form.File[fileKey]
-	if !ok {
-		return nil, errors.New("fileKey not found:" + fileKey)
-	}
-
-	streams := make([]*cenery.FileStream, 0, len(fileHeaders))
-	for _, fileHeader := range fileHeaders {
-		file, err := fileHeader.Open()
+// formValues returns the fields of a multipart/form-data or
+// application/x-www-form-urlencoded body.
+func formValues(c echo.Context) (map[string][]string, error) {
+	r := c.Request()
+	if isMultipart(r.Header.Get("Content-Type")) {
+		form, err := multipartForm(c)
 		if err != nil {
-			// Close previously opened files on error
-			for _, s := range streams {
-				_ = s.File.Close()
-			}
 			return nil, err
 		}
-
-		contentType := "application/octet-stream" // default
-		if ct := fileHeader.Header.Get("Content-Type"); ct != "" {
-			contentType = ct
+		return form.Value, nil
+	}
+	if r.PostForm == nil {
+		limitBody(c)
+		if err := r.ParseForm(); err != nil {
+			if errors.Is(err, cenery.ErrBodyTooLarge) {
+				return nil, cenery.RequestError(cenery.ErrBodyTooLarge)
+			}
+			return nil, err
 		}
-
-		streams = append(streams, &cenery.FileStream{
-			File:            file,
-			FileName:        fileHeader.Filename,
-			FileSize:        fileHeader.Size,
-			FileContentType: contentType,
-		})
 	}
+	return r.PostForm, nil
+}
 
-	return streams, nil
+func isMultipart(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "multipart/form-data"
 }
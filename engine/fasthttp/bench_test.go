@@ -0,0 +1,121 @@
+package fasthttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/dreamph/cenery"
+	"github.com/dreamph/cenery/cenerybench"
+	"github.com/fasthttp/router"
+	"github.com/valyala/fasthttp"
+)
+
+var benchEngine = cenerybench.Engine{
+	Cenery: func(setup func(app cenery.App)) cenerybench.Serve {
+		r := router.New()
+		setup(New(r))
+		return serveRequestCtx(r.Handler)
+	},
+	Native: nativeScenario,
+}
+
+// serveRequestCtx serves requests with handler and a reused
+// fasthttp.RequestCtx, as the fasthttp server does.
+func serveRequestCtx(handler fasthttp.RequestHandler) cenerybench.Serve {
+	var ctx fasthttp.RequestCtx
+	ctx.Init(&fasthttp.Request{}, nil, nil)
+	return func(r cenerybench.Request) (int, []byte) {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod(r.Method)
+		ctx.Request.SetRequestURI(r.Path)
+		if r.ContentType != "" {
+			ctx.Request.Header.SetContentType(r.ContentType)
+		}
+		ctx.Request.SetBody(r.Body)
+		handler(&ctx)
+		return ctx.Response.StatusCode(), ctx.Response.Body()
+	}
+}
+
+// nativeScenario implements s with fasthttp and its router directly. The
+// router has no middleware, so the handler is wrapped s.Middleware times.
+func nativeScenario(s cenerybench.Scenario) cenerybench.Serve {
+	r := router.New()
+	handle := func(method, path string, handler fasthttp.RequestHandler) {
+		for range s.Middleware {
+			next := handler
+			handler = func(ctx *fasthttp.RequestCtx) {
+				next(ctx)
+			}
+		}
+		r.Handle(method, path, handler)
+	}
+	switch s.Name {
+	case "static":
+		handle(fasthttp.MethodGet, "/hello", func(ctx *fasthttp.RequestCtx) {
+			ctx.SetContentType("text/plain; charset=utf-8")
+			ctx.SetBodyString("Hello, World!")
+		})
+	case "param":
+		handle(fasthttp.MethodGet, "/users/{id}/posts/{post}", func(ctx *fasthttp.RequestCtx) {
+			ctx.SetContentType("text/plain; charset=utf-8")
+			ctx.SetBodyString(ctx.UserValue("id").(string) + "/" + ctx.UserValue("post").(string))
+		})
+	case "json-encode":
+		handle(fasthttp.MethodGet, "/user", func(ctx *fasthttp.RequestCtx) {
+			ctx.SetContentType("application/json")
+			json.NewEncoder(ctx).Encode(cenerybench.SampleUser)
+		})
+	case "json-decode":
+		handle(fasthttp.MethodPost, "/user", func(ctx *fasthttp.RequestCtx) {
+			var u cenerybench.User
+			if err := json.Unmarshal(ctx.PostBody(), &u); err != nil {
+				ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+				return
+			}
+			ctx.SetBodyString(u.Name)
+		})
+	case "multipart":
+		handle(fasthttp.MethodPost, "/upload", func(ctx *fasthttp.RequestCtx) {
+			header, err := ctx.FormFile("file")
+			if err != nil {
+				ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+				return
+			}
+			defer file.Close()
+			data, err := io.ReadAll(file)
+			if err != nil {
+				ctx.Error(err.Error(), fasthttp.StatusBadRequest)
+				return
+			}
+			ctx.SetBodyString(strconv.Itoa(len(data)))
+		})
+	case "stream":
+		handle(fasthttp.MethodGet, "/stream", func(ctx *fasthttp.RequestCtx) {
+			ctx.SetContentType("application/octet-stream")
+			ctx.SetBodyStream(bytes.NewReader(cenerybench.StreamData), -1)
+		})
+	default:
+		handle(fasthttp.MethodGet, "/chain", func(ctx *fasthttp.RequestCtx) {
+			ctx.SetBodyString("ok")
+		})
+	}
+	return serveRequestCtx(r.Handler)
+}
+
+func TestBenchSuite(t *testing.T) {
+	cenerybench.Verify(t, benchEngine)
+}
+
+func BenchmarkSuite(b *testing.B) {
+	cenerybench.Run(b, benchEngine)
+}
@@ -1,14 +1,16 @@
 package fasthttp
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"fmt"
 	"io"
-	"strings"
+	"io/fs"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/dreamph/cenery"
 	"github.com/valyala/fasthttp"
@@ -17,16 +19,33 @@ import (
 type serverCtx struct {
 	ctx  *fasthttp.RequestCtx
 	next fasthttp.RequestHandler
-	resp cenery.Response
+	// app, handlers and index locate the handler in the app's chain, which
+	// Next continues without allocating a next handler per handler.
+	app      *app
+	handlers []cenery.Handler
+	index    int
+	resp     cenery.Response
+	res      response // backs resp, so a pooled serverCtx allocates nothing
+	state    *requestState
+	timeout  *fasthttp.RequestCtx
+	err      error
 }
 
 func NewServerCtx(ctx *fasthttp.RequestCtx, next fasthttp.RequestHandler) cenery.Ctx {
-	resp := NewResponse(ctx)
-	return &serverCtx{
-		ctx:  ctx,
-		next: next,
-		resp: resp,
-	}
+	return newServerCtx(ctx, next)
+}
+
+func newServerCtx(ctx *fasthttp.RequestCtx, next fasthttp.RequestHandler) *serverCtx {
+	s := &serverCtx{}
+	s.reset(ctx, next, getRequestState(ctx))
+	return s
+}
+
+// reset prepares s for a handler, clearing what the previous one left.
+func (s *serverCtx) reset(ctx *fasthttp.RequestCtx, next fasthttp.RequestHandler, state *requestState) {
+	*s = serverCtx{ctx: ctx, next: next, state: state}
+	s.res.init(ctx)
+	s.resp = &s.res
 }
 
 func (s *serverCtx) Params(key string, defaultValue ...string) string {
@@ -60,17 +79,15 @@ func (s *serverCtx) QueryParam(key string, defaultValue ...string) string {
 	return val
 }
 
-func (s *serverCtx) BodyParser(out any) error {
-	ct := string(s.ctx.Request.Header.ContentType())
-	if !strings.HasPrefix(ct, "application/json") && ct != "" {
-		return errors.New("unsupported content type")
-	}
+func (s *serverCtx) IP() string {
+	return s.ctx.RemoteIP().String()
+}
 
-	data := s.ctx.PostBody()
-	if len(data) == 0 {
-		return nil
+func (s *serverCtx) BodyParser(out any) error {
+	if err := checkBodySize(&s.ctx.Request, requestLimits(s.ctx)); err != nil {
+		return err
 	}
-	return jsonUnmarshal(data, out)
+	return cenery.DecodeBody(s, s.state.appConfig(), s.ctx.PostBody(), out)
 }
 
 func (s *serverCtx) BodyParserStream(out any) error {
@@ -78,18 +95,20 @@ func (s *serverCtx) BodyParserStream(out any) error {
 	if body == nil {
 		return errors.New("request body can't be empty")
 	}
-	dec := json.NewDecoder(body)
-	if err := dec.Decode(out); err != nil {
-		if errors.Is(err, io.EOF) {
-			return nil
-		}
-		return err
+	return cenery.DecodeJSON(s, s.state.appConfig(), body, out)
+}
+
+func (s *serverCtx) BodyParserNDJSON(out any, fn func() error) error {
+	body := s.BodyStream()
+	if body == nil {
+		return errors.New("request body can't be empty")
 	}
-	return nil
+	return cenery.DecodeNDJSON(s, s.state.appConfig(), body, out, fn)
 }
 
 func (s *serverCtx) BodyStream() io.ReadCloser {
-	return NewRequest(&s.ctx.Request).BodyStream()
+	body := NewRequest(&s.ctx.Request).BodyStream()
+	return requestLimits(s.ctx).LimitBody(body, int64(s.ctx.Request.Header.ContentLength()))
 }
 
 func (s *serverCtx) FormFile(fileKey string) *cenery.FileData {
@@ -100,6 +119,14 @@ func (s *serverCtx) FormFiles(fileKey string) *[]cenery.FileData {
 	return FormFiles(s.ctx, fileKey)
 }
 
+func (s *serverCtx) FormFileE(fileKey string) (*cenery.FileData, error) {
+	return FormFileE(s.ctx, fileKey)
+}
+
+func (s *serverCtx) FormFilesE(fileKey string) ([]cenery.FileData, error) {
+	return FormFilesE(s.ctx, fileKey)
+}
+
 func (s *serverCtx) FormFileStream(fileKey string) (*cenery.FileStream, error) {
 	return FormFileStream(s.ctx, fileKey)
 }
@@ -108,6 +135,36 @@ func (s *serverCtx) FormFilesStream(fileKey string) ([]*cenery.FileStream, error
 	return FormFilesStream(s.ctx, fileKey)
 }
 
+func (s *serverCtx) FormValue(key string, defaultValue ...string) string {
+	var val string
+	if values, _ := formValues(s.ctx); len(values[key]) > 0 {
+		val = values[key][0]
+	}
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (s *serverCtx) FormValues(key string) []string {
+	values, _ := formValues(s.ctx)
+	return values[key]
+}
+
+func (s *serverCtx) MultipartForm() (*cenery.MultipartForm, error) {
+	form, err := multipartForm(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cenery.MultipartForm{Value: form.Value, File: form.File}, nil
+}
+
+func (s *serverCtx) MultipartReader() (*cenery.MultipartReader, error) {
+	return multipartReader(s.ctx)
+}
+
 var enableSendBufferPooling atomic.Bool
 
 // EnableSendBufferPooling toggles pooling for Send() to reuse buffers
@@ -148,7 +205,7 @@ func (s *serverCtx) Send(status int, data []byte) error {
 }
 
 func (s *serverCtx) SendJSON(status int, data any) error {
-	payload, err := jsonMarshal(data)
+	payload, err := s.state.appConfig().JSONCodec().Marshal(data)
 	if err != nil {
 		return err
 	}
@@ -158,13 +215,104 @@ func (s *serverCtx) SendJSON(status int, data any) error {
 	return nil
 }
 
+func (s *serverCtx) Negotiate(status int, data any) error {
+	return cenery.Negotiate(s, s.state.appConfig(), status, data)
+}
+
+func (s *serverCtx) SendJSONStream(status int, items any, format ...cenery.JSONStreamFormat) error {
+	write, err := cenery.JSONStream(s, s.state.appConfig(), items, format...)
+	if err != nil {
+		return err
+	}
+	s.ctx.SetStatusCode(status)
+	ctx := s.state.streamContext(s.ctx)
+	s.ctx.SetBodyStreamWriter(func(bw *bufio.Writer) {
+		_ = write(ctx(), bw, bw.Flush)
+	})
+	return nil
+}
+
 func (s *serverCtx) SendStream(status int, contentType string, reader io.Reader) error {
+	return s.SendStreamSized(status, contentType, reader, cenery.ReaderSize(reader))
+}
+
+// SendStreamSized hands reader to fasthttp, which sends an *os.File of
+// known size with sendfile.
+func (s *serverCtx) SendStreamSized(status int, contentType string, reader io.Reader, size int64) error {
 	s.ctx.Response.Header.Set("Content-Type", contentType)
 	s.ctx.SetStatusCode(status)
-	s.ctx.SetBodyStream(reader, -1)
+	if size < 0 {
+		size = -1
+	}
+	s.ctx.SetBodyStream(reader, int(size))
 	return nil
 }
 
+func (s *serverCtx) SendFile(path string) error {
+	return cenery.SendFile(s, path)
+}
+
+func (s *serverCtx) SendFileFS(fsys fs.FS, name string) error {
+	return cenery.SendFileFS(s, fsys, name)
+}
+
+func (s *serverCtx) Download(path, filename string) error {
+	return cenery.Download(s, path, filename)
+}
+
+func (s *serverCtx) SaveFile(file *cenery.FileStream, path string) error {
+	return cenery.SaveFile(s, file, path)
+}
+
+func (s *serverCtx) Redirect(status int, location string) error {
+	return cenery.Redirect(s, s.state.appConfig(), status, location)
+}
+
+func (s *serverCtx) RedirectToRoute(name string, params map[string]string) error {
+	return cenery.RedirectToRoute(s, s.state.appConfig(), name, params)
+}
+
+func (s *serverCtx) RedirectBack(fallback string) error {
+	return cenery.RedirectBack(s, s.state.appConfig(), fallback)
+}
+
+// SSE streams from fasthttp's body stream writer, which outlives the
+// handler, so the request context is looked up through the request state.
+func (s *serverCtx) SSE(fn func(w cenery.EventWriter) error) error {
+	cenery.SetEventStreamHeaders(s.resp)
+	s.ctx.SetStatusCode(fasthttp.StatusOK)
+	ctx := s.state.streamContext(s.ctx)
+	lastEventID := string(s.ctx.Request.Header.Peek("Last-Event-ID"))
+	s.ctx.SetBodyStreamWriter(func(bw *bufio.Writer) {
+		w, cancel := cenery.NewEventWriter(ctx(), bw, bw.Flush, lastEventID)
+		defer cancel()
+		_ = fn(w)
+	})
+	return nil
+}
+
+// Locals are kept apart from fasthttp's user values, which the router also
+// uses for path parameters.
+func (s *serverCtx) Locals(key string, value ...any) any {
+	if len(value) > 0 {
+		s.state.setLocal(key, value[0])
+		return value[0]
+	}
+	return s.state.local(key)
+}
+
+func (s *serverCtx) Context() context.Context {
+	return s.state.context(s.ctx)
+}
+
+func (s *serverCtx) SetContext(ctx context.Context) {
+	s.state.setContext(ctx)
+}
+
+func (s *serverCtx) SetLimits(l cenery.Limits) {
+	s.state.setLimits(requestLimits(s.ctx).Merge(l))
+}
+
 func (s *serverCtx) Request() cenery.Request {
 	return NewRequest(&s.ctx.Request)
 }
@@ -174,8 +322,87 @@ func (s *serverCtx) Response() cenery.Response {
 }
 
 func (s *serverCtx) Next() error {
-	if s.next != nil {
+	if s.next == nil && s.app == nil {
+		return nil
+	}
+	seq := s.state.seqNum()
+	if s.app != nil {
+		s.app.processHandlers(s.ctx, s.handlers, s.index+1)
+	} else {
 		s.next(s.ctx)
 	}
-	return nil
+	return s.state.since(seq)
+}
+
+// nextHandler returns the rest of the chain as a handler.
+func (s *serverCtx) nextHandler() fasthttp.RequestHandler {
+	if s.app == nil {
+		return s.next
+	}
+	a, handlers, index := s.app, s.handlers, s.index+1
+	return func(ctx *fasthttp.RequestCtx) {
+		a.processHandlers(ctx, handlers, index)
+	}
+}
+
+// NextTimeout runs the rest of the chain on its own goroutine. If ctx is
+// done first, the error response rendered by the caller is sent right away
+// through fasthttp's TimeoutErrorWithResponse, which abandons the request
+// context so the chain's later writes are ignored.
+func (s *serverCtx) NextTimeout(ctx context.Context) error {
+	if s.next == nil && s.app == nil {
+		return nil
+	}
+
+	// The error response is rendered on a separate context while the chain
+	// may still be using this one; it only needs the request headers.
+	errCtx := &fasthttp.RequestCtx{}
+	s.ctx.Request.Header.CopyTo(&errCtx.Request.Header)
+
+	seq := s.state.seqNum()
+	prev := s.state.swapContext(ctx)
+	rc := s.ctx
+	next := s.nextHandler()
+	s.state.detach()
+	var panicVal any
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			panicVal = recover()
+			close(done)
+		}()
+		next(rc)
+	}()
+
+	select {
+	case <-done:
+		// The timer of ctx may not have fired yet when the chain returns
+		// after the deadline; the request has timed out all the same.
+		if deadline, ok := ctx.Deadline(); panicVal != nil || !ok || time.Now().Before(deadline) {
+			// ctx is cancelled once the caller returns, so the chain's
+			// streams and the handlers above it go back to the previous
+			// context. A chain still running after the deadline keeps ctx.
+			s.state.setContext(prev)
+			if panicVal != nil {
+				panic(panicVal)
+			}
+			return s.state.since(seq)
+		}
+		<-ctx.Done()
+	case <-ctx.Done():
+	}
+	s.timeout = s.ctx
+	s.ctx = errCtx
+	s.resp = NewResponse(errCtx)
+	return ctx.Err()
+}
+
+// release finishes the response of a handler that returned err.
+func (s *serverCtx) release() {
+	if s.timeout != nil {
+		s.timeout.TimeoutErrorWithResponse(&s.ctx.Response)
+	}
+	if s.err != nil {
+		s.state.setError(s.err)
+	}
 }
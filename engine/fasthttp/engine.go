@@ -5,7 +5,7 @@ import (
 	"github.com/fasthttp/router"
 )
 
-func NewApp() cenery.App {
+func NewApp(opts ...cenery.Option) cenery.App {
 	routerApp := router.New()
-	return New(routerApp)
+	return New(routerApp, opts...)
 }
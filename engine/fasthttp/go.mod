@@ -7,6 +7,7 @@ replace github.com/dreamph/cenery => ../../
 require (
 	github.com/dreamph/cenery v1.0.1
 	github.com/fasthttp/router v1.5.4
+	github.com/fasthttp/websocket v1.5.12
 	github.com/valyala/fasthttp v1.68.0
 )
 
@@ -15,4 +16,5 @@ require (
 	github.com/klauspost/compress v1.18.2 // indirect
 	github.com/savsgio/gotils v0.0.0-20250924091648-bce9a52d7761 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	golang.org/x/net v0.46.0 // indirect
 )
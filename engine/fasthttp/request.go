@@ -16,6 +16,22 @@ func NewRequest(req *fasthttp.Request) cenery.Request {
 	return &request{req: req}
 }
 
+func (h *request) Method() string {
+	return string(h.req.Header.Method())
+}
+
+func (h *request) Host() string {
+	return string(h.req.Host())
+}
+
+func (h *request) Scheme() string {
+	return string(h.req.URI().Scheme())
+}
+
+func (h *request) Path() string {
+	return string(h.req.URI().Path())
+}
+
 func (h *request) Body() []byte {
 	body := h.req.Body()
 	if len(body) == 0 {
@@ -31,6 +47,9 @@ func (h *request) SetBody(data []byte) {
 }
 
 func (h *request) BodyStream() io.ReadCloser {
+	if stream := h.req.BodyStream(); stream != nil {
+		return io.NopCloser(stream)
+	}
 	return io.NopCloser(bytes.NewReader(h.req.Body()))
 }
 
@@ -22,11 +22,18 @@ func EnableResponseCapture(enabled bool) {
 }
 
 func NewResponse(ctx *fasthttp.RequestCtx) cenery.Response {
-	resp := &response{resp: &ctx.Response}
+	h := &response{}
+	h.init(ctx)
+	return h
+}
+
+// init points h at ctx's response.
+func (h *response) init(ctx *fasthttp.RequestCtx) {
+	h.resp = &ctx.Response
+	h.resBody = nil
 	if captureResponseBody.Load() {
-		resp.resBody = &bytes.Buffer{}
+		h.resBody = &bytes.Buffer{}
 	}
-	return resp
 }
 
 func (h *response) Body() []byte {
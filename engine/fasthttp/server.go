@@ -2,6 +2,9 @@ package fasthttp
 
 import (
 	"context"
+	"io/fs"
+	"net"
+	"strings"
 
 	"github.com/dreamph/cenery"
 	"github.com/fasthttp/router"
@@ -12,10 +15,11 @@ type app struct {
 	router      *router.Router
 	server      *fasthttp.Server
 	middlewares []cenery.Handler
+	config      *cenery.Config
 }
 
-func New(routerApp *router.Router) cenery.App {
-	return &app{router: routerApp}
+func New(routerApp *router.Router, opts ...cenery.Option) cenery.App {
+	return &app{router: routerApp, config: cenery.NewConfig(opts...)}
 }
 
 func (a *app) Name() string {
@@ -23,50 +27,86 @@ func (a *app) Name() string {
 }
 
 func (a *app) Listen(addr string) error {
-	a.server = &fasthttp.Server{
-		Handler: a.router.Handler,
-	}
+	a.server = a.newServer()
 	return a.server.ListenAndServe(addr)
 }
 
+// Serve serves the connections accepted by ln, such as an in-memory
+// listener in tests.
+func (a *app) Serve(ln net.Listener) error {
+	a.server = a.newServer()
+	return a.server.Serve(ln)
+}
+
+func (a *app) newServer() *fasthttp.Server {
+	return &fasthttp.Server{
+		Handler:                      a.router.Handler,
+		MaxRequestBodySize:           serverBodyLimit(a.config.Limits),
+		StreamRequestBody:            a.config.StreamRequestBody,
+		DisablePreParseMultipartForm: true, // the route's Limits are not known yet
+	}
+}
+
 func (a *app) Use(handlers ...cenery.Handler) {
 	a.middlewares = append(a.middlewares, handlers...)
 }
 
-func (a *app) Get(path string, handlers ...cenery.Handler) {
+func (a *app) Get(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.GET(normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodGet, path)
 }
 
-func (a *app) Post(path string, handlers ...cenery.Handler) {
+func (a *app) Post(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.POST(normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodPost, path)
 }
 
-func (a *app) Put(path string, handlers ...cenery.Handler) {
+func (a *app) Put(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.PUT(normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodPut, path)
 }
 
-func (a *app) Delete(path string, handlers ...cenery.Handler) {
+func (a *app) Delete(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.DELETE(normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodDelete, path)
 }
 
-func (a *app) Head(path string, handlers ...cenery.Handler) {
+func (a *app) Head(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.HEAD(normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodHead, path)
 }
 
-func (a *app) Options(path string, handlers ...cenery.Handler) {
+func (a *app) Options(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.OPTIONS(normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodOptions, path)
 }
 
-func (a *app) Connect(path string, handlers ...cenery.Handler) {
+func (a *app) Connect(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.Handle(fasthttp.MethodConnect, normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodConnect, path)
 }
 
-func (a *app) Patch(path string, handlers ...cenery.Handler) {
+func (a *app) Patch(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.PATCH(normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodPatch, path)
 }
 
-func (a *app) Trace(path string, handlers ...cenery.Handler) {
+func (a *app) Trace(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.router.Handle(fasthttp.MethodTrace, normalizePath(path), a.wrapWithMiddlewares(handlers...))
+	return a.config.Routes.Add(fasthttp.MethodTrace, path)
+}
+
+func (a *app) Static(prefix, root string, config ...cenery.StaticConfig) {
+	a.StaticFS(prefix, cenery.RootFS(root), config...)
+}
+
+func (a *app) StaticFS(prefix string, fsys fs.FS, config ...cenery.StaticConfig) {
+	handler := cenery.StaticHandler(prefix, fsys, config...)
+	// The router redirects prefix to prefix/ itself; registering prefix too
+	// would make it redirect prefix/ back to prefix instead.
+	prefix = strings.TrimRight(prefix, "/")
+	a.Get(prefix+"/{filepath:*}", handler)
+	a.Head(prefix+"/{filepath:*}", handler)
 }
 
 func (a *app) Shutdown(ctx context.Context) error {
@@ -81,6 +121,9 @@ func (a *app) wrapWithMiddlewares(handlers ...cenery.Handler) fasthttp.RequestHa
 		all := make([]cenery.Handler, 0, len(a.middlewares)+len(handlers))
 		all = append(all, a.middlewares...)
 		all = append(all, handlers...)
+		state := getRequestState(ctx)
+		state.enter()
+		defer state.leave()
 		a.processHandlers(ctx, all, 0)
 	}
 }
@@ -90,14 +133,25 @@ func (a *app) processHandlers(ctx *fasthttp.RequestCtx, handlers []cenery.Handle
 		return
 	}
 
-	handler := handlers[index]
-	next := fasthttp.RequestHandler(func(c *fasthttp.RequestCtx) {
-		a.processHandlers(c, handlers, index+1)
-	})
+	svc, caller := acquireCtx(ctx, nil, getRequestState(ctx))
+	defer releaseCtx(svc, caller)
+	svc.app, svc.handlers, svc.index = a, handlers, index
+	svc.state.init(a.config)
+	if err := handlers[index](svc); err != nil {
+		a.handleError(svc, err)
+	}
+	svc.release()
+}
 
-	svc := NewServerCtx(ctx, next)
-	if err := handler(svc); err != nil {
-		ctx.Error(err.Error(), fasthttp.StatusInternalServerError)
+// handleError passes err to the error handler unless a later handler has
+// already done so and the error is only being returned up the chain.
+func (a *app) handleError(s *serverCtx, err error) {
+	if s.timeout == nil && s.state.handled(err) {
+		return
+	}
+	s.err = err
+	if herr := a.config.ErrorHandler(s, err); herr != nil {
+		s.ctx.Error(herr.Error(), fasthttp.StatusInternalServerError)
 	}
 }
 
@@ -0,0 +1,268 @@
+package fasthttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"mime/multipart"
+	"sync"
+	"time"
+
+	"github.com/dreamph/cenery"
+	"github.com/valyala/fasthttp"
+)
+
+type stateKey struct{}
+
+// requestState is shared by every handler serving one request. It is locked
+// because a timed-out chain keeps running next to the handlers above it.
+type requestState struct {
+	mu  sync.Mutex
+	ctx context.Context
+	err error // last error passed to the error handler
+	seq int   // bumped whenever err is set
+
+	locals map[string]any
+	limits *cenery.Limits  // nil until set by the app or a route
+	config *cenery.Config  // app serving the request, nil until set
+	form   *multipart.Form // parsed within limits, see multipartForm
+
+	current  *serverCtx // shared by the handlers of the chain, see acquireCtx
+	detached bool       // the chain went on in another goroutine
+
+	chains   int           // app handler chains running, see streamContext
+	returned chan struct{} // closed once they have all returned
+}
+
+func getRequestState(ctx *fasthttp.RequestCtx) *requestState {
+	if state, ok := ctx.UserValue(stateKey{}).(*requestState); ok {
+		return state
+	}
+	state := &requestState{}
+	ctx.SetUserValue(stateKey{}, state)
+	return state
+}
+
+// context returns the context set by SetContext, or the request context,
+// which is done when the server shuts down.
+func (st *requestState) context(ctx *fasthttp.RequestCtx) context.Context {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.ctx != nil {
+		return st.ctx
+	}
+	return requestContext{ctx}
+}
+
+// requestContext is the RequestCtx as a context, without its Value: that
+// reads the request's user values, and the timer of a derived context
+// calls it once the deadline passes, possibly after the request was
+// released and reset.
+type requestContext struct {
+	rc *fasthttp.RequestCtx
+}
+
+func (c requestContext) Deadline() (time.Time, bool) { return c.rc.Deadline() }
+func (c requestContext) Done() <-chan struct{}       { return c.rc.Done() }
+func (c requestContext) Err() error                  { return c.rc.Err() }
+func (requestContext) Value(any) any                 { return nil }
+
+func (st *requestState) setContext(ctx context.Context) {
+	st.mu.Lock()
+	st.ctx = ctx
+	st.mu.Unlock()
+}
+
+// swapContext sets ctx and returns the context it replaces, nil when none
+// was set.
+func (st *requestState) swapContext(ctx context.Context) context.Context {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	prev := st.ctx
+	st.ctx = ctx
+	return prev
+}
+
+// enter records that an app's handler chain starts serving the request.
+func (st *requestState) enter() {
+	st.mu.Lock()
+	st.chains++
+	st.mu.Unlock()
+}
+
+// leave records that an app's handler chain has returned, releasing the
+// stream writers waiting in streamContext once none is left.
+func (st *requestState) leave() {
+	st.mu.Lock()
+	st.chains--
+	if st.chains == 0 && st.returned != nil {
+		close(st.returned)
+		st.returned = nil
+	}
+	st.mu.Unlock()
+}
+
+// streamContext returns the context for a body stream writer set now.
+// fasthttp starts the writer on its own goroutine straight away, while the
+// chain is still running, so the returned func waits for the chain to
+// return and gives the request's context by then: a NextTimeout deadline
+// that ended with its handler does not cut the stream short. Outside an
+// app it gives the context as it is now.
+func (st *requestState) streamContext(ctx *fasthttp.RequestCtx) func() context.Context {
+	st.mu.Lock()
+	if st.chains == 0 {
+		st.mu.Unlock()
+		current := st.context(ctx)
+		return func() context.Context { return current }
+	}
+	if st.returned == nil {
+		st.returned = make(chan struct{})
+	}
+	returned := st.returned
+	st.mu.Unlock()
+	return func() context.Context {
+		<-returned
+		return st.context(ctx)
+	}
+}
+
+func (st *requestState) setError(err error) {
+	st.mu.Lock()
+	st.err = err
+	st.seq++
+	st.mu.Unlock()
+}
+
+// handled reports whether err, or an error it wraps, was already handled.
+func (st *requestState) handled(err error) bool {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.err != nil && errors.Is(err, st.err)
+}
+
+func (st *requestState) seqNum() int {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.seq
+}
+
+// since returns the handled error if one was set after seq.
+func (st *requestState) since(seq int) error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.seq == seq {
+		return nil
+	}
+	return st.err
+}
+
+func (st *requestState) local(key string) any {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.locals[key]
+}
+
+func (st *requestState) setLocal(key string, val any) {
+	st.mu.Lock()
+	if st.locals == nil {
+		st.locals = make(map[string]any)
+	}
+	st.locals[key] = val
+	st.mu.Unlock()
+}
+
+func (st *requestState) multipartForm() *multipart.Form {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.form
+}
+
+func (st *requestState) setMultipartForm(form *multipart.Form) {
+	st.mu.Lock()
+	st.form = form
+	st.mu.Unlock()
+}
+
+// Close removes the files of the parsed multipart form. fasthttp calls it
+// once the request is done, as it closes the user values that are
+// io.Closers.
+func (st *requestState) Close() error {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.form == nil {
+		return nil
+	}
+	err := st.form.RemoveAll()
+	st.form = nil
+	return err
+}
+
+// init records the config of the app serving the request, unless an outer
+// app already did.
+func (st *requestState) init(config *cenery.Config) {
+	st.mu.Lock()
+	if st.config == nil {
+		st.config = config
+	}
+	if st.limits == nil {
+		l := config.Limits
+		st.limits = &l
+	}
+	st.mu.Unlock()
+}
+
+func (st *requestState) appConfig() *cenery.Config {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.config
+}
+
+func (st *requestState) setLimits(l cenery.Limits) {
+	st.mu.Lock()
+	st.limits = &l
+	st.mu.Unlock()
+}
+
+// requestLimits returns the limits of the app serving ctx, or the defaults
+// when ctx is not served through one.
+func requestLimits(ctx *fasthttp.RequestCtx) cenery.Limits {
+	if state, ok := ctx.UserValue(stateKey{}).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if state.limits != nil {
+			return *state.limits
+		}
+	}
+	return cenery.DefaultLimits()
+}
+
+// checkBodySize returns cenery.ErrBodyTooLarge if the declared or already
+// read body of req exceeds the BodyLimit of l.
+func checkBodySize(req *fasthttp.Request, l cenery.Limits) error {
+	if err := l.CheckBodySize(int64(req.Header.ContentLength())); err != nil {
+		return err
+	}
+	if req.IsBodyStream() {
+		if req.Header.ContentLength() >= 0 {
+			return nil
+		}
+		// A streamed chunked body has no declared size: buffer it within
+		// the limit so reading the body later cannot exceed it.
+		data, err := io.ReadAll(l.LimitBody(io.NopCloser(req.BodyStream()), -1))
+		if err != nil {
+			return err
+		}
+		req.SetBody(data)
+		return nil
+	}
+	return l.CheckBodySize(int64(len(req.Body())))
+}
+
+// serverBodyLimit converts an app-level BodyLimit to a fasthttp server limit.
+func serverBodyLimit(l cenery.Limits) int {
+	if l.BodyLimit < 0 {
+		return math.MaxInt
+	}
+	return int(l.BodyLimit)
+}
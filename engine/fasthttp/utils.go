@@ -2,7 +2,7 @@ package fasthttp
 
 import (
 	"errors"
-	"io"
+	"mime"
 	"mime/multipart"
 
 	"github.com/dreamph/cenery"
@@ -10,152 +10,128 @@ import (
 )
 
 func FormFile(c *fasthttp.RequestCtx, fileKey string) *cenery.FileData {
-	file, _ := formFile(c, fileKey, false)
+	file, _ := FormFileE(c, fileKey)
 	return file
 }
 
-func formFile(c *fasthttp.RequestCtx, fileKey string, errorIfNotFound bool) (*cenery.FileData, error) {
-	form, err := c.MultipartForm()
+// FormFileE reads the first file uploaded as fileKey into memory.
+func FormFileE(c *fasthttp.RequestCtx, fileKey string) (*cenery.FileData, error) {
+	headers, err := formFileHeaders(c, fileKey)
 	if err != nil {
 		return nil, err
 	}
-
-	files, ok := form.File[fileKey]
-	if !ok {
-		if errorIfNotFound {
-			return nil, errors.New("fileKey not found:" + fileKey)
-		}
-		return nil, nil
-	}
-	file := files[0]
-	contentType := "application/octet-stream" // default
-	if ct := file.Header.Get("Content-Type"); ct != "" {
-		contentType = ct
-	}
-	return &cenery.FileData{
-		FileData:        fileHeaderToBytes(file),
-		FileSize:        file.Size,
-		FileContentType: contentType,
-		FileName:        file.Filename,
-	}, nil
+	return cenery.ReadFormFile(headers[0])
 }
 
 func FormFiles(c *fasthttp.RequestCtx, fileKey string) *[]cenery.FileData {
-	files, _ := formFiles(c, fileKey, false)
-	return files
+	files, err := FormFilesE(c, fileKey)
+	if err != nil {
+		return nil
+	}
+	return &files
 }
 
-func formFiles(c *fasthttp.RequestCtx, fileKey string, errorIfNotFound bool) (*[]cenery.FileData, error) {
-	form, err := c.MultipartForm()
+// FormFilesE reads every file uploaded as fileKey into memory.
+func FormFilesE(c *fasthttp.RequestCtx, fileKey string) ([]cenery.FileData, error) {
+	form, err := multipartForm(c)
 	if err != nil {
 		return nil, err
 	}
-
-	files, ok := form.File[fileKey]
-	if !ok {
-		if errorIfNotFound {
-			return nil, errors.New("fileKey not found:" + fileKey)
-		}
-		return nil, nil
-	}
-
-	uploadFiles := make([]cenery.FileData, len(files))
-	for i, file := range files {
-		contentType := "application/octet-stream" // default
-		if ct := file.Header.Get("Content-Type"); ct != "" {
-			contentType = ct
-		}
-		uploadFiles[i] = cenery.FileData{
-			FileData:        fileHeaderToBytes(file),
-			FileSize:        file.Size,
-			FileContentType: contentType,
-			FileName:        file.Filename,
-		}
-	}
-	return &uploadFiles, nil
+	return cenery.FormFileData(form, fileKey)
 }
 
-func fileHeaderToBytes(h *multipart.FileHeader) []byte {
-	file, err := h.Open()
+// FormFileStream opens the first file uploaded as fileKey without reading
+// it into memory. Close the stream once done.
+func FormFileStream(c *fasthttp.RequestCtx, fileKey string) (*cenery.FileStream, error) {
+	headers, err := formFileHeaders(c, fileKey)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	defer func(file multipart.File) {
-		_ = file.Close()
-	}(file)
-	data, err := io.ReadAll(file)
+	return cenery.OpenFormFile(headers[0])
+}
+
+// FormFilesStream opens every file uploaded as fileKey without reading
+// them into memory.
+func FormFilesStream(c *fasthttp.RequestCtx, fileKey string) ([]*cenery.FileStream, error) {
+	form, err := multipartForm(c)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	return data
+	return cenery.FormFileStreams(form, fileKey)
 }
 
-// FormFileStream returns a streaming file upload (no memory allocation)
-func FormFileStream(c *fasthttp.RequestCtx, fileKey string) (*cenery.FileStream, error) {
-	form, err := c.MultipartForm()
+func formFileHeaders(c *fasthttp.RequestCtx, fileKey string) ([]*multipart.FileHeader, error) {
+	form, err := multipartForm(c)
 	if err != nil {
 		return nil, err
 	}
+	return cenery.FormFileHeaders(form, fileKey)
+}
 
-	files, ok := form.File[fileKey]
-	if !ok {
-		return nil, errors.New("fileKey not found:" + fileKey)
+func multipartForm(c *fasthttp.RequestCtx) (*multipart.Form, error) {
+	limits := requestLimits(c)
+	if err := checkBodySize(&c.Request, limits); err != nil {
+		return nil, err
 	}
-
-	fileHeader := files[0]
-	file, err := fileHeader.Open()
+	if !limits.ChecksFiles() {
+		form, err := c.MultipartForm()
+		if errors.Is(err, fasthttp.ErrNoMultipartForm) {
+			return nil, cenery.RequestError(cenery.ErrNotMultipart)
+		}
+		return form, err
+	}
+	// fasthttp's own parser stores the whole form before it can be
+	// checked, so parse it part by part instead, within the limits.
+	state := getRequestState(c)
+	if form := state.multipartForm(); form != nil {
+		return form, nil
+	}
+	r, err := multipartReader(c)
 	if err != nil {
 		return nil, err
 	}
-
-	contentType := "application/octet-stream" // default
-	if ct := fileHeader.Header.Get("Content-Type"); ct != "" {
-		contentType = ct
-	}
-
-	return &cenery.FileStream{
-		File:            file,
-		FileName:        fileHeader.Filename,
-		FileSize:        fileHeader.Size,
-		FileContentType: contentType,
-	}, nil
-}
-
-// FormFilesStream returns multiple streaming file uploads (no memory allocation)
-func FormFilesStream(c *fasthttp.RequestCtx, fileKey string) ([]*cenery.FileStream, error) {
-	form, err := c.MultipartForm()
+	form, err := cenery.ReadMultipartForm(r, limits.MultipartMemory)
 	if err != nil {
 		return nil, err
 	}
+	state.setMultipartForm(form)
+	return form, nil
+}
 
-	fileHeaders, ok := form.File[fileKey]
-	if !ok {
-		return nil, errors.New("fileKey not found:" + fileKey)
+// multipartReader reads the multipart body of c part by part, within the
+// request's limits. The body only streams when the server has
+// StreamRequestBody set.
+func multipartReader(c *fasthttp.RequestCtx) (*cenery.MultipartReader, error) {
+	boundary := string(c.Request.Header.MultipartFormBoundary())
+	if boundary == "" {
+		return nil, cenery.RequestError(cenery.ErrNotMultipart)
 	}
+	limits := requestLimits(c)
+	body := limits.LimitBody(NewRequest(&c.Request).BodyStream(), int64(c.Request.Header.ContentLength()))
+	return cenery.NewMultipartReader(multipart.NewReader(body, boundary), limits), nil
+}
 
-	streams := make([]*cenery.FileStream, 0, len(fileHeaders))
-	for _, fileHeader := range fileHeaders {
-		file, err := fileHeader.Open()
+// formValues returns the fields of a multipart/form-data or
+// application/x-www-form-urlencoded body.
+func formValues(c *fasthttp.RequestCtx) (map[string][]string, error) {
+	if isMultipart(string(c.Request.Header.ContentType())) {
+		form, err := multipartForm(c)
 		if err != nil {
-			// Close previously opened files on error
-			for _, s := range streams {
-				_ = s.File.Close()
-			}
 			return nil, err
 		}
-
-		contentType := "application/octet-stream" // default
-		if ct := fileHeader.Header.Get("Content-Type"); ct != "" {
-			contentType = ct
-		}
-
-		streams = append(streams, &cenery.FileStream{
-			File:            file,
-			FileName:        fileHeader.Filename,
-			FileSize:        fileHeader.Size,
-			FileContentType: contentType,
-		})
+		return form.Value, nil
 	}
+	if err := checkBodySize(&c.Request, requestLimits(c)); err != nil {
+		return nil, err
+	}
+	values := make(map[string][]string)
+	c.PostArgs().VisitAll(func(key, value []byte) {
+		values[string(key)] = append(values[string(key)], string(value))
+	})
+	return values, nil
+}
 
-	return streams, nil
+func isMultipart(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && mediaType == "multipart/form-data"
 }
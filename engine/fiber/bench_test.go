@@ -0,0 +1,109 @@
+package fiber
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/dreamph/cenery"
+	"github.com/dreamph/cenery/cenerybench"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+var benchEngine = cenerybench.Engine{
+	Cenery: func(setup func(app cenery.App)) cenerybench.Serve {
+		fiberApp := fiber.New()
+		setup(New(fiberApp))
+		return serveRequestCtx(fiberApp.Handler())
+	},
+	Native: nativeScenario,
+}
+
+// serveRequestCtx serves requests with handler and a reused
+// fasthttp.RequestCtx, as the fasthttp server does.
+func serveRequestCtx(handler fasthttp.RequestHandler) cenerybench.Serve {
+	var ctx fasthttp.RequestCtx
+	ctx.Init(&fasthttp.Request{}, nil, nil)
+	return func(r cenerybench.Request) (int, []byte) {
+		ctx.Request.Reset()
+		ctx.Response.Reset()
+		ctx.Request.Header.SetMethod(r.Method)
+		ctx.Request.SetRequestURI(r.Path)
+		if r.ContentType != "" {
+			ctx.Request.Header.SetContentType(r.ContentType)
+		}
+		ctx.Request.SetBody(r.Body)
+		handler(&ctx)
+		return ctx.Response.StatusCode(), ctx.Response.Body()
+	}
+}
+
+// nativeScenario implements s with fiber directly.
+func nativeScenario(s cenerybench.Scenario) cenerybench.Serve {
+	app := fiber.New()
+	for range s.Middleware {
+		app.Use(func(c *fiber.Ctx) error {
+			return c.Next()
+		})
+	}
+	switch s.Name {
+	case "static":
+		app.Get("/hello", func(c *fiber.Ctx) error {
+			return c.SendString("Hello, World!")
+		})
+	case "param":
+		app.Get("/users/:id/posts/:post", func(c *fiber.Ctx) error {
+			return c.SendString(c.Params("id") + "/" + c.Params("post"))
+		})
+	case "json-encode":
+		app.Get("/user", func(c *fiber.Ctx) error {
+			return c.JSON(cenerybench.SampleUser)
+		})
+	case "json-decode":
+		app.Post("/user", func(c *fiber.Ctx) error {
+			var u cenerybench.User
+			if err := c.BodyParser(&u); err != nil {
+				return err
+			}
+			return c.SendString(u.Name)
+		})
+	case "multipart":
+		app.Post("/upload", func(c *fiber.Ctx) error {
+			header, err := c.FormFile("file")
+			if err != nil {
+				return err
+			}
+			file, err := header.Open()
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			data, err := io.ReadAll(file)
+			if err != nil {
+				return err
+			}
+			return c.SendString(strconv.Itoa(len(data)))
+		})
+	case "stream":
+		app.Get("/stream", func(c *fiber.Ctx) error {
+			c.Set("Content-Type", "application/octet-stream")
+			return c.Status(http.StatusOK).SendStream(bytes.NewReader(cenerybench.StreamData))
+		})
+	default:
+		app.Get("/chain", func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+	}
+	return serveRequestCtx(app.Handler())
+}
+
+func TestBenchSuite(t *testing.T) {
+	cenerybench.Verify(t, benchEngine)
+}
+
+func BenchmarkSuite(b *testing.B) {
+	cenerybench.Run(b, benchEngine)
+}
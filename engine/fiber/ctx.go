@@ -1,20 +1,38 @@
 package fiber
 
 import (
-	"encoding/json"
+	"bufio"
+	"context"
 	"errors"
 	"io"
+	"io/fs"
+	"time"
 
 	"github.com/dreamph/cenery"
 	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
 )
 
 type serverCtx struct {
-	ctx *fiber.Ctx
+	ctx   *fiber.Ctx
+	res   response // returned by Response, so it allocates nothing
+	state *requestState
+	err   error
 }
 
 func NewServerCtx(ctx *fiber.Ctx) cenery.Ctx {
-	return &serverCtx{ctx: ctx}
+	return newServerCtx(ctx)
+}
+
+func newServerCtx(ctx *fiber.Ctx) *serverCtx {
+	s := &serverCtx{}
+	s.reset(ctx, getRequestState(ctx))
+	return s
+}
+
+// reset prepares s for a handler, clearing what the previous one left.
+func (s *serverCtx) reset(ctx *fiber.Ctx, state *requestState) {
+	*s = serverCtx{ctx: ctx, res: response{resp: ctx.Response()}, state: state}
 }
 
 func (s *serverCtx) Params(key string, defaultValue ...string) string {
@@ -25,8 +43,15 @@ func (s *serverCtx) QueryParam(key string, defaultValue ...string) string {
 	return s.ctx.Query(key, defaultValue...)
 }
 
+func (s *serverCtx) IP() string {
+	return s.ctx.Context().RemoteIP().String()
+}
+
 func (s *serverCtx) BodyParser(out any) error {
-	return s.ctx.BodyParser(out)
+	if err := checkBodySize(s.ctx.Request(), requestLimits(s.ctx)); err != nil {
+		return err
+	}
+	return cenery.DecodeBody(s, s.state.config, s.ctx.Body(), out)
 }
 
 func (s *serverCtx) BodyParserStream(out any) error {
@@ -34,18 +59,20 @@ func (s *serverCtx) BodyParserStream(out any) error {
 	if body == nil {
 		return errors.New("request body can't be empty")
 	}
-	dec := json.NewDecoder(body)
-	if err := dec.Decode(out); err != nil {
-		if errors.Is(err, io.EOF) {
-			return nil
-		}
-		return err
+	return cenery.DecodeJSON(s, s.state.config, body, out)
+}
+
+func (s *serverCtx) BodyParserNDJSON(out any, fn func() error) error {
+	body := s.BodyStream()
+	if body == nil {
+		return errors.New("request body can't be empty")
 	}
-	return nil
+	return cenery.DecodeNDJSON(s, s.state.config, body, out, fn)
 }
 
 func (s *serverCtx) BodyStream() io.ReadCloser {
-	return NewRequest(s.ctx.Request()).BodyStream()
+	body := NewRequest(s.ctx.Request()).BodyStream()
+	return requestLimits(s.ctx).LimitBody(body, int64(s.ctx.Request().Header.ContentLength()))
 }
 
 func (s *serverCtx) FormFile(fileKey string) *cenery.FileData {
@@ -56,6 +83,14 @@ func (s *serverCtx) FormFiles(fileKey string) *[]cenery.FileData {
 	return FormFiles(s.ctx, fileKey)
 }
 
+func (s *serverCtx) FormFileE(fileKey string) (*cenery.FileData, error) {
+	return FormFileE(s.ctx, fileKey)
+}
+
+func (s *serverCtx) FormFilesE(fileKey string) ([]cenery.FileData, error) {
+	return FormFilesE(s.ctx, fileKey)
+}
+
 func (s *serverCtx) FormFileStream(fileKey string) (*cenery.FileStream, error) {
 	return FormFileStream(s.ctx, fileKey)
 }
@@ -64,6 +99,36 @@ func (s *serverCtx) FormFilesStream(fileKey string) ([]*cenery.FileStream, error
 	return FormFilesStream(s.ctx, fileKey)
 }
 
+func (s *serverCtx) FormValue(key string, defaultValue ...string) string {
+	var val string
+	if values, _ := formValues(s.ctx); len(values[key]) > 0 {
+		val = values[key][0]
+	}
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (s *serverCtx) FormValues(key string) []string {
+	values, _ := formValues(s.ctx)
+	return values[key]
+}
+
+func (s *serverCtx) MultipartForm() (*cenery.MultipartForm, error) {
+	form, err := multipartForm(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cenery.MultipartForm{Value: form.Value, File: form.File}, nil
+}
+
+func (s *serverCtx) MultipartReader() (*cenery.MultipartReader, error) {
+	return multipartReader(s.ctx)
+}
+
 func (s *serverCtx) SendString(status int, data string) error {
 	return s.ctx.Status(status).SendString(data)
 }
@@ -73,13 +138,105 @@ func (s *serverCtx) Send(status int, data []byte) error {
 }
 
 func (s *serverCtx) SendJSON(status int, data any) error {
-	return s.ctx.Status(status).JSON(data)
+	payload, err := s.state.config.JSONCodec().Marshal(data)
+	if err != nil {
+		return err
+	}
+	s.ctx.Set("Content-Type", "application/json")
+	return s.Send(status, payload)
+}
+
+func (s *serverCtx) Negotiate(status int, data any) error {
+	return cenery.Negotiate(s, s.state.config, status, data)
+}
+
+func (s *serverCtx) SendJSONStream(status int, items any, format ...cenery.JSONStreamFormat) error {
+	write, err := cenery.JSONStream(s, s.state.config, items, format...)
+	if err != nil {
+		return err
+	}
+	s.ctx.Status(status)
+	ctx := s.state.streamContext(s.Context())
+	s.ctx.Context().SetBodyStreamWriter(func(bw *bufio.Writer) {
+		_ = write(ctx(), bw, bw.Flush)
+	})
+	return nil
 }
 
 func (s *serverCtx) SendStream(status int, contentType string, reader io.Reader) error {
+	return s.SendStreamSized(status, contentType, reader, cenery.ReaderSize(reader))
+}
+
+// SendStreamSized hands reader to fasthttp, which sends an *os.File of
+// known size with sendfile.
+func (s *serverCtx) SendStreamSized(status int, contentType string, reader io.Reader, size int64) error {
 	s.ctx.Set("Content-Type", contentType)
 	s.ctx.Status(status)
-	return s.ctx.SendStream(reader)
+	if size < 0 {
+		size = -1
+	}
+	return s.ctx.SendStream(reader, int(size))
+}
+
+func (s *serverCtx) SendFile(path string) error {
+	return cenery.SendFile(s, path)
+}
+
+func (s *serverCtx) SendFileFS(fsys fs.FS, name string) error {
+	return cenery.SendFileFS(s, fsys, name)
+}
+
+func (s *serverCtx) Download(path, filename string) error {
+	return cenery.Download(s, path, filename)
+}
+
+func (s *serverCtx) SaveFile(file *cenery.FileStream, path string) error {
+	return cenery.SaveFile(s, file, path)
+}
+
+func (s *serverCtx) Redirect(status int, location string) error {
+	return cenery.Redirect(s, s.state.config, status, location)
+}
+
+func (s *serverCtx) RedirectToRoute(name string, params map[string]string) error {
+	return cenery.RedirectToRoute(s, s.state.config, name, params)
+}
+
+func (s *serverCtx) RedirectBack(fallback string) error {
+	return cenery.RedirectBack(s, s.state.config, fallback)
+}
+
+// SSE streams from fasthttp's body stream writer, which runs once the
+// handler chain has returned, so the request context is captured up front.
+func (s *serverCtx) SSE(fn func(w cenery.EventWriter) error) error {
+	cenery.SetEventStreamHeaders(s.Response())
+	s.ctx.Status(fiber.StatusOK)
+	ctx := s.state.streamContext(s.Context())
+	lastEventID := string(s.ctx.Request().Header.Peek("Last-Event-ID"))
+	s.ctx.Context().SetBodyStreamWriter(func(bw *bufio.Writer) {
+		w, cancel := cenery.NewEventWriter(ctx(), bw, bw.Flush, lastEventID)
+		defer cancel()
+		_ = fn(w)
+	})
+	return nil
+}
+
+func (s *serverCtx) Locals(key string, value ...any) any {
+	return s.ctx.Locals(key, value...)
+}
+
+func (s *serverCtx) Context() context.Context {
+	return s.ctx.UserContext()
+}
+
+func (s *serverCtx) SetContext(ctx context.Context) {
+	s.ctx.SetUserContext(ctx)
+	s.state.ctx = ctx
+}
+
+func (s *serverCtx) SetLimits(l cenery.Limits) {
+	limits := requestLimits(s.ctx).Merge(l)
+	s.state.limits = &limits
 }
 
 func (s *serverCtx) Request() cenery.Request {
@@ -87,9 +244,48 @@ func (s *serverCtx) Request() cenery.Request {
 }
 
 func (s *serverCtx) Response() cenery.Response {
-	return NewResponse(s.ctx.Response())
+	return &s.res
 }
 
 func (s *serverCtx) Next() error {
-	return s.ctx.Next()
+	seq := s.state.seq
+	if err := s.ctx.Next(); err != nil {
+		return err
+	}
+	return s.state.since(seq)
+}
+
+// NextTimeout runs the rest of the chain with ctx as its context. fiber
+// pools its contexts and only sends the response once the handler returns,
+// so the chain runs on the calling goroutine and relies on handlers
+// honouring ctx; if ctx is done by the time it returns, whatever the chain
+// wrote is discarded in favour of the error response.
+func (s *serverCtx) NextTimeout(ctx context.Context) error {
+	var header fasthttp.ResponseHeader
+	s.ctx.Response().Header.CopyTo(&header)
+
+	// ctx is cancelled once the caller returns, so the chain's streams and
+	// the handlers above it go back to the previous context.
+	prev := s.Context()
+	s.SetContext(ctx)
+	defer s.SetContext(prev)
+	err := s.Next()
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		// The timer cancelling ctx may not have run yet on a busy server.
+		<-ctx.Done()
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		resp := s.ctx.Response()
+		resp.Reset()
+		header.CopyTo(&resp.Header)
+		return ctxErr
+	}
+	return err
+}
+
+// release finishes the response of a handler that returned err.
+func (s *serverCtx) release() {
+	if s.err != nil {
+		s.state.setError(s.err)
+	}
 }
@@ -7,11 +7,20 @@ import (
 	fiberrecover "github.com/gofiber/fiber/v2/middleware/recover"
 )
 
-func NewApp() cenery.App {
+// goJSON is the JSON codec of apps made with NewApp unless they pick
+// another with cenery.WithJSONCodec.
+var goJSON = cenery.NewJSONCodec(gojson.Marshal, gojson.Unmarshal, gojson.NewEncoder, gojson.NewDecoder)
+
+func NewApp(opts ...cenery.Option) cenery.App {
+	opts = append([]cenery.Option{cenery.WithJSONCodec(goJSON)}, opts...)
+	cfg := cenery.NewConfig(opts...)
 	fiberApp := fiber.New(fiber.Config{
-		JSONDecoder: gojson.Unmarshal,
-		JSONEncoder: gojson.Marshal,
+		JSONDecoder:                  cfg.JSONCodec().Unmarshal,
+		JSONEncoder:                  cfg.JSONCodec().Marshal,
+		BodyLimit:                    serverBodyLimit(cfg.Limits),
+		StreamRequestBody:            cfg.StreamRequestBody,
+		DisablePreParseMultipartForm: true, // the route's Limits are not known yet
 	})
 	fiberApp.Use(fiberrecover.New())
-	return New(fiberApp)
+	return New(fiberApp, opts...)
 }
@@ -0,0 +1,46 @@
+package fiber
+
+import (
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ctxPool holds the serverCtxs shared along a request's handler chain,
+// under the lifetime rules of cenery.Ctx.
+var ctxPool = sync.Pool{
+	New: func() any {
+		return new(serverCtx)
+	},
+}
+
+// acquireCtx returns the serverCtx for a handler of state's request, with
+// the fields of the handler it is reused from, if any.
+func acquireCtx(ctx *fiber.Ctx, state *requestState) (*serverCtx, serverCtx) {
+	if s := state.current; s != nil {
+		caller := *s
+		s.reset(ctx, state)
+		return s, caller
+	}
+	s := ctxPool.Get().(*serverCtx)
+	s.reset(ctx, state)
+	state.current = s
+	return s, serverCtx{}
+}
+
+// releaseCtx hands s back to the handler it was reused from, or returns it
+// to the pool.
+func releaseCtx(s *serverCtx, caller serverCtx) {
+	if caller.state != nil {
+		*s = caller
+		return
+	}
+	state := s.state
+	state.current = nil
+	if state.returned != nil {
+		close(state.returned)
+		state.returned = nil
+	}
+	*s = serverCtx{}
+	ctxPool.Put(s)
+}
@@ -16,6 +16,22 @@ func NewRequest(req *fasthttp.Request) cenery.Request {
 	return &request{req: req}
 }
 
+func (h *request) Method() string {
+	return string(h.req.Header.Method())
+}
+
+func (h *request) Host() string {
+	return string(h.req.Host())
+}
+
+func (h *request) Scheme() string {
+	return string(h.req.URI().Scheme())
+}
+
+func (h *request) Path() string {
+	return string(h.req.URI().Path())
+}
+
 func (h *request) Body() []byte {
 	return h.req.Body()
 }
@@ -2,6 +2,10 @@ package fiber
 
 import (
 	"context"
+	"errors"
+	"io/fs"
+	"net"
+	"strings"
 
 	"github.com/dreamph/cenery"
 	"github.com/gofiber/fiber/v2"
@@ -9,10 +13,11 @@ import (
 
 type app struct {
 	server *fiber.App
+	config *cenery.Config
 }
 
-func New(server *fiber.App) cenery.App {
-	return &app{server: server}
+func New(server *fiber.App, opts ...cenery.Option) cenery.App {
+	return &app{server: server, config: cenery.NewConfig(opts...)}
 }
 
 func (a *app) Name() string {
@@ -23,6 +28,13 @@ func (a *app) Listen(addr string) error {
 	return a.server.Listen(addr)
 }
 
+// Serve serves the connections accepted by ln, such as an in-memory
+// listener in tests, without the startup message of Listen.
+func (a *app) Serve(ln net.Listener) error {
+	a.server.Handler()
+	return a.server.Server().Serve(ln)
+}
+
 func (a *app) Use(handlers ...cenery.Handler) {
 	apiHandlers := a.toHandlers(handlers...)
 	middlewareHandlers := make([]any, len(apiHandlers))
@@ -32,40 +44,64 @@ func (a *app) Use(handlers ...cenery.Handler) {
 	a.server.Use(middlewareHandlers...)
 }
 
-func (a *app) Get(path string, handlers ...cenery.Handler) {
+func (a *app) Get(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Get(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodGet, path)
 }
 
-func (a *app) Post(path string, handlers ...cenery.Handler) {
+func (a *app) Post(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Post(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodPost, path)
 }
 
-func (a *app) Put(path string, handlers ...cenery.Handler) {
+func (a *app) Put(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Put(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodPut, path)
 }
 
-func (a *app) Delete(path string, handlers ...cenery.Handler) {
+func (a *app) Delete(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Delete(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodDelete, path)
 }
 
-func (a *app) Head(path string, handlers ...cenery.Handler) {
+func (a *app) Head(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Head(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodHead, path)
 }
 
-func (a *app) Options(path string, handlers ...cenery.Handler) {
+func (a *app) Options(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Options(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodOptions, path)
 }
 
-func (a *app) Connect(path string, handlers ...cenery.Handler) {
+func (a *app) Connect(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Connect(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodConnect, path)
 }
 
-func (a *app) Patch(path string, handlers ...cenery.Handler) {
+func (a *app) Patch(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Patch(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodPatch, path)
 }
 
-func (a *app) Trace(path string, handlers ...cenery.Handler) {
+func (a *app) Trace(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Trace(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(fiber.MethodTrace, path)
+}
+
+func (a *app) Static(prefix, root string, config ...cenery.StaticConfig) {
+	a.StaticFS(prefix, cenery.RootFS(root), config...)
+}
+
+func (a *app) StaticFS(prefix string, fsys fs.FS, config ...cenery.StaticConfig) {
+	handler := cenery.StaticHandler(prefix, fsys, config...)
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" {
+		a.Get(prefix, handler)
+		a.Head(prefix, handler)
+	}
+	a.Get(prefix+"/*", handler)
+	a.Head(prefix+"/*", handler)
 }
 
 func (a *app) Shutdown(_ context.Context) error {
@@ -77,9 +113,31 @@ func (a *app) toHandlers(handlers ...cenery.Handler) []fiber.Handler {
 	for i, handler := range handlers {
 		h := handler // Copy variable to avoid closure capture bug
 		handlerList[i] = func(c *fiber.Ctx) error {
-			svc := NewServerCtx(c)
-			return h(svc)
+			svc, caller := acquireCtx(c, getRequestState(c))
+			defer releaseCtx(svc, caller)
+			svc.state.init(a.config)
+			if err := h(svc); err != nil {
+				a.handleError(svc, err)
+			}
+			svc.release()
+			return nil
 		}
 	}
 	return handlerList
 }
+
+// handleError passes err to the error handler unless a later handler has
+// already done so and the error is only being returned up the chain.
+func (a *app) handleError(s *serverCtx, err error) {
+	if s.state.handled(err) {
+		return
+	}
+	var fe *fiber.Error
+	if errors.As(err, &fe) {
+		err = &cenery.Error{Code: fe.Code, Message: fe.Message, Err: err}
+	}
+	s.err = err
+	if herr := a.config.ErrorHandler(s, err); herr != nil {
+		_ = s.ctx.Status(fiber.StatusInternalServerError).SendString(herr.Error())
+	}
+}
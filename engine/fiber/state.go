@@ -0,0 +1,150 @@
+package fiber
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"mime/multipart"
+
+	"github.com/dreamph/cenery"
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+type stateKey struct{}
+
+// requestState is shared by every handler serving one request.
+type requestState struct {
+	err error // last error passed to the error handler
+	seq int   // bumped whenever err is set
+
+	limits *cenery.Limits  // nil until set by the app or a route
+	config *cenery.Config  // app serving the request, nil until set
+	ctx    context.Context // last set with SetContext, nil until then
+	form   *multipart.Form // parsed within limits, see multipartForm
+
+	current  *serverCtx    // shared by the handlers of the chain, see acquireCtx
+	returned chan struct{} // closed once the chain returns, see streamContext
+}
+
+func getRequestState(c *fiber.Ctx) *requestState {
+	if state, ok := c.Locals(stateKey{}).(*requestState); ok {
+		return state
+	}
+	state := &requestState{}
+	c.Locals(stateKey{}, state)
+	return state
+}
+
+func (st *requestState) setError(err error) {
+	st.err = err
+	st.seq++
+}
+
+// handled reports whether err, or an error it wraps, was already handled.
+func (st *requestState) handled(err error) bool {
+	return st.err != nil && errors.Is(err, st.err)
+}
+
+// since returns the handled error if one was set after seq.
+func (st *requestState) since(seq int) error {
+	if st.seq == seq {
+		return nil
+	}
+	return st.err
+}
+
+func (st *requestState) multipartForm() *multipart.Form {
+	return st.form
+}
+
+func (st *requestState) setMultipartForm(form *multipart.Form) {
+	st.form = form
+}
+
+// Close removes the files of the parsed multipart form. fasthttp calls it
+// once the request is done, as it closes the user values, which hold the
+// Locals, that are io.Closers.
+func (st *requestState) Close() error {
+	if st.form == nil {
+		return nil
+	}
+	err := st.form.RemoveAll()
+	st.form = nil
+	return err
+}
+
+// init records the config of the app serving the request, unless an outer
+// app already did.
+func (st *requestState) init(config *cenery.Config) {
+	if st.config == nil {
+		st.config = config
+	}
+	if st.limits == nil {
+		l := config.Limits
+		st.limits = &l
+	}
+}
+
+// requestLimits returns the limits of the app serving c, or the defaults
+// when c is not served through one.
+func requestLimits(c *fiber.Ctx) cenery.Limits {
+	if state, ok := c.Locals(stateKey{}).(*requestState); ok && state.limits != nil {
+		return *state.limits
+	}
+	return cenery.DefaultLimits()
+}
+
+// checkBodySize returns cenery.ErrBodyTooLarge if the declared or already
+// read body of req exceeds the BodyLimit of l.
+func checkBodySize(req *fasthttp.Request, l cenery.Limits) error {
+	if err := l.CheckBodySize(int64(req.Header.ContentLength())); err != nil {
+		return err
+	}
+	if req.IsBodyStream() {
+		if req.Header.ContentLength() >= 0 {
+			return nil
+		}
+		// A streamed chunked body has no declared size: buffer it within
+		// the limit so reading the body later cannot exceed it.
+		data, err := io.ReadAll(l.LimitBody(io.NopCloser(req.BodyStream()), -1))
+		if err != nil {
+			return err
+		}
+		req.SetBody(data)
+		return nil
+	}
+	return l.CheckBodySize(int64(len(req.Body())))
+}
+
+// serverBodyLimit converts an app-level BodyLimit to a fasthttp server limit.
+func serverBodyLimit(l cenery.Limits) int {
+	if l.BodyLimit < 0 {
+		return math.MaxInt
+	}
+	return int(l.BodyLimit)
+}
+
+// streamContext returns the context for a body stream writer set now.
+// fasthttp starts the writer on its own goroutine straight away, while the
+// chain is still running, so the returned func waits for the chain to
+// return and gives the request's context by then: a NextTimeout deadline
+// that ended with its handler does not cut the stream short. Outside a
+// chain it gives fallback.
+func (st *requestState) streamContext(fallback context.Context) func() context.Context {
+	if st.current == nil {
+		return func() context.Context { return fallback }
+	}
+	if st.returned == nil {
+		st.returned = make(chan struct{})
+	}
+	returned := st.returned
+	return func() context.Context {
+		<-returned
+		if st.ctx != nil {
+			return st.ctx
+		}
+		return fallback
+	}
+}
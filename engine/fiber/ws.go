@@ -0,0 +1,172 @@
+package fiber
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dreamph/cenery"
+	"github.com/fasthttp/websocket"
+	"github.com/valyala/fasthttp"
+)
+
+// wsControlTimeout bounds writing a ping or close frame.
+const wsControlTimeout = 5 * time.Second
+
+func (a *app) WebSocket(path string, handler cenery.WSHandler, config ...cenery.WSConfig) {
+	cfg := cenery.NewWSConfig(config...)
+	upgrader := &websocket.FastHTTPUpgrader{
+		HandshakeTimeout:  cfg.HandshakeTimeout,
+		ReadBufferSize:    cfg.ReadBufferSize,
+		WriteBufferSize:   cfg.WriteBufferSize,
+		Subprotocols:      cfg.Subprotocols,
+		EnableCompression: cfg.EnableCompression,
+		// The origin is checked against the cenery.Ctx before upgrading.
+		CheckOrigin: func(*fasthttp.RequestCtx) bool { return true },
+	}
+
+	a.Get(path, func(c cenery.Ctx) error {
+		if !cfg.AllowOrigin(c) {
+			return &cenery.Error{Code: http.StatusForbidden, Message: http.StatusText(http.StatusForbidden), Err: cenery.ErrWSOrigin}
+		}
+		s := c.(*serverCtx)
+		req := newWSRequest(s)
+		// The connection is served once the handler chain has returned. A
+		// failed handshake is answered by the error handler instead.
+		var status int
+		u := *upgrader
+		u.Error = func(ctx *fasthttp.RequestCtx, code int, _ error) {
+			ctx.Response.Header.Set("Sec-Websocket-Version", "13")
+			status = code
+		}
+		err := u.Upgrade(s.ctx.Context(), func(conn *websocket.Conn) {
+			if cfg.ReadLimit > 0 {
+				conn.SetReadLimit(cfg.ReadLimit)
+			}
+			ws := &wsConn{conn: conn, req: req}
+			code, text := cenery.WSCloseCode(handler(ws))
+			_ = ws.Close(code, text)
+		})
+		if err != nil && status != 0 {
+			return &cenery.Error{Code: status, Message: http.StatusText(status), Err: err}
+		}
+		return nil
+	})
+}
+
+// wsRequest copies what a WSConn exposes of the upgrade request, since
+// fiber and fasthttp recycle the Ctx once the connection is hijacked.
+type wsRequest struct {
+	params map[string]string
+	query  url.Values
+	locals map[string]any
+	ip     string
+}
+
+func newWSRequest(s *serverCtx) *wsRequest {
+	req := &wsRequest{
+		params: make(map[string]string),
+		query:  make(url.Values),
+		locals: make(map[string]any),
+		ip:     s.IP(),
+	}
+	for key, val := range s.ctx.AllParams() {
+		req.params[key] = strings.Clone(val)
+	}
+	s.ctx.Context().QueryArgs().VisitAll(func(key, value []byte) {
+		req.query.Add(string(key), string(value))
+	})
+	s.ctx.Context().VisitUserValues(func(key []byte, val any) {
+		req.locals[string(key)] = val
+	})
+	return req
+}
+
+type wsConn struct {
+	conn *websocket.Conn
+	req  *wsRequest
+}
+
+func (w *wsConn) ReadMessage() (int, []byte, error) {
+	mt, data, err := w.conn.ReadMessage()
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return mt, data, &cenery.WSCloseError{Code: ce.Code, Text: ce.Text}
+	}
+	return mt, data, err
+}
+
+func (w *wsConn) WriteMessage(messageType int, data []byte) error {
+	return w.conn.WriteMessage(messageType, data)
+}
+
+func (w *wsConn) Ping(data []byte) error {
+	return w.conn.WriteControl(websocket.PingMessage, data, time.Now().Add(wsControlTimeout))
+}
+
+func (w *wsConn) SetPingHandler(h func(data string) error) {
+	w.conn.SetPingHandler(h)
+}
+
+func (w *wsConn) SetPongHandler(h func(data string) error) {
+	w.conn.SetPongHandler(h)
+}
+
+func (w *wsConn) Close(code int, text string) error {
+	msg := websocket.FormatCloseMessage(code, text)
+	err := w.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsControlTimeout))
+	if cerr := w.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (w *wsConn) SetReadDeadline(t time.Time) error {
+	return w.conn.SetReadDeadline(t)
+}
+
+func (w *wsConn) SetWriteDeadline(t time.Time) error {
+	return w.conn.SetWriteDeadline(t)
+}
+
+func (w *wsConn) SetReadLimit(limit int64) {
+	w.conn.SetReadLimit(limit)
+}
+
+func (w *wsConn) EnableWriteCompression(enable bool) {
+	w.conn.EnableWriteCompression(enable)
+}
+
+func (w *wsConn) Subprotocol() string {
+	return w.conn.Subprotocol()
+}
+
+func (w *wsConn) Params(key string, defaultValue ...string) string {
+	val := w.req.params[key]
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (w *wsConn) QueryParam(key string, defaultValue ...string) string {
+	val := w.req.query.Get(key)
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (w *wsConn) Locals(key string) any {
+	return w.req.locals[key]
+}
+
+func (w *wsConn) IP() string {
+	return w.req.ip
+}
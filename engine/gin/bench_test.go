@@ -0,0 +1,94 @@
+package gin
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/dreamph/cenery"
+	"github.com/dreamph/cenery/cenerybench"
+	"github.com/gin-gonic/gin"
+)
+
+var benchEngine = cenerybench.Engine{
+	Cenery: func(setup func(app cenery.App)) cenerybench.Serve {
+		gin.SetMode(gin.ReleaseMode)
+		app := New(gin.New())
+		setup(app)
+		return cenerybench.ServeHandler(app.(http.Handler))
+	},
+	Native: nativeScenario,
+}
+
+// nativeScenario implements s with gin directly.
+func nativeScenario(s cenerybench.Scenario) cenerybench.Serve {
+	gin.SetMode(gin.ReleaseMode)
+	r := gin.New()
+	for range s.Middleware {
+		r.Use(func(c *gin.Context) {
+			c.Next()
+		})
+	}
+	switch s.Name {
+	case "static":
+		r.GET("/hello", func(c *gin.Context) {
+			c.String(http.StatusOK, "Hello, World!")
+		})
+	case "param":
+		r.GET("/users/:id/posts/:post", func(c *gin.Context) {
+			c.String(http.StatusOK, c.Param("id")+"/"+c.Param("post"))
+		})
+	case "json-encode":
+		r.GET("/user", func(c *gin.Context) {
+			c.JSON(http.StatusOK, cenerybench.SampleUser)
+		})
+	case "json-decode":
+		r.POST("/user", func(c *gin.Context) {
+			var u cenerybench.User
+			if err := c.ShouldBindJSON(&u); err != nil {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			c.String(http.StatusOK, u.Name)
+		})
+	case "multipart":
+		r.POST("/upload", func(c *gin.Context) {
+			header, err := c.FormFile("file")
+			if err != nil {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			defer file.Close()
+			data, err := io.ReadAll(file)
+			if err != nil {
+				c.String(http.StatusBadRequest, err.Error())
+				return
+			}
+			c.String(http.StatusOK, strconv.Itoa(len(data)))
+		})
+	case "stream":
+		r.GET("/stream", func(c *gin.Context) {
+			c.DataFromReader(http.StatusOK, -1, "application/octet-stream", bytes.NewReader(cenerybench.StreamData), nil)
+		})
+	default:
+		r.GET("/chain", func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+	}
+	return cenerybench.ServeHandler(r)
+}
+
+func TestBenchSuite(t *testing.T) {
+	cenerybench.Verify(t, benchEngine)
+}
+
+func BenchmarkSuite(b *testing.B) {
+	cenerybench.Run(b, benchEngine)
+}
@@ -2,9 +2,12 @@ package gin
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
 	"errors"
 	"io"
+	"io/fs"
+	"net/http"
+	"strconv"
 	"sync"
 	"sync/atomic"
 
@@ -13,16 +16,30 @@ import (
 )
 
 type serverCtx struct {
-	ctx  *gin.Context
-	resp cenery.Response
+	ctx     *gin.Context
+	resp    cenery.Response
+	res     response // backs resp, so a pooled serverCtx allocates nothing
+	state   *requestState
+	pending *timeoutResponse
+	err     error
+	nexted  bool
 }
 
 func NewServerCtx(ctx *gin.Context) cenery.Ctx {
-	resp := NewResponse(ctx)
-	return &serverCtx{
-		ctx:  ctx,
-		resp: resp,
-	}
+	return newServerCtx(ctx)
+}
+
+func newServerCtx(ctx *gin.Context) *serverCtx {
+	s := &serverCtx{}
+	s.reset(ctx, getRequestState(ctx))
+	return s
+}
+
+// reset prepares s for a handler, clearing what the previous one left.
+func (s *serverCtx) reset(ctx *gin.Context, state *requestState) {
+	*s = serverCtx{ctx: ctx, state: state}
+	s.res.init(ctx)
+	s.resp = &s.res
 }
 
 func (s *serverCtx) Params(key string, defaultValue ...string) string {
@@ -45,25 +62,58 @@ func (s *serverCtx) QueryParam(key string, defaultValue ...string) string {
 	return val
 }
 
-func (s *serverCtx) BodyParser(out any) error {
-	return s.ctx.ShouldBind(out)
+func (s *serverCtx) IP() string {
+	return s.ctx.RemoteIP()
 }
 
-func (s *serverCtx) BodyParserStream(out any) error {
-	if s.ctx.Request.Body == nil {
+func (s *serverCtx) BodyParser(out any) error {
+	if cfg := s.state.appConfig(); cfg != nil && cfg.NativeBodyParser {
+		return s.bind(out)
+	}
+	req := s.ctx.Request
+	if req.Body == nil {
 		return errors.New("request body can't be empty")
 	}
-	dec := json.NewDecoder(s.ctx.Request.Body)
-	if err := dec.Decode(out); err != nil {
-		if errors.Is(err, io.EOF) {
-			return nil
+	limitBody(s.ctx)
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return err
+	}
+
+	req.Body = io.NopCloser(bytes.NewBuffer(data))
+	return cenery.DecodeBody(s, s.state.appConfig(), data, out)
+}
+
+// bind decodes the body with gin's ShouldBind for Config.NativeBodyParser.
+func (s *serverCtx) bind(out any) error {
+	limitBody(s.ctx)
+	if err := s.ctx.ShouldBind(out); err != nil {
+		if errors.Is(err, cenery.ErrBodyTooLarge) {
+			return cenery.RequestError(cenery.ErrBodyTooLarge)
 		}
 		return err
 	}
 	return nil
 }
 
+func (s *serverCtx) BodyParserStream(out any) error {
+	if s.ctx.Request.Body == nil {
+		return errors.New("request body can't be empty")
+	}
+	limitBody(s.ctx)
+	return cenery.DecodeJSON(s, s.state.appConfig(), s.ctx.Request.Body, out)
+}
+
+func (s *serverCtx) BodyParserNDJSON(out any, fn func() error) error {
+	if s.ctx.Request.Body == nil {
+		return errors.New("request body can't be empty")
+	}
+	limitBody(s.ctx)
+	return cenery.DecodeNDJSON(s, s.state.appConfig(), s.ctx.Request.Body, out, fn)
+}
+
 func (s *serverCtx) BodyStream() io.ReadCloser {
+	limitBody(s.ctx)
 	return s.ctx.Request.Body
 }
 
@@ -75,6 +125,14 @@ func (s *serverCtx) FormFiles(fileKey string) *[]cenery.FileData {
 	return FormFiles(s.ctx, fileKey)
 }
 
+func (s *serverCtx) FormFileE(fileKey string) (*cenery.FileData, error) {
+	return FormFileE(s.ctx, fileKey)
+}
+
+func (s *serverCtx) FormFilesE(fileKey string) ([]cenery.FileData, error) {
+	return FormFilesE(s.ctx, fileKey)
+}
+
 func (s *serverCtx) FormFileStream(fileKey string) (*cenery.FileStream, error) {
 	return FormFileStream(s.ctx, fileKey)
 }
@@ -83,6 +141,36 @@ func (s *serverCtx) FormFilesStream(fileKey string) ([]*cenery.FileStream, error
 	return FormFilesStream(s.ctx, fileKey)
 }
 
+func (s *serverCtx) FormValue(key string, defaultValue ...string) string {
+	var val string
+	if values, _ := formValues(s.ctx); len(values[key]) > 0 {
+		val = values[key][0]
+	}
+	if len(defaultValue) == 1 {
+		if val == "" {
+			val = defaultValue[0]
+		}
+	}
+	return val
+}
+
+func (s *serverCtx) FormValues(key string) []string {
+	values, _ := formValues(s.ctx)
+	return values[key]
+}
+
+func (s *serverCtx) MultipartForm() (*cenery.MultipartForm, error) {
+	form, err := multipartForm(s.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &cenery.MultipartForm{Value: form.Value, File: form.File}, nil
+}
+
+func (s *serverCtx) MultipartReader() (*cenery.MultipartReader, error) {
+	return multipartReader(s.ctx)
+}
+
 var enableSendBufferPooling atomic.Bool
 
 // EnableSendBufferPooling toggles pooling for Send() to reuse buffers
@@ -122,17 +210,113 @@ func (s *serverCtx) Send(status int, data []byte) error {
 }
 
 func (s *serverCtx) SendJSON(status int, data any) error {
-	s.ctx.JSON(status, data)
-	return nil
+	payload, err := s.state.appConfig().JSONCodec().Marshal(data)
+	if err != nil {
+		return err
+	}
+	s.ctx.Header("Content-Type", "application/json")
+	return s.Send(status, payload)
+}
+
+func (s *serverCtx) Negotiate(status int, data any) error {
+	return cenery.Negotiate(s, s.state.appConfig(), status, data)
+}
+
+func (s *serverCtx) SendJSONStream(status int, items any, format ...cenery.JSONStreamFormat) error {
+	write, err := cenery.JSONStream(s, s.state.appConfig(), items, format...)
+	if err != nil {
+		return err
+	}
+	s.ctx.Status(status)
+	writer := s.ctx.Writer
+	flush := func() error {
+		writer.Flush()
+		return nil
+	}
+	return write(s.ctx.Request.Context(), writer, flush)
 }
 
 func (s *serverCtx) SendStream(status int, contentType string, reader io.Reader) error {
+	return s.SendStreamSized(status, contentType, reader, cenery.ReaderSize(reader))
+}
+
+func (s *serverCtx) SendStreamSized(status int, contentType string, reader io.Reader, size int64) error {
 	s.ctx.Header("Content-Type", contentType)
+	if size >= 0 {
+		s.ctx.Header("Content-Length", strconv.FormatInt(size, 10))
+	}
 	s.ctx.Status(status)
 	_, err := io.Copy(s.ctx.Writer, reader)
 	return err
 }
 
+func (s *serverCtx) SendFile(path string) error {
+	return cenery.SendFile(s, path)
+}
+
+func (s *serverCtx) SendFileFS(fsys fs.FS, name string) error {
+	return cenery.SendFileFS(s, fsys, name)
+}
+
+func (s *serverCtx) Download(path, filename string) error {
+	return cenery.Download(s, path, filename)
+}
+
+func (s *serverCtx) SaveFile(file *cenery.FileStream, path string) error {
+	return cenery.SaveFile(s, file, path)
+}
+
+func (s *serverCtx) Redirect(status int, location string) error {
+	return cenery.Redirect(s, s.state.appConfig(), status, location)
+}
+
+func (s *serverCtx) RedirectToRoute(name string, params map[string]string) error {
+	return cenery.RedirectToRoute(s, s.state.appConfig(), name, params)
+}
+
+func (s *serverCtx) RedirectBack(fallback string) error {
+	return cenery.RedirectBack(s, s.state.appConfig(), fallback)
+}
+
+func (s *serverCtx) SSE(fn func(w cenery.EventWriter) error) error {
+	cenery.SetEventStreamHeaders(s.resp)
+	s.ctx.Status(http.StatusOK)
+	writer := s.ctx.Writer
+	flush := func() error {
+		writer.Flush()
+		return nil
+	}
+	writer.Flush()
+	req := s.ctx.Request
+	w, cancel := cenery.NewEventWriter(req.Context(), writer, flush, req.Header.Get("Last-Event-ID"))
+	defer cancel()
+	if err := fn(w); err != nil && w.Context().Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func (s *serverCtx) Locals(key string, value ...any) any {
+	if len(value) > 0 {
+		s.ctx.Set(key, value[0])
+		return value[0]
+	}
+	val, _ := s.ctx.Get(key)
+	return val
+}
+
+func (s *serverCtx) Context() context.Context {
+	return s.ctx.Request.Context()
+}
+
+func (s *serverCtx) SetContext(ctx context.Context) {
+	s.ctx.Request = s.ctx.Request.WithContext(ctx)
+}
+
+func (s *serverCtx) SetLimits(l cenery.Limits) {
+	s.state.setLimits(requestLimits(s.ctx).Merge(l))
+}
+
 func (s *serverCtx) Request() cenery.Request {
 	return NewRequest(s.ctx.Request)
 }
@@ -142,9 +326,76 @@ func (s *serverCtx) Response() cenery.Response {
 }
 
 func (s *serverCtx) Next() error {
+	s.nexted = true
+	n := len(s.ctx.Errors)
 	s.ctx.Next()
-	if len(s.ctx.Errors) > 0 {
-		return s.ctx.Errors.Last()
+	if len(s.ctx.Errors) > n {
+		return s.ctx.Errors.Last().Err
 	}
 	return nil
 }
+
+// NextTimeout runs the rest of the chain on its own goroutine, writing into
+// a buffer. If ctx is done first, the buffer is discarded and the error
+// response rendered by the caller is sent in its place. The request is not
+// released until the chain returns, since gin pools its contexts.
+func (s *serverCtx) NextTimeout(ctx context.Context) error {
+	s.nexted = true
+	c := s.ctx
+	n := len(c.Errors)
+	c.Request = c.Request.WithContext(ctx)
+	detached := c.Copy()
+	w := c.Writer
+	tw := newTimeoutWriter(w)
+	c.Writer = tw
+	s.state.detach()
+
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				tw.panicVal = p
+			}
+			close(done)
+		}()
+		c.Next()
+	}()
+
+	select {
+	case <-done:
+		c.Writer = w
+		if tw.panicVal != nil {
+			panic(tw.panicVal)
+		}
+		tw.writeTo(w)
+		if len(c.Errors) > n {
+			return c.Errors.Last().Err
+		}
+		return nil
+	case <-ctx.Done():
+		tw.discard()
+		buf := newTimeoutWriter(w)
+		s.pending = &timeoutResponse{w: w, buf: buf, done: done, ctx: c}
+		detached.Writer = buf
+		s.ctx = detached
+		s.resp = NewResponse(detached)
+		return ctx.Err()
+	}
+}
+
+// release finishes the response of a handler that returned err.
+func (s *serverCtx) release() {
+	c := s.ctx
+	if s.pending != nil {
+		s.pending.finish()
+		c = s.pending.ctx
+	}
+	if s.err != nil {
+		_ = c.Error(s.err)
+	}
+	// gin runs the remaining handlers on its own unless aborted, while the
+	// other engines stop at a middleware that does not call Next.
+	if s.err != nil || !s.nexted {
+		c.Abort()
+	}
+}
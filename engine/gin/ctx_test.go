@@ -2,15 +2,32 @@ package gin
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/dreamph/cenery"
+	"github.com/dreamph/cenery/cenerytest"
+	"github.com/dreamph/cenery/middleware/timeout"
+	"github.com/dreamph/cenery/tus"
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
 func TestParams(t *testing.T) {
@@ -103,6 +120,43 @@ func TestBodyParser(t *testing.T) {
 	}
 }
 
+func TestBodyParserBinding(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	handler := func(c cenery.Ctx) error {
+		var in struct {
+			Name string `json:"name" binding:"required"`
+		}
+		if err := c.BodyParser(&in); err != nil {
+			return cenery.NewError(http.StatusBadRequest, err.Error())
+		}
+		return c.SendString(http.StatusOK, "ok "+in.Name)
+	}
+	native, codec := gin.New(), gin.New()
+	New(native, cenery.WithNativeBodyParser()).Post("/users", handler)
+	New(codec).Post("/users", handler)
+
+	tests := []struct {
+		name       string
+		server     *gin.Engine
+		body       string
+		wantStatus int
+	}{
+		{"ShouldBind", native, `{"name":"a"}`, http.StatusOK},
+		{"ShouldBind validates", native, `{}`, http.StatusBadRequest},
+		{"codecs", codec, `{"name":"a"}`, http.StatusOK},
+		{"codecs skip binding tags", codec, `{}`, http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		tt.server.ServeHTTP(rec, req)
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%v: status = %v, want %v; body %q", tt.name, rec.Code, tt.wantStatus, rec.Body.String())
+		}
+	}
+}
+
 func TestBodyParserStream(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	rec := httptest.NewRecorder()
@@ -256,6 +310,1250 @@ func TestResponseHeaders(t *testing.T) {
 	}
 }
 
+func TestIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	ctx := NewServerCtx(c)
+
+	if got := ctx.IP(); got != "192.0.2.1" {
+		t.Errorf("IP() = %v, want %v", got, "192.0.2.1")
+	}
+}
+
+func TestRequestInfo(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "https://example.com/path", nil)
+
+	r := NewRequest(req)
+	if r.Method() != http.MethodPost || r.Host() != "example.com" || r.Scheme() != "https" {
+		t.Errorf("Method/Host/Scheme = %v %v %v, want POST example.com https", r.Method(), r.Host(), r.Scheme())
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	a.Get("/teapot", func(c cenery.Ctx) error {
+		return c.Next()
+	}, func(c cenery.Ctx) error {
+		return cenery.NewError(http.StatusTeapot)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/teapot", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusTeapot)
+	}
+	if rec.Body.String() != "I'm a teapot" {
+		t.Fatalf("body = %v, want %v", rec.Body.String(), "I'm a teapot")
+	}
+}
+
+func TestCustomErrorHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server, cenery.WithErrorHandler(func(c cenery.Ctx, err error) error {
+		return c.SendJSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+	})).(*app)
+	a.Get("/", func(c cenery.Ctx) error {
+		return errors.New("upstream failed")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadGateway {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusBadGateway)
+	}
+	if !strings.Contains(rec.Body.String(), `"error":"upstream failed"`) {
+		t.Fatalf("body = %v, want to contain error", rec.Body.String())
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	a.Get("/slow", timeout.New(timeout.Config{Timeout: 20 * time.Millisecond}), func(c cenery.Ctx) error {
+		time.Sleep(100 * time.Millisecond)
+		c.Response().SetHeader("X-Late", "1")
+		return c.SendString(http.StatusOK, "late")
+	})
+	a.Get("/fast", timeout.New(timeout.Config{Timeout: time.Second}), func(c cenery.Ctx) error {
+		if _, ok := c.Context().Deadline(); !ok {
+			return c.SendString(http.StatusInternalServerError, "no deadline")
+		}
+		return c.SendString(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+	}
+	if rec.Body.String() != "Service Unavailable" {
+		t.Fatalf("body = %v, want %v", rec.Body.String(), "Service Unavailable")
+	}
+	if rec.Header().Get("X-Late") != "" {
+		t.Fatalf("late header was written")
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/fast", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != "ok" {
+		t.Fatalf("fast response = %v %v, want %v %v", rec.Code, rec.Body.String(), http.StatusOK, "ok")
+	}
+}
+
+func TestTimeoutLocals(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server, cenery.WithErrorHandler(func(c cenery.Ctx, err error) error {
+		c.Locals("error", err)
+		return cenery.DefaultErrorHandler(c, err)
+	})).(*app)
+	a.Get("/slow", timeout.New(timeout.Config{Timeout: 5 * time.Millisecond}), func(c cenery.Ctx) error {
+		<-c.Context().Done()
+		for i := range 100 {
+			c.Locals("late", i)
+		}
+		return errors.New("late")
+	})
+
+	for range 10 {
+		req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		if rec.Code != http.StatusServiceUnavailable {
+			t.Fatalf("status = %v, want %v", rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server, cenery.WithBodyLimit(16)).(*app)
+	a.Post("/json", func(c cenery.Ctx) error {
+		var out map[string]any
+		if err := c.BodyParser(&out); err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, "ok")
+	})
+	a.Post("/stream", func(c cenery.Ctx) error {
+		if _, err := io.ReadAll(c.BodyStream()); err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, "ok")
+	})
+	a.Post("/large", cenery.RouteLimits(cenery.Limits{BodyLimit: 1 << 10}), func(c cenery.Ctx) error {
+		var out map[string]any
+		if err := c.BodyParser(&out); err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, "ok")
+	})
+
+	large := `{"name":"` + strings.Repeat("x", 64) + `"}`
+	tests := []struct {
+		path string
+		body io.Reader
+		want int
+	}{
+		{"/json", strings.NewReader(`{"a":1}`), http.StatusOK},
+		{"/json", strings.NewReader(large), http.StatusRequestEntityTooLarge},
+		// No Content-Length: the limit is hit while reading.
+		{"/stream", io.MultiReader(strings.NewReader(large)), http.StatusRequestEntityTooLarge},
+		{"/large", strings.NewReader(large), http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, tt.body)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%v status = %v, want %v", tt.path, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestMultipartLimits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server, cenery.WithLimits(cenery.Limits{MaxFiles: 1, MaxFileSize: 8})).(*app)
+	a.Post("/upload", func(c cenery.Ctx) error {
+		files, err := c.FormFilesStream("file")
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			_ = f.File.Close()
+		}
+		return c.SendString(http.StatusOK, "ok")
+	})
+	a.Post("/none", cenery.RouteLimits(cenery.Limits{MaxFiles: cenery.NoFiles}), func(c cenery.Ctx) error {
+		_, err := c.FormFilesStream("file")
+		return err
+	})
+	// A handler that changes the error it got does not change it for the
+	// requests that follow.
+	a.Post("/changed", cenery.RouteLimits(cenery.Limits{MaxFiles: cenery.NoFiles}), func(c cenery.Ctx) error {
+		_, err := c.FormFilesStream("file")
+		var e *cenery.Error
+		if errors.As(err, &e) {
+			e.Code = http.StatusInternalServerError
+		}
+		return err
+	})
+
+	tests := []struct {
+		name  string
+		path  string
+		files []string
+		want  int
+	}{
+		{"one file", "/upload", []string{"small"}, http.StatusOK},
+		{"too many files", "/upload", []string{"a", "b"}, http.StatusRequestEntityTooLarge},
+		{"file too large", "/upload", []string{"more than eight bytes"}, http.StatusRequestEntityTooLarge},
+		{"changed error", "/changed", []string{"small"}, http.StatusInternalServerError},
+		{"no files allowed", "/none", []string{"small"}, http.StatusRequestEntityTooLarge},
+		{"no files sent", "/none", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		for _, content := range tt.files {
+			part, _ := writer.CreateFormFile("file", "test.txt")
+			io.WriteString(part, content)
+		}
+		writer.Close()
+
+		req := httptest.NewRequest(http.MethodPost, tt.path, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%v status = %v, want %v", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	// The limits are checked as the parts arrive, before the rest of the
+	// body is read and stored.
+	for _, files := range []int{1, 2} {
+		head := &bytes.Buffer{}
+		writer := multipart.NewWriter(head)
+		for i := range files {
+			part, _ := writer.CreateFormFile("file", "test.txt")
+			if i < files-1 {
+				io.WriteString(part, "a")
+			}
+		}
+		rest := strings.NewReader(strings.Repeat("x", 2<<20))
+		req := httptest.NewRequest(http.MethodPost, "/upload", io.MultiReader(head, rest))
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusRequestEntityTooLarge || rest.Len() < 1<<20 {
+			t.Errorf("%v files: status = %v, %v bytes left unread, want 413 before the body is read", files, rec.Code, rest.Len())
+		}
+	}
+}
+
+func TestLocals(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	a.Get("/", func(c cenery.Ctx) error {
+		c.Locals("user", "alice")
+		return c.Next()
+	}, func(c cenery.Ctx) error {
+		user, _ := c.Locals("user").(string)
+		return c.SendString(http.StatusOK, user)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "alice" {
+		t.Errorf("Locals() = %v, want %v", rec.Body.String(), "alice")
+	}
+}
+
+func TestSSE(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	a.Get("/events", func(c cenery.Ctx) error {
+		return c.SSE(func(w cenery.EventWriter) error {
+			_ = w.Retry(time.Second)
+			_ = w.Event(w.LastEventID(), "update", "a\nb")
+			return w.Comment("ping")
+		})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "7")
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	want := "retry: 1000\n\nid: 7\nevent: update\ndata: a\ndata: b\n\n: ping\n"
+	if rec.Body.String() != want {
+		t.Errorf("SSE() body = %q, want %q", rec.Body.String(), want)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %v, want %v", ct, "text/event-stream")
+	}
+	if !rec.Flushed {
+		t.Errorf("SSE() did not flush")
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	a.WebSocket("/ws/:room", func(conn cenery.WSConn) error {
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				if cenery.IsWSCloseError(err, cenery.CloseNormalClosure) {
+					return nil
+				}
+				return err
+			}
+			if string(msg) == "bye" {
+				return &cenery.WSCloseError{Code: 4000, Text: "bye"}
+			}
+			reply := conn.Params("room") + ":" + conn.Subprotocol() + ":" + string(msg)
+			if err := conn.WriteMessage(mt, []byte(reply)); err != nil {
+				return err
+			}
+		}
+	}, cenery.WSConfig{Subprotocols: []string{"chat"}})
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	dialer := &websocket.Dialer{Subprotocols: []string{"chat"}}
+	conn, _, err := dialer.Dial(url+"/ws/lobby", nil)
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != "chat" {
+		t.Errorf("Subprotocol() = %v, want %v", conn.Subprotocol(), "chat")
+	}
+
+	_ = conn.WriteMessage(websocket.TextMessage, []byte("hi"))
+	mt, msg, err := conn.ReadMessage()
+	if err != nil || mt != websocket.TextMessage || string(msg) != "lobby:chat:hi" {
+		t.Errorf("ReadMessage() = %v, %q, %v, want %q", mt, msg, err, "lobby:chat:hi")
+	}
+
+	_ = conn.WriteMessage(websocket.TextMessage, []byte("bye"))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, 4000) {
+		t.Errorf("ReadMessage() error = %v, want close 4000", err)
+	}
+
+	_, resp, err := dialer.Dial(url+"/ws/lobby", http.Header{"Origin": {"http://evil.example"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("Dial() from foreign origin error = %v, want 403", err)
+	}
+}
+
+func TestWebSocketHandshakeError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	var handled []error
+	a := New(server, cenery.WithErrorHandler(func(c cenery.Ctx, err error) error {
+		handled = append(handled, err)
+		return cenery.DefaultErrorHandler(c, err)
+	})).(*app)
+	a.Use(timeout.New(timeout.Config{Timeout: time.Second}))
+	a.WebSocket("/ws", func(conn cenery.WSConn) error {
+		t.Error("handler ran behind the timeout middleware")
+		return nil
+	})
+
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Dial() behind the timeout error = %v, want 500", err)
+	}
+	resp, err = http.Get(ts.URL + "/ws")
+	if err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("GET without upgrade = %v, want 400", resp.StatusCode)
+	}
+	if len(handled) != 2 {
+		t.Errorf("error handler calls = %v, want 2", handled)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"index.html":  {Data: []byte("<h1>home</h1>"), ModTime: modTime},
+		"app.js":      {Data: []byte("console.log(1)"), ModTime: modTime},
+		"app.js.br":   {Data: []byte("brotli"), ModTime: modTime},
+		"docs/a.txt":  {Data: []byte("a"), ModTime: modTime},
+		"docs/b.data": {Data: []byte("\x89PNG\r\n\x1a\n"), ModTime: modTime},
+	}
+	root := t.TempDir()
+	_ = os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0o600)
+	_ = os.Mkdir(filepath.Join(root, "public"), 0o755)
+	_ = os.WriteFile(filepath.Join(root, "public", "hello.txt"), []byte("hello"), 0o600)
+	if err := os.Symlink(filepath.Join(root, "secret.txt"), filepath.Join(root, "public", "leak.txt")); err != nil {
+		t.Fatalf("Symlink() error = %v", err)
+	}
+	a.StaticFS("/static", fsys, cenery.StaticConfig{Browse: true, MaxAge: time.Hour, Precompressed: true})
+	a.Static("/files/", filepath.Join(root, "public"))
+
+	etag := `"e-` + strconv.FormatInt(modTime.UnixNano(), 16) + `"`
+	tests := []struct {
+		path       string
+		header     [2]string
+		wantStatus int
+		wantBody   string
+		wantHeader [2]string
+	}{
+		{"/static", [2]string{}, http.StatusMovedPermanently, "", [2]string{"Location", "/static/"}},
+		{"/static/", [2]string{}, http.StatusOK, "<h1>home</h1>", [2]string{"Content-Type", "text/html; charset=utf-8"}},
+		{"/static/app.js", [2]string{}, http.StatusOK, "console.log(1)", [2]string{"ETag", etag}},
+		{"/static/app.js", [2]string{"Accept-Encoding", "gzip, br"}, http.StatusOK, "brotli", [2]string{"Content-Encoding", "br"}},
+		{"/static/app.js", [2]string{"If-None-Match", etag}, http.StatusNotModified, "", [2]string{"Cache-Control", "public, max-age=3600"}},
+		{"/static/app.js", [2]string{"If-Modified-Since", modTime.Format(http.TimeFormat)}, http.StatusNotModified, "", [2]string{}},
+		{"/static/docs", [2]string{}, http.StatusMovedPermanently, "", [2]string{"Location", "/static/docs/"}},
+		{"/static/docs/", [2]string{}, http.StatusOK, `<a href="a.txt">a.txt</a>`, [2]string{}},
+		{"/static/docs/b.data", [2]string{}, http.StatusOK, "\x89PNG", [2]string{"Content-Type", "image/png"}},
+		{"/static/missing.txt", [2]string{}, http.StatusNotFound, "", [2]string{}},
+		{"/files/hello.txt", [2]string{}, http.StatusOK, "hello", [2]string{}},
+		{"/files/../secret.txt", [2]string{}, http.StatusNotFound, "", [2]string{}},
+		{"/files/%2e%2e/secret.txt", [2]string{}, http.StatusNotFound, "", [2]string{}},
+		{"/files/leak.txt", [2]string{}, http.StatusNotFound, "", [2]string{}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header[0] != "" {
+			req.Header.Set(tt.header[0], tt.header[1])
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, body, header := rec.Code, rec.Body.String(), rec.Header().Get
+		if status != tt.wantStatus || !strings.Contains(body, tt.wantBody) {
+			t.Errorf("GET %v = %v %q, want %v %q", tt.path, status, body, tt.wantStatus, tt.wantBody)
+		}
+		if tt.wantHeader[0] != "" && header(tt.wantHeader[0]) != tt.wantHeader[1] {
+			t.Errorf("GET %v %v = %v, want %v", tt.path, tt.wantHeader[0], header(tt.wantHeader[0]), tt.wantHeader[1])
+		}
+	}
+}
+
+func TestSendFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	root := t.TempDir()
+	file := filepath.Join(root, "data.txt")
+	_ = os.WriteFile(file, []byte("0123456789abcdef"), 0o600)
+	a.Get("/file", func(c cenery.Ctx) error { return c.SendFile(file) })
+	a.Get("/download", func(c cenery.Ctx) error { return c.Download(file, "résumé.txt") })
+	a.Get("/fs", func(c cenery.Ctx) error {
+		return c.SendFileFS(fstest.MapFS{"a.json": {Data: []byte(`{}`)}}, "a.json")
+	})
+
+	tests := []struct {
+		path       string
+		header     [2]string
+		wantStatus int
+		wantBody   string
+		wantHeader [2]string
+	}{
+		{"/file", [2]string{}, http.StatusOK, "0123456789abcdef", [2]string{"Accept-Ranges", "bytes"}},
+		{"/file", [2]string{"Range", "bytes=2-5"}, http.StatusPartialContent, "2345", [2]string{"Content-Range", "bytes 2-5/16"}},
+		{"/file", [2]string{"Range", "bytes=-3"}, http.StatusPartialContent, "def", [2]string{"Content-Range", "bytes 13-15/16"}},
+		{"/file", [2]string{"Range", "bytes=20-"}, http.StatusRequestedRangeNotSatisfiable, "", [2]string{"Content-Range", "bytes */16"}},
+		{"/file", [2]string{"Range", "bytes=0-1,4-5"}, http.StatusPartialContent, "Content-Range: bytes 4-5/16\r\n\r\n45\r\n", [2]string{}},
+		{"/file", [2]string{"Range", "bytes=0-3,2-5"}, http.StatusOK, "0123456789abcdef", [2]string{}},
+		{"/file", [2]string{"Range", "bytes=" + strings.Repeat("20-,", 40) + "0-1"}, http.StatusOK, "0123456789abcdef", [2]string{}},
+		{"/download", [2]string{}, http.StatusOK, "0123", [2]string{"Content-Disposition", `attachment; filename="r_sum_.txt"; filename*=UTF-8''r%C3%A9sum%C3%A9.txt`}},
+		{"/fs", [2]string{}, http.StatusOK, "{}", [2]string{"Content-Type", "application/json"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.header[0] != "" {
+			req.Header.Set(tt.header[0], tt.header[1])
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, body, header := rec.Code, rec.Body.String(), rec.Header().Get
+		if status != tt.wantStatus || !strings.Contains(body, tt.wantBody) {
+			t.Errorf("GET %v %v = %v %q, want %v %q", tt.path, tt.header[1], status, body, tt.wantStatus, tt.wantBody)
+		}
+		if tt.wantHeader[0] != "" && header(tt.wantHeader[0]) != tt.wantHeader[1] {
+			t.Errorf("GET %v %v %v = %v, want %v", tt.path, tt.header[1], tt.wantHeader[0], header(tt.wantHeader[0]), tt.wantHeader[1])
+		}
+	}
+}
+
+func TestSendStreamSized(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	file := filepath.Join(t.TempDir(), "data.bin")
+	_ = os.WriteFile(file, []byte("0123456789"), 0o600)
+	a.Get("/sized", func(c cenery.Ctx) error {
+		return c.SendStreamSized(http.StatusOK, "text/plain", io.MultiReader(strings.NewReader("hello")), 5)
+	})
+	a.Get("/file", func(c cenery.Ctx) error {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		return c.SendStream(http.StatusOK, "application/octet-stream", f)
+	})
+
+	tests := []struct {
+		path       string
+		wantBody   string
+		wantLength string
+	}{
+		{"/sized", "hello", "5"},
+		{"/file", "0123456789", "10"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		body, length := rec.Body.String(), rec.Header().Get("Content-Length")
+		if body != tt.wantBody || length != tt.wantLength {
+			t.Errorf("GET %v = %q with Content-Length %v, want %q with %v", tt.path, body, length, tt.wantBody, tt.wantLength)
+		}
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server, cenery.WithRedirect(cenery.RedirectConfig{AllowedHosts: []string{"*.example.org"}})).(*app)
+	a.Get("/users/:id", func(c cenery.Ctx) error { return c.SendString(http.StatusOK, c.Params("id")) }).Name("user")
+	a.Get("/go", func(c cenery.Ctx) error { return c.Redirect(0, c.QueryParam("to")) })
+	a.Post("/go", func(c cenery.Ctx) error { return c.Redirect(0, "/done") })
+	a.Get("/keep", func(c cenery.Ctx) error { return c.Redirect(http.StatusTemporaryRedirect, "/x") })
+	a.Get("/named", func(c cenery.Ctx) error {
+		return c.RedirectToRoute("user", map[string]string{"id": "a b"})
+	})
+	a.Get("/back", func(c cenery.Ctx) error { return c.RedirectBack("/home") })
+
+	tests := []struct {
+		method       string
+		path         string
+		referer      string
+		wantStatus   int
+		wantLocation string
+	}{
+		{http.MethodGet, "/go?to=/home", "", http.StatusFound, "/home"},
+		{http.MethodGet, "/go?to=//evil.com", "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/go?to=/%5Cevil.com", "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/go?to=https://evil.com", "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/go?to=javascript:alert(1)", "", http.StatusBadRequest, ""},
+		{http.MethodGet, "/go?to=https://api.example.org/x", "", http.StatusFound, "https://api.example.org/x"},
+		{http.MethodGet, "/go?to=http://example.com/x", "", http.StatusFound, "http://example.com/x"},
+		{http.MethodPost, "/go", "", http.StatusSeeOther, "/done"},
+		{http.MethodGet, "/keep", "", http.StatusTemporaryRedirect, "/x"},
+		{http.MethodGet, "/named", "", http.StatusFound, "/users/a%20b"},
+		{http.MethodGet, "/back", "http://example.com/prev", http.StatusFound, "http://example.com/prev"},
+		{http.MethodGet, "/back", "https://evil.com/", http.StatusFound, "/home"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		if tt.referer != "" {
+			req.Header.Set("Referer", tt.referer)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, location := rec.Code, rec.Header().Get("Location")
+		if status != tt.wantStatus || location != tt.wantLocation {
+			t.Errorf("%v %v = %v %q, want %v %q", tt.method, tt.path, status, location, tt.wantStatus, tt.wantLocation)
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	type item struct {
+		XMLName xml.Name `json:"-" xml:"item"`
+		Name    string   `json:"name" xml:"name" form:"name"`
+	}
+	a := New(server).(*app)
+	a.Get("/item", func(c cenery.Ctx) error { return c.Negotiate(http.StatusOK, item{Name: "a"}) })
+	a.Post("/item", func(c cenery.Ctx) error {
+		var in item
+		if err := c.BodyParser(&in); err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, in.Name)
+	})
+
+	tests := []struct {
+		method     string
+		header     [2]string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{http.MethodGet, [2]string{}, "", http.StatusOK, `{"name":"a"}`},
+		{http.MethodGet, [2]string{"Accept", "application/xml"}, "", http.StatusOK, "<item><name>a</name></item>"},
+		{http.MethodGet, [2]string{"Accept", "text/html;q=0.9, application/xml;q=0.5, */*;q=0.1"}, "", http.StatusOK, "<item><name>a</name></item>"},
+		{http.MethodGet, [2]string{"Accept", "application/json;q=0, */*"}, "", http.StatusOK, "<item><name>a</name></item>"},
+		{http.MethodGet, [2]string{"Accept", "text/html"}, "", http.StatusNotAcceptable, "Not Acceptable"},
+		{http.MethodPost, [2]string{"Content-Type", "application/xml"}, "<item><name>x</name></item>", http.StatusOK, "x"},
+		{http.MethodPost, [2]string{"Content-Type", "application/x-www-form-urlencoded"}, "name=f", http.StatusOK, "f"},
+		{http.MethodPost, [2]string{"Content-Type", "application/problem+json"}, `{"name":"p"}`, http.StatusOK, "p"},
+		{http.MethodPost, [2]string{"Content-Type", "text/csv"}, "name\nc", http.StatusUnsupportedMediaType, "Unsupported Media Type"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, "/item", strings.NewReader(tt.body))
+		if tt.header[0] != "" {
+			req.Header.Set(tt.header[0], tt.header[1])
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, body := rec.Code, rec.Body.String()
+		if status != tt.wantStatus || body != tt.wantBody {
+			t.Errorf("%v %v = %v %q, want %v %q", tt.method, tt.header, status, body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+func TestJSONCodec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	var calls []string
+	codec := cenery.NewJSONCodec(
+		func(v any) ([]byte, error) { calls = append(calls, "Marshal"); return json.Marshal(v) },
+		func(data []byte, v any) error { calls = append(calls, "Unmarshal"); return json.Unmarshal(data, v) },
+		func(w io.Writer) *json.Encoder { calls = append(calls, "NewEncoder"); return json.NewEncoder(w) },
+		func(r io.Reader) *json.Decoder { calls = append(calls, "NewDecoder"); return json.NewDecoder(r) },
+	)
+	a := New(server, cenery.WithJSONCodec(codec)).(*app)
+	a.Post("/parse", func(c cenery.Ctx) error {
+		var in map[string]any
+		if err := c.BodyParser(&in); err != nil {
+			return err
+		}
+		return c.SendJSON(http.StatusOK, in)
+	})
+	a.Post("/stream", func(c cenery.Ctx) error {
+		var in map[string]any
+		if err := c.BodyParserStream(&in); err != nil {
+			return err
+		}
+		return c.Negotiate(http.StatusOK, in)
+	})
+
+	tests := []struct {
+		path      string
+		wantCalls string
+	}{
+		{"/parse", "Unmarshal,Marshal"},
+		{"/stream", "NewDecoder,Marshal"},
+	}
+	for _, tt := range tests {
+		calls = nil
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(`{"a":1}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		body := rec.Body.String()
+		if body != `{"a":1}` || strings.Join(calls, ",") != tt.wantCalls {
+			t.Errorf("POST %v = %q calling %v, want %v", tt.path, body, calls, tt.wantCalls)
+		}
+	}
+}
+
+func TestJSONDecodeOptions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	strict := cenery.JSONDecodeOptions{
+		DisallowUnknownFields: true,
+		UseNumber:             true,
+		DisallowTrailingData:  true,
+		MaxDepth:              3,
+		DisallowDuplicateKeys: true,
+	}
+	a := New(server, cenery.WithJSONDecodeOptions(cenery.JSONDecodeOptions{DisallowUnknownFields: true})).(*app)
+	handler := func(stream bool) cenery.Handler {
+		return func(c cenery.Ctx) error {
+			var in struct {
+				Name  string `json:"name"`
+				Items []struct {
+					Qty int `json:"qty"`
+				} `json:"items"`
+				Meta any `json:"meta"`
+			}
+			parse := c.BodyParser
+			if stream {
+				parse = c.BodyParserStream
+			}
+			if err := parse(&in); err != nil {
+				return err
+			}
+			if _, ok := in.Meta.(json.Number); ok {
+				return c.SendString(http.StatusOK, in.Name+" number")
+			}
+			return c.SendString(http.StatusOK, in.Name)
+		}
+	}
+	a.Post("/app", handler(false))
+	a.Post("/loose", cenery.RouteJSONOptions(cenery.JSONDecodeOptions{}), handler(false))
+	a.Post("/strict", cenery.RouteJSONOptions(strict), handler(false))
+	a.Post("/stream", cenery.RouteJSONOptions(strict), handler(true))
+
+	tests := []struct {
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/app", `{"name":"a","extra":1}`, http.StatusBadRequest, `field "extra": unknown field`},
+		{"/loose", `{"name":"a","extra":1}`, http.StatusOK, "a"},
+		{"/strict", `{"name":"a","meta":1}`, http.StatusOK, "a number"},
+		{"/strict", `{"name":"a"} {"name":"b"}`, http.StatusBadRequest, "unexpected data after the JSON value"},
+		{"/strict", `{"name":"a","name":"b"}`, http.StatusBadRequest, `field "name": duplicate key`},
+		{"/strict", `{"meta":[[[1]]]}`, http.StatusBadRequest, `field "meta[0][0]": nested deeper than 3 levels`},
+		{"/strict", `{"items":[{"qty":"x"}]}`, http.StatusBadRequest, `field "items[0].qty": expected int, got string`},
+		{"/strict", `{"items":[{"qty":1,"x":2}]}`, http.StatusBadRequest, `field "items[0].x": unknown field`},
+		{"/strict", `{"NAME":"a","meta":{"x":1}}`, http.StatusOK, "a"},
+		{"/strict", `{"name":`, http.StatusBadRequest, "unexpected end of JSON"},
+		{"/stream", `{"name":"a"} garbage`, http.StatusBadRequest, "unexpected data after the JSON value"},
+		{"/stream", `{"name":"a","extra":1}`, http.StatusBadRequest, `field "extra": unknown field`},
+		{"/stream", `{"name":"a","name":"b"}`, http.StatusBadRequest, `field "name": duplicate key`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, body := rec.Code, rec.Body.String()
+		if status != tt.wantStatus || body != tt.wantBody {
+			t.Errorf("POST %v %v = %v %q, want %v %q", tt.path, tt.body, status, body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+func TestJSONStream(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	type item struct {
+		N int `json:"n"`
+	}
+	a := New(server).(*app)
+	a.Get("/array", func(c cenery.Ctx) error {
+		seq := func(yield func(int) bool) {
+			for i := 1; i <= 3; i++ {
+				if !yield(i) {
+					return
+				}
+			}
+		}
+		return c.SendJSONStream(http.StatusOK, seq)
+	})
+	a.Get("/ndjson", func(c cenery.Ctx) error {
+		ch := make(chan item)
+		go func() {
+			defer close(ch)
+			for i := 1; i <= 2; i++ {
+				ch <- item{N: i}
+			}
+		}()
+		return c.SendJSONStream(http.StatusOK, ch, cenery.NDJSON)
+	})
+	a.Get("/bad", func(c cenery.Ctx) error {
+		return c.SendJSONStream(http.StatusOK, 42)
+	})
+	a.Get("/unsupported", func(c cenery.Ctx) error {
+		seq := func(yield func(any) bool) {
+			if yield(1) {
+				yield(make(chan int))
+			}
+		}
+		return c.SendJSONStream(http.StatusOK, seq)
+	})
+	a.Post("/ndjson", func(c cenery.Ctx) error {
+		var in item
+		sum := 0
+		if err := c.BodyParserNDJSON(&in, func() error {
+			sum += in.N
+			return nil
+		}); err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, strconv.Itoa(sum))
+	})
+
+	tests := []struct {
+		method          string
+		path            string
+		body            string
+		wantStatus      int
+		wantContentType string
+		wantBody        string
+	}{
+		{http.MethodGet, "/array", "", http.StatusOK, "application/json", "[1,2,3]"},
+		{http.MethodGet, "/ndjson", "", http.StatusOK, "application/x-ndjson", "{\"n\":1}\n{\"n\":2}\n"},
+		{http.MethodGet, "/bad", "", http.StatusInternalServerError, "", cenery.ErrJSONStreamItems.Error()},
+		{http.MethodGet, "/unsupported", "", http.StatusOK, "application/json", "[1"},
+		{http.MethodPost, "/ndjson", "{\"n\":1}\n\n{\"n\":2}\n", http.StatusOK, "", "3"},
+		{http.MethodPost, "/ndjson", "{\"n\":1}\n{\"n\":\"x\"}", http.StatusBadRequest, "", `field "[1].n": expected int, got string`},
+		{http.MethodPost, "/ndjson", "{\"n\":1} {\"n\":2}", http.StatusBadRequest, "", `field "[0]": unexpected data after the JSON value`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, body, contentType := rec.Code, rec.Body.String(), rec.Header().Get("Content-Type")
+		if status != tt.wantStatus || body != tt.wantBody {
+			t.Errorf("%v %v = %v %q, want %v %q", tt.method, tt.path, status, body, tt.wantStatus, tt.wantBody)
+		}
+		if tt.wantContentType != "" && contentType != tt.wantContentType {
+			t.Errorf("%v %v Content-Type = %q, want %q", tt.method, tt.path, contentType, tt.wantContentType)
+		}
+	}
+}
+
+func TestFormValue(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	a.Post("/form", func(c cenery.Ctx) error {
+		return c.SendString(http.StatusOK, c.FormValue("name", "anon")+","+strings.Join(c.FormValues("tag"), "|"))
+	})
+	a.Post("/small", cenery.RouteLimits(cenery.Limits{BodyLimit: 8}), func(c cenery.Ctx) error {
+		return c.SendString(http.StatusOK, c.FormValue("name", "anon"))
+	})
+	a.Post("/multipart", func(c cenery.Ctx) error {
+		form, err := c.MultipartForm()
+		if err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, form.Value["name"][0]+" "+form.File["file"][0].Filename)
+	})
+
+	multipartBody := &bytes.Buffer{}
+	writer := multipart.NewWriter(multipartBody)
+	writer.WriteField("name", "b")
+	writer.WriteField("tag", "z")
+	part, _ := writer.CreateFormFile("file", "test.txt")
+	io.WriteString(part, "file content")
+	writer.Close()
+
+	const urlencoded = "application/x-www-form-urlencoded"
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{"/form", urlencoded, "name=a&tag=x&tag=y", http.StatusOK, "a,x|y"},
+		{"/form?name=q", urlencoded, "tag=x", http.StatusOK, "anon,x"},
+		{"/form", writer.FormDataContentType(), multipartBody.String(), http.StatusOK, "b,z"},
+		{"/small", urlencoded, "name=too-long-for-the-limit", http.StatusOK, "anon"},
+		{"/multipart", writer.FormDataContentType(), multipartBody.String(), http.StatusOK, "b test.txt"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, body := rec.Code, rec.Body.String()
+		if status != tt.wantStatus || body != tt.wantBody {
+			t.Errorf("POST %v = %v %q, want %v %q", tt.path, status, body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+func TestMultipartReader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	parts := func(c cenery.Ctx) error {
+		mr, err := c.MultipartReader()
+		if err != nil {
+			return err
+		}
+		var out strings.Builder
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return c.SendString(http.StatusOK, out.String())
+			}
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(part)
+			if err != nil {
+				return err
+			}
+			out.WriteString(part.FormName() + "=")
+			if part.FileName() != "" {
+				out.WriteString(part.FileName() + "(" + part.ContentType() + "):")
+			}
+			out.WriteString(string(data) + ";")
+		}
+	}
+	a.Post("/parts", parts)
+	a.Post("/limited", cenery.RouteLimits(cenery.Limits{MaxFiles: 1, MaxFileSize: 8}), parts)
+	a.Post("/first", func(c cenery.Ctx) error {
+		mr, err := c.MultipartReader()
+		if err != nil {
+			return err
+		}
+		part, err := mr.NextPart()
+		if err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, part.FormName())
+	})
+
+	form := func(files ...string) (string, string) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		writer.WriteField("name", "a")
+		for _, content := range files {
+			part, _ := writer.CreateFormFile("file", "test.txt")
+			io.WriteString(part, content)
+		}
+		writer.Close()
+		return writer.FormDataContentType(), body.String()
+	}
+	type testCase struct {
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}
+	newCase := func(path string, wantStatus int, wantBody string, files ...string) testCase {
+		contentType, body := form(files...)
+		return testCase{path, contentType, body, wantStatus, wantBody}
+	}
+	tests := []testCase{
+		newCase("/parts", http.StatusOK, "name=a;file=test.txt(application/octet-stream):hello;", "hello"),
+		newCase("/limited", http.StatusOK, "name=a;file=test.txt(application/octet-stream):hello;", "hello"),
+		newCase("/limited", http.StatusRequestEntityTooLarge, "too many files", "hello", "again"),
+		newCase("/limited", http.StatusRequestEntityTooLarge, "file too large", "far too large"),
+		newCase("/first", http.StatusOK, "name", "hello"),
+		{"/parts", "application/x-www-form-urlencoded", "name=a", http.StatusBadRequest, "request is not multipart/form-data"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, body := rec.Code, rec.Body.String()
+		if status != tt.wantStatus || body != tt.wantBody {
+			t.Errorf("POST %v = %v %q, want %v %q", tt.path, status, body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+func TestSaveFile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	dir := t.TempDir()
+	a := New(server).(*app)
+	a.Post("/upload", func(c cenery.Ctx) error {
+		file, err := c.FormFileStream("file")
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if err := c.SaveFile(file, filepath.Join(dir, "sub", file.FileName)); err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, "saved")
+	})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "test.txt")
+	io.WriteString(part, "file content")
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	status, got := rec.Code, rec.Body.String()
+	if status != http.StatusOK || got != "saved" {
+		t.Fatalf("POST /upload = %v %q", status, got)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "sub", "test.txt"))
+	if err != nil || string(data) != "file content" {
+		t.Errorf("saved file = %q, %v", data, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "sub")); len(entries) != 1 {
+		t.Errorf("sub holds %v files, want 1", len(entries))
+	}
+}
+
+func TestFormFileErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	a.Post("/file", func(c cenery.Ctx) error {
+		file, err := c.FormFileE(c.QueryParam("key"))
+		if err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, file.FileName+" "+file.FileContentType+" "+file.DetectedContentType+" "+file.Header.Get("Content-Disposition"))
+	})
+	a.Post("/files", func(c cenery.Ctx) error {
+		files, err := c.FormFilesE(c.QueryParam("key"))
+		if err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, strconv.Itoa(len(files)))
+	})
+	a.Post("/stream", func(c cenery.Ctx) error {
+		file, err := c.FormFileStream(c.QueryParam("key"))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file.File)
+		if err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, file.DetectedContentType+" "+string(data))
+	})
+	a.Post("/legacy", func(c cenery.Ctx) error {
+		if c.FormFile(c.QueryParam("key")) == nil && c.FormFiles(c.QueryParam("key")) == nil {
+			return c.SendString(http.StatusOK, "nil")
+		}
+		return c.SendString(http.StatusOK, "found")
+	})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, content := range []string{"hello", "<html></html>"} {
+		part, _ := writer.CreateFormFile("file", "test.txt")
+		io.WriteString(part, content)
+	}
+	writer.Close()
+	formType := writer.FormDataContentType()
+
+	const urlencoded = "application/x-www-form-urlencoded"
+	tests := []struct {
+		path        string
+		contentType string
+		body        string
+		wantStatus  int
+		wantBody    string
+	}{
+		{"/file?key=file", formType, body.String(), http.StatusOK, `test.txt application/octet-stream text/plain; charset=utf-8 form-data; name="file"; filename="test.txt"`},
+		{"/file?key=missing", formType, body.String(), http.StatusBadRequest, "file not found"},
+		{"/file?key=file", urlencoded, "file=x", http.StatusBadRequest, "request is not multipart/form-data"},
+		{"/files?key=file", formType, body.String(), http.StatusOK, "2"},
+		{"/files?key=missing", formType, body.String(), http.StatusBadRequest, "file not found"},
+		{"/stream?key=file", formType, body.String(), http.StatusOK, "text/plain; charset=utf-8 hello"},
+		{"/stream?key=missing", formType, body.String(), http.StatusBadRequest, "file not found"},
+		{"/legacy?key=missing", formType, body.String(), http.StatusOK, "nil"},
+		{"/legacy?key=file", formType, body.String(), http.StatusOK, "found"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, body := rec.Code, rec.Body.String()
+		if status != tt.wantStatus || body != tt.wantBody {
+			t.Errorf("POST %v = %v %q, want %v %q", tt.path, status, body, tt.wantStatus, tt.wantBody)
+		}
+	}
+}
+
+func TestTus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server).(*app)
+	store := tus.NewMemoryStore()
+	tus.New(tus.Config{Store: store, NewID: func() string { return "abc" }}).Mount(a, "/files")
+
+	sum := sha1.Sum([]byte("world"))
+	offset := func(n string, checksum ...string) map[string]string {
+		h := map[string]string{"Tus-Resumable": "1.0.0", "Content-Type": "application/offset+octet-stream", "Upload-Offset": n}
+		if len(checksum) > 0 {
+			h["Upload-Checksum"] = "sha1 " + checksum[0]
+		}
+		return h
+	}
+	tests := []struct {
+		method     string
+		path       string
+		headers    map[string]string
+		body       string
+		wantStatus int
+		wantHeader string
+		wantValue  string
+	}{
+		{http.MethodOptions, "/files", nil, "", http.StatusNoContent, "Tus-Version", "1.0.0"},
+		{http.MethodPost, "/files", map[string]string{"Tus-Resumable": "1.0.0", "Upload-Length": "11"}, "", http.StatusCreated, "Location", "/files/abc"},
+		{http.MethodPatch, "/files/abc", offset("0"), "hello ", http.StatusNoContent, "Upload-Offset", "6"},
+		{http.MethodHead, "/files/abc", map[string]string{"Tus-Resumable": "1.0.0"}, "", http.StatusOK, "Upload-Offset", "6"},
+		{http.MethodPatch, "/files/abc", offset("6", "AAAAAAAAAAAAAAAAAAAAAAAAAAA="), "world", tus.StatusChecksumMismatch, "Upload-Offset", "6"},
+		{http.MethodPatch, "/files/abc", offset("0"), "hello ", http.StatusConflict, "Tus-Resumable", "1.0.0"},
+		{http.MethodPatch, "/files/abc", offset("6", base64.StdEncoding.EncodeToString(sum[:])), "world", http.StatusNoContent, "Upload-Offset", "11"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		for k, v := range tt.headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		status, value := rec.Code, rec.Header().Get(tt.wantHeader)
+		if status != tt.wantStatus || value != tt.wantValue {
+			t.Errorf("%v %v = %v %v %q, want %v %q", tt.method, tt.path, status, tt.wantHeader, value, tt.wantStatus, tt.wantValue)
+		}
+	}
+
+	rc, err := store.Open(context.Background(), "abc")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello world" {
+		t.Errorf("upload = %q, want %q", data, "hello world")
+	}
+}
+
+func TestClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	a := New(gin.New())
+	a.Post("/users/:id", func(c cenery.Ctx) error {
+		var user struct {
+			Name string `json:"name"`
+		}
+		if err := c.BodyParser(&user); err != nil {
+			return err
+		}
+		c.Response().SetHeader("X-Token", c.Request().GetHeader("Authorization"))
+		return c.SendJSON(http.StatusCreated, map[string]string{"id": c.Params("id"), "name": user.Name})
+	})
+	a.Post("/upload", func(c cenery.Ctx) error {
+		file, err := c.FormFileE("file")
+		if err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, c.FormValue("name")+" "+file.FileName+" "+string(file.FileData))
+	})
+
+	client := cenerytest.NewClient(a)
+	defer client.Close()
+
+	client.Post("/users/7").
+		Header("Authorization", "Bearer t").
+		JSON(map[string]string{"name": "Ann"}).
+		Do(t).
+		AssertStatus(http.StatusCreated).
+		AssertHeader("X-Token", "Bearer t").
+		AssertJSON(map[string]string{"id": "7", "name": "Ann"})
+	client.Post("/users/7").Text("{").Do(t).AssertStatus(http.StatusUnsupportedMediaType)
+	client.Post("/upload").
+		Field("name", "report").
+		File("file", "a.txt", []byte("hello")).
+		Do(t).
+		AssertStatus(http.StatusOK).
+		AssertBody("report a.txt hello")
+	client.Post("/upload").Field("name", "report").Do(t).AssertStatus(http.StatusBadRequest)
+}
+
+// TestCtxPool serves concurrent requests through pooled Ctxs; run it with
+// -race to also catch a Ctx used by two requests at once.
+func TestCtxPool(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	a := New(server)
+	var inUse sync.Map
+	a.Use(func(c cenery.Ctx) error {
+		if _, loaded := inUse.LoadOrStore(c, true); loaded {
+			t.Errorf("Ctx %p serves two requests at once", c)
+		}
+		defer inUse.Delete(c)
+		c.Locals("ctx", c)
+		return c.Next()
+	})
+	// render responds once Next returns, with what the handler left.
+	render := func(c cenery.Ctx) error {
+		if err := c.Next(); err != nil {
+			return err
+		}
+		return c.SendString(http.StatusOK, c.Locals("body").(string))
+	}
+	a.Get("/items/:id", render, func(c cenery.Ctx) error {
+		if c.Locals("ctx") != c {
+			t.Errorf("handlers of one request got different Ctxs")
+		}
+		runtime.Gosched()
+		c.Locals("body", c.Params("id"))
+		return nil
+	})
+	a.Get("/slow/:id", timeout.New(timeout.Config{Timeout: time.Millisecond}), func(c cenery.Ctx) error {
+		time.Sleep(5 * time.Millisecond)
+		c.Response().SetHeader("X-Id", c.Params("id"))
+		return c.SendString(http.StatusOK, c.Params("id"))
+	})
+
+	var wg sync.WaitGroup
+	for i := range 32 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range 20 {
+				id := strconv.Itoa(i*100 + j)
+				path, want := "/items/"+id, id
+				if j%5 == 0 {
+					path, want = "/slow/"+id, "Service Unavailable"
+				}
+				rec := httptest.NewRecorder()
+				server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+				if rec.Body.String() != want {
+					t.Errorf("%v body = %q, want %q", path, rec.Body.String(), want)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
 func BenchmarkParams(b *testing.B) {
 	gin.SetMode(gin.TestMode)
 	rec := httptest.NewRecorder()
@@ -5,8 +5,8 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func NewApp() cenery.App {
+func NewApp(opts ...cenery.Option) cenery.App {
 	ginApp := gin.New()
 	ginApp.Use(gin.Recovery())
-	return New(ginApp)
+	return New(ginApp, opts...)
 }
@@ -7,6 +7,7 @@ replace github.com/dreamph/cenery => ../../
 require (
 	github.com/dreamph/cenery v1.0.1
 	github.com/gin-gonic/gin v1.11.0
+	github.com/gorilla/websocket v1.5.3
 )
 
 require (
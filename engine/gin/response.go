@@ -36,22 +36,23 @@ func EnableResponseCapture(enabled bool) {
 }
 
 func NewResponse(ctx *gin.Context) cenery.Response {
-	resp := ctx.Writer
-	var resBodyBuffer *bytes.Buffer
+	h := &response{}
+	h.init(ctx)
+	return h
+}
+
+// init points h at ctx's writer, wrapping it to fill the captured body when
+// capture is enabled.
+func (h *response) init(ctx *gin.Context) {
+	h.resBody = nil
 	if captureResponseBody.Load() {
-		resBodyBuffer = &bytes.Buffer{}
-		writer := &responseBodyWriter{
-			ResponseWriter: resp,
-			writer:         io.MultiWriter(resp, resBodyBuffer),
+		h.resBody = &bytes.Buffer{}
+		ctx.Writer = &responseBodyWriter{
+			ResponseWriter: ctx.Writer,
+			writer:         io.MultiWriter(ctx.Writer, h.resBody),
 		}
-		ctx.Writer = writer
-		resp = writer
-	}
-
-	return &response{
-		resp:    resp,
-		resBody: resBodyBuffer,
 	}
+	h.resp = ctx.Writer
 }
 
 func (h *response) Body() []byte {
@@ -2,7 +2,10 @@ package gin
 
 import (
 	"context"
+	"errors"
+	"io/fs"
 	"net/http"
+	"strings"
 
 	"github.com/dreamph/cenery"
 	"github.com/gin-gonic/gin"
@@ -11,10 +14,11 @@ import (
 type app struct {
 	server     *gin.Engine
 	httpServer *http.Server
+	config     *cenery.Config
 }
 
-func New(server *gin.Engine) cenery.App {
-	return &app{server: server}
+func New(server *gin.Engine, opts ...cenery.Option) cenery.App {
+	return &app{server: server, config: cenery.NewConfig(opts...)}
 }
 
 func (a *app) Name() string {
@@ -29,44 +33,74 @@ func (a *app) Listen(addr string) error {
 	return a.httpServer.ListenAndServe()
 }
 
+// ServeHTTP serves r with the app, so it can be mounted in another
+// net/http server or driven by httptest and cenerytest.
+func (a *app) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.server.ServeHTTP(w, r)
+}
+
 func (a *app) Use(handlers ...cenery.Handler) {
 	a.server.Use(a.toHandlers(handlers...)...)
 }
 
-func (a *app) Get(path string, handlers ...cenery.Handler) {
+func (a *app) Get(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.GET(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodGet, path)
 }
 
-func (a *app) Post(path string, handlers ...cenery.Handler) {
+func (a *app) Post(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.POST(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodPost, path)
 }
 
-func (a *app) Put(path string, handlers ...cenery.Handler) {
+func (a *app) Put(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.PUT(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodPut, path)
 }
 
-func (a *app) Delete(path string, handlers ...cenery.Handler) {
+func (a *app) Delete(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.DELETE(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodDelete, path)
 }
 
-func (a *app) Head(path string, handlers ...cenery.Handler) {
+func (a *app) Head(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.HEAD(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodHead, path)
 }
 
-func (a *app) Options(path string, handlers ...cenery.Handler) {
+func (a *app) Options(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.OPTIONS(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodOptions, path)
 }
 
-func (a *app) Connect(path string, handlers ...cenery.Handler) {
+func (a *app) Connect(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Handle(http.MethodConnect, path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodConnect, path)
 }
 
-func (a *app) Patch(path string, handlers ...cenery.Handler) {
+func (a *app) Patch(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.PATCH(path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodPatch, path)
 }
 
-func (a *app) Trace(path string, handlers ...cenery.Handler) {
+func (a *app) Trace(path string, handlers ...cenery.Handler) *cenery.Route {
 	a.server.Handle(http.MethodTrace, path, a.toHandlers(handlers...)...)
+	return a.config.Routes.Add(http.MethodTrace, path)
+}
+
+func (a *app) Static(prefix, root string, config ...cenery.StaticConfig) {
+	a.StaticFS(prefix, cenery.RootFS(root), config...)
+}
+
+func (a *app) StaticFS(prefix string, fsys fs.FS, config ...cenery.StaticConfig) {
+	handler := cenery.StaticHandler(prefix, fsys, config...)
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix != "" {
+		a.Get(prefix, handler)
+		a.Head(prefix, handler)
+	}
+	a.Get(prefix+"/*filepath", handler)
+	a.Head(prefix+"/*filepath", handler)
 }
 
 func (a *app) Shutdown(ctx context.Context) error {
@@ -81,11 +115,30 @@ func (a *app) toHandlers(handlers ...cenery.Handler) []gin.HandlerFunc {
 	for i, handler := range handlers {
 		h := handler // Copy variable to avoid closure capture bug
 		handlerList[i] = func(c *gin.Context) {
-			svc := NewServerCtx(c)
+			svc, caller := acquireCtx(c, getRequestState(c))
+			defer releaseCtx(svc, caller)
+			svc.state.init(a.config)
 			if err := h(svc); err != nil {
-				_ = c.AbortWithError(http.StatusInternalServerError, err)
+				a.handleError(svc, err)
 			}
+			svc.release()
 		}
 	}
 	return handlerList
 }
+
+// handleError passes err to the error handler unless a later handler has
+// already done so and the error is only being returned up the chain.
+func (a *app) handleError(s *serverCtx, err error) {
+	if s.pending == nil {
+		for _, e := range s.ctx.Errors {
+			if errors.Is(err, e.Err) {
+				return
+			}
+		}
+	}
+	s.err = err
+	if herr := a.config.ErrorHandler(s, err); herr != nil {
+		s.ctx.String(http.StatusInternalServerError, herr.Error())
+	}
+}
@@ -0,0 +1,88 @@
+package gin
+
+import (
+	"sync"
+
+	"github.com/dreamph/cenery"
+	"github.com/gin-gonic/gin"
+)
+
+const stateKey = "cenery.state"
+
+// requestState is shared by every handler serving one request. Handled
+// errors are tracked in gin's own c.Errors. It is locked because a
+// timed-out chain keeps running next to the handlers above it.
+type requestState struct {
+	mu          sync.Mutex
+	limits      *cenery.Limits // nil until set by the app or a route
+	config      *cenery.Config // app serving the request, nil until set
+	bodyLimited bool           // request body already wrapped
+
+	current  *serverCtx // shared by the handlers of the chain, see acquireCtx
+	detached bool       // the chain went on in another goroutine
+}
+
+func getRequestState(c *gin.Context) *requestState {
+	if state, ok := c.Value(stateKey).(*requestState); ok {
+		return state
+	}
+	state := &requestState{}
+	c.Set(stateKey, state)
+	return state
+}
+
+// init records the config of the app serving the request, unless an outer
+// app already did.
+func (st *requestState) init(config *cenery.Config) {
+	st.mu.Lock()
+	if st.config == nil {
+		st.config = config
+	}
+	if st.limits == nil {
+		l := config.Limits
+		st.limits = &l
+	}
+	st.mu.Unlock()
+}
+
+func (st *requestState) appConfig() *cenery.Config {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.config
+}
+
+func (st *requestState) setLimits(l cenery.Limits) {
+	st.mu.Lock()
+	st.limits = &l
+	st.mu.Unlock()
+}
+
+// requestLimits returns the limits of the app serving c, or the defaults
+// when c is not served through one.
+func requestLimits(c *gin.Context) cenery.Limits {
+	if state, ok := c.Value(stateKey).(*requestState); ok {
+		state.mu.Lock()
+		defer state.mu.Unlock()
+		if state.limits != nil {
+			return *state.limits
+		}
+	}
+	return cenery.DefaultLimits()
+}
+
+// limitBody caps the request body at the current BodyLimit. The body is only
+// wrapped once per request, so a limit set after it was first read has no
+// effect.
+func limitBody(c *gin.Context) {
+	state, ok := c.Value(stateKey).(*requestState)
+	if ok {
+		state.mu.Lock()
+		limited := state.bodyLimited
+		state.bodyLimited = true
+		state.mu.Unlock()
+		if limited {
+			return
+		}
+	}
+	c.Request.Body = requestLimits(c).LimitBody(c.Request.Body, c.Request.ContentLength)
+}
@@ -0,0 +1,130 @@
+package gin
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dreamph/cenery"
+	"github.com/gorilla/websocket"
+)
+
+// wsControlTimeout bounds writing a ping or close frame.
+const wsControlTimeout = 5 * time.Second
+
+func (a *app) WebSocket(path string, handler cenery.WSHandler, config ...cenery.WSConfig) {
+	cfg := cenery.NewWSConfig(config...)
+	upgrader := &websocket.Upgrader{
+		HandshakeTimeout:  cfg.HandshakeTimeout,
+		ReadBufferSize:    cfg.ReadBufferSize,
+		WriteBufferSize:   cfg.WriteBufferSize,
+		Subprotocols:      cfg.Subprotocols,
+		EnableCompression: cfg.EnableCompression,
+		// The origin is checked against the cenery.Ctx before upgrading.
+		CheckOrigin: func(*http.Request) bool { return true },
+	}
+
+	a.Get(path, func(c cenery.Ctx) error {
+		if !cfg.AllowOrigin(c) {
+			return &cenery.Error{Code: http.StatusForbidden, Message: http.StatusText(http.StatusForbidden), Err: cenery.ErrWSOrigin}
+		}
+		s := c.(*serverCtx)
+		// A failed handshake is answered by the error handler, with the
+		// status the upgrader picked, unless the connection was hijacked.
+		var status int
+		u := *upgrader
+		u.Error = func(w http.ResponseWriter, _ *http.Request, code int, _ error) {
+			w.Header().Set("Sec-Websocket-Version", "13")
+			status = code
+		}
+		conn, err := u.Upgrade(s.ctx.Writer, s.ctx.Request, nil)
+		if err != nil {
+			if status == 0 {
+				return nil
+			}
+			return &cenery.Error{Code: status, Message: http.StatusText(status), Err: err}
+		}
+		if cfg.ReadLimit > 0 {
+			conn.SetReadLimit(cfg.ReadLimit)
+		}
+		ws := &wsConn{conn: conn, ctx: s}
+		code, text := cenery.WSCloseCode(handler(ws))
+		_ = ws.Close(code, text)
+		return nil
+	})
+}
+
+type wsConn struct {
+	conn *websocket.Conn
+	ctx  cenery.Ctx
+}
+
+func (w *wsConn) ReadMessage() (int, []byte, error) {
+	mt, data, err := w.conn.ReadMessage()
+	var ce *websocket.CloseError
+	if errors.As(err, &ce) {
+		return mt, data, &cenery.WSCloseError{Code: ce.Code, Text: ce.Text}
+	}
+	return mt, data, err
+}
+
+func (w *wsConn) WriteMessage(messageType int, data []byte) error {
+	return w.conn.WriteMessage(messageType, data)
+}
+
+func (w *wsConn) Ping(data []byte) error {
+	return w.conn.WriteControl(websocket.PingMessage, data, time.Now().Add(wsControlTimeout))
+}
+
+func (w *wsConn) SetPingHandler(h func(data string) error) {
+	w.conn.SetPingHandler(h)
+}
+
+func (w *wsConn) SetPongHandler(h func(data string) error) {
+	w.conn.SetPongHandler(h)
+}
+
+func (w *wsConn) Close(code int, text string) error {
+	msg := websocket.FormatCloseMessage(code, text)
+	err := w.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(wsControlTimeout))
+	if cerr := w.conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (w *wsConn) SetReadDeadline(t time.Time) error {
+	return w.conn.SetReadDeadline(t)
+}
+
+func (w *wsConn) SetWriteDeadline(t time.Time) error {
+	return w.conn.SetWriteDeadline(t)
+}
+
+func (w *wsConn) SetReadLimit(limit int64) {
+	w.conn.SetReadLimit(limit)
+}
+
+func (w *wsConn) EnableWriteCompression(enable bool) {
+	w.conn.EnableWriteCompression(enable)
+}
+
+func (w *wsConn) Subprotocol() string {
+	return w.conn.Subprotocol()
+}
+
+func (w *wsConn) Params(key string, defaultValue ...string) string {
+	return w.ctx.Params(key, defaultValue...)
+}
+
+func (w *wsConn) QueryParam(key string, defaultValue ...string) string {
+	return w.ctx.QueryParam(key, defaultValue...)
+}
+
+func (w *wsConn) Locals(key string) any {
+	return w.ctx.Locals(key)
+}
+
+func (w *wsConn) IP() string {
+	return w.ctx.IP()
+}
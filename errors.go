@@ -0,0 +1,47 @@
+package cenery
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error is an error with an HTTP status code. Returning one from a handler
+// makes the error handler respond with Code instead of 500.
+type Error struct {
+	Code    int
+	Message string
+	// Err is the underlying cause, if any.
+	Err error
+}
+
+// NewError creates an Error. The message defaults to the status text of code.
+func NewError(code int, message ...string) *Error {
+	msg := http.StatusText(code)
+	if len(message) > 0 {
+		msg = message[0]
+	}
+	return &Error{Code: code, Message: msg}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler writes the response for an error returned by a handler.
+type ErrorHandler = func(c Ctx, err error) error
+
+// DefaultErrorHandler responds with the status of an *Error, or 500 for any
+// other error, and the error message as plain text.
+func DefaultErrorHandler(c Ctx, err error) error {
+	code := http.StatusInternalServerError
+	var e *Error
+	if errors.As(err, &e) {
+		code = e.Code
+	}
+	c.Response().SetHeader("Content-Type", "text/plain; charset=utf-8")
+	return c.SendString(code, err.Error())
+}
@@ -0,0 +1,410 @@
+package cenery
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SendFile implements Ctx.SendFile for engines.
+func SendFile(c Ctx, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fsError(err)
+	}
+	return sendOpenFile(c, f, filepath.Base(path), "")
+}
+
+// SendFileFS implements Ctx.SendFileFS for engines.
+func SendFileFS(c Ctx, fsys fs.FS, name string) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return fsError(err)
+	}
+	return sendOpenFile(c, f, path.Base(name), "")
+}
+
+// Download implements Ctx.Download for engines.
+func Download(c Ctx, path, filename string) error {
+	if filename == "" {
+		filename = filepath.Base(path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fsError(err)
+	}
+	c.Response().SetHeader("Content-Disposition", ContentDisposition("attachment", filename))
+	return sendOpenFile(c, f, filename, "")
+}
+
+// SaveFile implements Ctx.SaveFile for engines. The copy stops when the
+// context of c is done.
+func SaveFile(c Ctx, file *FileStream, path string) error {
+	if file == nil || file.File == nil {
+		return errors.New("cenery: SaveFile needs a file")
+	}
+	_, err := WriteFile(c.Context(), path, file.File)
+	return err
+}
+
+// WriteFile copies r to path through a temporary file in the same
+// directory, so path never holds a partial file, creating missing
+// directories. It returns the number of bytes written and fails once ctx is
+// done.
+func WriteFile(ctx context.Context, path string, r io.Reader) (int64, error) {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(tmp, ctxReader{ctx, r})
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	return n, nil
+}
+
+// ctxReader fails reads once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+func sendOpenFile(c Ctx, f fs.File, name, etag string) error {
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		return NewError(http.StatusNotFound)
+	}
+	return sendContent(c, f, info, name, etag)
+}
+
+// sendContent responds with f, which it closes, honouring conditional and
+// range requests. The content type is taken from the extension of name or
+// sniffed. An empty etag is derived from the size and modtime.
+func sendContent(c Ctx, f fs.File, info fs.FileInfo, name, etag string) error {
+	resp := c.Response()
+	req := c.Request()
+	size := info.Size()
+	modTime := info.ModTime()
+
+	if etag == "" && !modTime.IsZero() {
+		etag = `"` + strconv.FormatInt(size, 16) + "-" + strconv.FormatInt(modTime.UnixNano(), 16) + `"`
+	}
+	if etag != "" {
+		resp.SetHeader("ETag", etag)
+	}
+	if !modTime.IsZero() {
+		resp.SetHeader("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	}
+	if notModified(req, etag, modTime) {
+		f.Close()
+		return c.Send(http.StatusNotModified, nil)
+	}
+
+	seeker, canSeek := f.(io.ReadSeeker)
+	var body io.Reader = f
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			f.Close()
+			return err
+		}
+		contentType = http.DetectContentType(head[:n])
+		if canSeek {
+			if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+				f.Close()
+				return err
+			}
+		} else {
+			body = io.MultiReader(bytes.NewReader(head[:n]), f)
+		}
+	}
+
+	if !canSeek {
+		return c.SendStreamSized(http.StatusOK, contentType, closeAfterSend(body, f), size)
+	}
+	resp.SetHeader("Accept-Ranges", "bytes")
+
+	rangeHeader := req.GetHeader("Range")
+	if rangeHeader == "" || req.Method() != http.MethodGet || !ifRange(req, etag, modTime) {
+		return c.SendStreamSized(http.StatusOK, contentType, closeAfterSend(f, f), size)
+	}
+
+	ranges, err := parseRange(rangeHeader, size)
+	switch {
+	case errors.Is(err, errRangeUnsatisfiable):
+		f.Close()
+		resp.SetHeader("Content-Range", "bytes */"+strconv.FormatInt(size, 10))
+		return NewError(http.StatusRequestedRangeNotSatisfiable)
+	case err != nil:
+		// Malformed, overlapping or too many ranges are ignored, as RFC 9110
+		// allows.
+		return c.SendStreamSized(http.StatusOK, contentType, closeAfterSend(f, f), size)
+	}
+
+	if len(ranges) == 1 {
+		r := ranges[0]
+		resp.SetHeader("Content-Range", r.contentRange(size))
+		return c.SendStreamSized(http.StatusPartialContent, contentType, closeAfterSend(&rangeReader{f: seeker, r: r}, f), r.length)
+	}
+
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	var parts []io.Reader
+	var length int64
+	for i, r := range ranges {
+		header := "--" + boundary + "\r\nContent-Type: " + contentType + "\r\nContent-Range: " + r.contentRange(size) + "\r\n\r\n"
+		if i > 0 {
+			header = "\r\n" + header
+		}
+		parts = append(parts, strings.NewReader(header), &rangeReader{f: seeker, r: r})
+		length += int64(len(header)) + r.length
+	}
+	trailer := "\r\n--" + boundary + "--\r\n"
+	parts = append(parts, strings.NewReader(trailer))
+	length += int64(len(trailer))
+
+	return c.SendStreamSized(http.StatusPartialContent, "multipart/byteranges; boundary="+boundary, closeAfterSend(io.MultiReader(parts...), f), length)
+}
+
+type byteRange struct {
+	start, length int64
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return "bytes " + strconv.FormatInt(r.start, 10) + "-" + strconv.FormatInt(r.start+r.length-1, 10) + "/" + strconv.FormatInt(size, 10)
+}
+
+var (
+	errRangeMalformed     = errors.New("cenery: malformed range")
+	errRangeUnsatisfiable = errors.New("cenery: range not satisfiable")
+)
+
+// maxRanges is the most ranges a Range header may list.
+const maxRanges = 32
+
+// parseRange parses a Range header such as "bytes=0-99,200-,-50" against a
+// file of size bytes. Ranges starting past the end are dropped; if none are
+// left it returns errRangeUnsatisfiable. More than maxRanges ranges, or
+// ranges that overlap, are errRangeMalformed, so a small file cannot be
+// multiplied into a large response.
+func parseRange(header string, size int64) ([]byteRange, error) {
+	spec, ok := strings.CutPrefix(header, "bytes=")
+	if !ok {
+		return nil, errRangeMalformed
+	}
+	parts := strings.Split(spec, ",")
+	if len(parts) > maxRanges {
+		return nil, errRangeMalformed
+	}
+	var ranges []byteRange
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, ok := strings.Cut(part, "-")
+		if !ok {
+			return nil, errRangeMalformed
+		}
+		first, last = strings.TrimSpace(first), strings.TrimSpace(last)
+		if first == "" {
+			// A suffix range: the last n bytes.
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil || n < 0 {
+				return nil, errRangeMalformed
+			}
+			if n == 0 || size == 0 {
+				continue
+			}
+			n = min(n, size)
+			ranges = append(ranges, byteRange{start: size - n, length: n})
+			continue
+		}
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil || start < 0 {
+			return nil, errRangeMalformed
+		}
+		end := size - 1
+		if last != "" {
+			end, err = strconv.ParseInt(last, 10, 64)
+			if err != nil || end < start {
+				return nil, errRangeMalformed
+			}
+			end = min(end, size-1)
+		}
+		if start >= size {
+			continue
+		}
+		ranges = append(ranges, byteRange{start: start, length: end - start + 1})
+	}
+	if len(ranges) == 0 {
+		return nil, errRangeUnsatisfiable
+	}
+	sorted := slices.SortedFunc(slices.Values(ranges), func(a, b byteRange) int {
+		return cmp.Compare(a.start, b.start)
+	})
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].start < sorted[i-1].start+sorted[i-1].length {
+			return nil, errRangeMalformed
+		}
+	}
+	return ranges, nil
+}
+
+// ifRange reports whether a Range request may be served partially: when it
+// has no If-Range, or If-Range matches the strong etag or exact modtime.
+func ifRange(req Request, etag string, modTime time.Time) bool {
+	val := req.GetHeader("If-Range")
+	if val == "" {
+		return true
+	}
+	if strings.HasPrefix(val, `"`) || strings.HasPrefix(val, "W/") {
+		return etag != "" && !strings.HasPrefix(etag, "W/") && val == etag
+	}
+	t, err := http.ParseTime(val)
+	return err == nil && !modTime.IsZero() && modTime.Truncate(time.Second).Equal(t)
+}
+
+// notModified evaluates If-None-Match, or If-Modified-Since when the
+// request has no If-None-Match, as RFC 9110 requires.
+func notModified(req Request, etag string, modTime time.Time) bool {
+	if match := req.GetHeader("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since := req.GetHeader("If-Modified-Since")
+	if since == "" || modTime.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(since)
+	return err == nil && !modTime.Truncate(time.Second).After(t)
+}
+
+// rangeReader reads one range of f, seeking to it on the first read so the
+// ranges of a multipart response can share f.
+type rangeReader struct {
+	f       io.ReadSeeker
+	r       byteRange
+	started bool
+	n       int64
+}
+
+func (rr *rangeReader) Read(p []byte) (int, error) {
+	if !rr.started {
+		if _, err := rr.f.Seek(rr.r.start, io.SeekStart); err != nil {
+			return 0, err
+		}
+		rr.started = true
+	}
+	if rr.n >= rr.r.length {
+		return 0, io.EOF
+	}
+	if remaining := rr.r.length - rr.n; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err := rr.f.Read(p)
+	rr.n += int64(n)
+	if err == io.EOF && rr.n < rr.r.length {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// ContentDisposition formats a Content-Disposition header as RFC 6266
+// describes: an ASCII filename for old clients and, when that had to drop
+// characters, the UTF-8 name as filename*.
+func ContentDisposition(disposition, filename string) string {
+	var fallback strings.Builder
+	ascii := true
+	for _, r := range filename {
+		switch {
+		case r == utf8.RuneError || r < 0x20 || r == 0x7f:
+			fallback.WriteByte('_')
+			ascii = false
+		case r == '"' || r == '\\':
+			fallback.WriteByte('\\')
+			fallback.WriteRune(r)
+		case r > 0x7f:
+			fallback.WriteByte('_')
+			ascii = false
+		default:
+			fallback.WriteRune(r)
+		}
+	}
+	v := disposition + `; filename="` + fallback.String() + `"`
+	if !ascii {
+		v += "; filename*=UTF-8''" + encodeExtValue(filename)
+	}
+	return v
+}
+
+// encodeExtValue percent-encodes s for an RFC 8187 ext-value.
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		if isAttrChar(ch) {
+			b.WriteByte(ch)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[ch>>4])
+		b.WriteByte(hex[ch&0xf])
+	}
+	return b.String()
+}
+
+func isAttrChar(ch byte) bool {
+	switch {
+	case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$&+-.^_`|~", ch) >= 0
+}
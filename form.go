@@ -0,0 +1,202 @@
+package cenery
+
+import (
+	"bytes"
+	"encoding"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// UnmarshalForm decodes an application/x-www-form-urlencoded body into v,
+// a pointer to a struct or to a map[string]string, map[string][]string or
+// map[string]any. Struct fields are matched by their form tag, then their
+// json tag, then their name. Strings, bools, numbers, encoding.TextUnmarshaler
+// values and slices and pointers of those are supported.
+func UnmarshalForm(data []byte, v any) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	return decodeForm(values, v)
+}
+
+// multipartValues returns the non-file fields of a multipart/form-data body.
+func multipartValues(body []byte, boundary string) (map[string][]string, error) {
+	if boundary == "" {
+		return nil, errors.New("cenery: multipart boundary missing")
+	}
+	values := make(map[string][]string)
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return values, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := part.FormName()
+		if name == "" || part.FileName() != "" {
+			part.Close()
+			continue
+		}
+		data, err := io.ReadAll(part)
+		part.Close()
+		if err != nil {
+			return nil, err
+		}
+		values[name] = append(values[name], string(data))
+	}
+}
+
+var textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+func decodeForm(values map[string][]string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("cenery: form target must be a non-nil pointer")
+	}
+	switch target := v.(type) {
+	case *map[string][]string:
+		if *target == nil {
+			*target = make(map[string][]string, len(values))
+		}
+		for k, vs := range values {
+			(*target)[k] = vs
+		}
+		return nil
+	case *map[string]string:
+		if *target == nil {
+			*target = make(map[string]string, len(values))
+		}
+		for k, vs := range values {
+			(*target)[k] = vs[0]
+		}
+		return nil
+	case *map[string]any:
+		if *target == nil {
+			*target = make(map[string]any, len(values))
+		}
+		for k, vs := range values {
+			if len(vs) == 1 {
+				(*target)[k] = vs[0]
+			} else {
+				(*target)[k] = vs
+			}
+		}
+		return nil
+	}
+	rv = rv.Elem()
+	if rv.Kind() != reflect.Struct {
+		return errors.New("cenery: cannot decode form into " + rv.Type().String())
+	}
+	return decodeFormStruct(values, rv)
+}
+
+func decodeFormStruct(values map[string][]string, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fv := rv.Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			if err := decodeFormStruct(values, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		name := formFieldName(field)
+		if name == "-" {
+			continue
+		}
+		vs, ok := values[name]
+		if !ok || len(vs) == 0 {
+			continue
+		}
+		if err := setFormValue(fv, vs); err != nil {
+			return badBody(name, err.Error(), err)
+		}
+	}
+	return nil
+}
+
+func formFieldName(field reflect.StructField) string {
+	for _, key := range []string{"form", "json"} {
+		if tag, ok := field.Tag.Lookup(key); ok {
+			if name, _, _ := strings.Cut(tag, ","); name != "" {
+				return name
+			}
+		}
+	}
+	return field.Name
+}
+
+func setFormValue(fv reflect.Value, vs []string) error {
+	if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) && fv.Type().Elem().Kind() != reflect.Uint8 {
+		slice := reflect.MakeSlice(fv.Type(), len(vs), len(vs))
+		for i, s := range vs {
+			if err := setFormString(slice.Index(i), s); err != nil {
+				return err
+			}
+		}
+		fv.Set(slice)
+		return nil
+	}
+	return setFormString(fv, vs[0])
+}
+
+func setFormString(fv reflect.Value, s string) error {
+	if fv.Kind() == reflect.Pointer {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		return setFormString(fv.Elem(), s)
+	}
+	if fv.CanAddr() && fv.Addr().Type().Implements(textUnmarshalerType) {
+		return fv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			fv.SetBytes([]byte(s))
+			return nil
+		}
+		fallthrough
+	default:
+		return errors.New("unsupported type " + fv.Type().String())
+	}
+	return nil
+}
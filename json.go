@@ -0,0 +1,73 @@
+package cenery
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// JSONEncoder writes JSON values to a stream.
+type JSONEncoder interface {
+	Encode(v any) error
+}
+
+// JSONDecoder reads JSON values from a stream.
+type JSONDecoder interface {
+	Decode(v any) error
+}
+
+// JSONCodec is the JSON implementation used by BodyParser,
+// BodyParserStream, SendJSON and the JSON codec of Negotiate on every
+// engine. Its behaviour should match encoding/json.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+	NewEncoder(w io.Writer) JSONEncoder
+	NewDecoder(r io.Reader) JSONDecoder
+}
+
+// StdJSON is the JSONCodec of encoding/json, and the default.
+var StdJSON JSONCodec = NewJSONCodec(json.Marshal, json.Unmarshal, json.NewEncoder, json.NewDecoder)
+
+// NewJSONCodec builds a JSONCodec from the functions of a package that
+// mirrors encoding/json, such as goccy/go-json:
+//
+//	cenery.NewJSONCodec(gojson.Marshal, gojson.Unmarshal, gojson.NewEncoder, gojson.NewDecoder)
+func NewJSONCodec[E JSONEncoder, D JSONDecoder](
+	marshal func(v any) ([]byte, error),
+	unmarshal func(data []byte, v any) error,
+	newEncoder func(w io.Writer) E,
+	newDecoder func(r io.Reader) D,
+) JSONCodec {
+	return jsonFuncs[E, D]{marshal, unmarshal, newEncoder, newDecoder}
+}
+
+type jsonFuncs[E JSONEncoder, D JSONDecoder] struct {
+	marshal    func(v any) ([]byte, error)
+	unmarshal  func(data []byte, v any) error
+	newEncoder func(w io.Writer) E
+	newDecoder func(r io.Reader) D
+}
+
+func (j jsonFuncs[E, D]) Marshal(v any) ([]byte, error)      { return j.marshal(v) }
+func (j jsonFuncs[E, D]) Unmarshal(data []byte, v any) error { return j.unmarshal(data, v) }
+func (j jsonFuncs[E, D]) NewEncoder(w io.Writer) JSONEncoder { return j.newEncoder(w) }
+func (j jsonFuncs[E, D]) NewDecoder(r io.Reader) JSONDecoder { return j.newDecoder(r) }
+
+// JSONCodec returns the JSON codec of c, or StdJSON when c is nil.
+func (c *Config) JSONCodec() JSONCodec {
+	if c == nil || c.JSON == nil {
+		return StdJSON
+	}
+	return c.JSON
+}
+
+// WithJSONCodec sets the JSON implementation used by every engine.
+func WithJSONCodec(j JSONCodec) Option {
+	return func(c *Config) {
+		if j == nil {
+			return
+		}
+		c.JSON = j
+		c.Codecs.Register(Codec{MediaType: JSON.MediaType, Marshal: j.Marshal, Unmarshal: j.Unmarshal})
+	}
+}
@@ -0,0 +1,420 @@
+package cenery
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Errors wrapped by the *BodyError of a body rejected by JSONDecodeOptions.
+var (
+	ErrUnknownField = errors.New("cenery: unknown field")
+	ErrTrailingData = errors.New("cenery: trailing data after JSON value")
+	ErrMaxDepth     = errors.New("cenery: JSON nested too deeply")
+	ErrDuplicateKey = errors.New("cenery: duplicate JSON key")
+)
+
+// ErrDecodeOption is wrapped by the 500 *Error BodyParser returns when the
+// decoder of the JSON codec lacks the UseNumber method JSONDecodeOptions
+// asks for. It is only found once a body is decoded; NewConfig does not
+// check the options.
+var ErrDecodeOption = errors.New("cenery: JSON codec does not support the decode option")
+
+// JSONDecodeOptions makes BodyParser and BodyParserStream stricter than
+// encoding/json. Violations are 400 errors wrapping a *BodyError. On gin and
+// echo, BodyParser ignores them with Config.NativeBodyParser.
+type JSONDecodeOptions struct {
+	// DisallowUnknownFields rejects object keys that match no field of the
+	// destination struct. The keys are checked by cenery, so it works with
+	// every JSON codec.
+	DisallowUnknownFields bool
+	// UseNumber decodes numbers into an interface{} as json.Number instead
+	// of float64.
+	UseNumber bool
+	// DisallowTrailingData rejects anything but whitespace after the value.
+	DisallowTrailingData bool
+	// MaxDepth limits how deeply objects and arrays may nest. Zero means
+	// no limit.
+	MaxDepth int
+	// DisallowDuplicateKeys rejects objects that repeat a key.
+	DisallowDuplicateKeys bool
+}
+
+// JSONDecodeOptionsKey is the Locals key RouteJSONOptions stores the route's
+// options under.
+const JSONDecodeOptionsKey = "cenery.json.decode"
+
+// RouteJSONOptions returns a handler that replaces the app's JSON decode
+// options for the rest of the chain:
+//
+//	app.Post("/orders", cenery.RouteJSONOptions(cenery.JSONDecodeOptions{DisallowUnknownFields: true}), create)
+//
+// BodyParser fails with a 500 wrapping ErrDecodeOption when the app's JSON
+// codec cannot honour o.UseNumber.
+func RouteJSONOptions(o JSONDecodeOptions) Handler {
+	return func(c Ctx) error {
+		c.Locals(JSONDecodeOptionsKey, o)
+		return c.Next()
+	}
+}
+
+// WithJSONDecodeOptions sets the JSON decode options of every route.
+// BodyParser fails with a 500 wrapping ErrDecodeOption when the app's JSON
+// codec cannot honour o.UseNumber.
+func WithJSONDecodeOptions(o JSONDecodeOptions) Option {
+	return func(c *Config) {
+		c.JSONDecode = o
+	}
+}
+
+func jsonDecodeOptions(c Ctx, cfg *Config) JSONDecodeOptions {
+	if o, ok := c.Locals(JSONDecodeOptionsKey).(JSONDecodeOptions); ok {
+		return o
+	}
+	if cfg == nil {
+		return JSONDecodeOptions{}
+	}
+	return cfg.JSONDecode
+}
+
+// BodyError describes why BodyParser rejected a request body. It is
+// wrapped in a 400 *Error.
+type BodyError struct {
+	// Field is the path of the offending field, such as "items[2].name",
+	// or empty when the error concerns the whole body.
+	Field  string
+	Reason string
+	Err    error
+}
+
+func (e *BodyError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return "field " + strconv.Quote(e.Field) + ": " + e.Reason
+}
+
+func (e *BodyError) Unwrap() error {
+	return e.Err
+}
+
+func badBody(field, reason string, err error) error {
+	be := &BodyError{Field: field, Reason: reason, Err: err}
+	return &Error{Code: http.StatusBadRequest, Message: be.Error(), Err: be}
+}
+
+// DecodeJSON implements Ctx.BodyParserStream for engines: it decodes the
+// first JSON value of r into out with the JSON codec of cfg and the decode
+// options of the route. An empty body leaves out unchanged.
+func DecodeJSON(c Ctx, cfg *Config, r io.Reader, out any) error {
+	return decodeJSON(cfg.JSONCodec(), jsonDecodeOptions(c, cfg), r, out)
+}
+
+func decodeJSON(j JSONCodec, o JSONDecodeOptions, r io.Reader, out any) error {
+	dec := j.NewDecoder(r)
+	if o.MaxDepth > 0 || o.DisallowDuplicateKeys || o.DisallowUnknownFields {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return jsonError(err)
+		}
+		if err := checkJSON(raw, o, reflect.TypeOf(out)); err != nil {
+			return err
+		}
+		inner := j.NewDecoder(bytes.NewReader(raw))
+		if err := configureDecoder(inner, o); err != nil {
+			return err
+		}
+		if err := inner.Decode(out); err != nil {
+			return jsonError(err)
+		}
+	} else {
+		if err := configureDecoder(dec, o); err != nil {
+			return err
+		}
+		if err := dec.Decode(out); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return jsonError(err)
+		}
+	}
+	if o.DisallowTrailingData {
+		var extra json.RawMessage
+		if err := dec.Decode(&extra); err != io.EOF {
+			if errors.Is(err, ErrBodyTooLarge) {
+				return err
+			}
+			return badBody("", "unexpected data after the JSON value", ErrTrailingData)
+		}
+	}
+	return nil
+}
+
+func configureDecoder(dec JSONDecoder, o JSONDecodeOptions) error {
+	if o.UseNumber {
+		d, ok := dec.(interface{ UseNumber() })
+		if !ok {
+			return decodeOptionError("UseNumber")
+		}
+		d.UseNumber()
+	}
+	return nil
+}
+
+func decodeOptionError(option string) error {
+	return &Error{
+		Code:    http.StatusInternalServerError,
+		Message: "cenery: the decoder of the JSON codec has no " + option + " method, which JSONDecodeOptions." + option + " needs",
+		Err:     ErrDecodeOption,
+	}
+}
+
+// jsonError turns a decode error into a 400 naming the field when it can.
+// Errors of the body reader, such as ErrBodyTooLarge, are returned as is.
+func jsonError(err error) error {
+	var e *Error
+	if errors.Is(err, ErrBodyTooLarge) || errors.As(err, &e) {
+		return err
+	}
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return badBody(fieldPath(typeErr.Field), "expected "+typeErr.Type.String()+", got "+typeErr.Value, err)
+	case errors.As(err, &syntaxErr):
+		return badBody("", "invalid JSON at offset "+strconv.FormatInt(syntaxErr.Offset, 10), err)
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return badBody("", "unexpected end of JSON", err)
+	}
+	return badBody("", err.Error(), err)
+}
+
+// fieldPath rewrites the "items.0.qty" paths of encoding/json as
+// "items[0].qty".
+func fieldPath(field string) string {
+	var b strings.Builder
+	for i, seg := range strings.Split(field, ".") {
+		if _, err := strconv.Atoi(seg); err == nil {
+			b.WriteString("[" + seg + "]")
+			continue
+		}
+		if i > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(seg)
+	}
+	return b.String()
+}
+
+// checkJSON enforces MaxDepth, DisallowDuplicateKeys and
+// DisallowUnknownFields on a single JSON value decoded into a value of type
+// t. Syntax and type errors are left to the decoder.
+func checkJSON(data []byte, o JSONDecodeOptions, t reflect.Type) error {
+	type frame struct {
+		object  bool
+		wantKey bool
+		key     string
+		index   int
+		keys    map[string]struct{}
+		// typ is the struct, map, slice or array type the value is decoded
+		// into, or nil when its keys are not checked; field is the type of
+		// the value of key.
+		typ   reflect.Type
+		field reflect.Type
+	}
+	var stack []*frame
+	path := func() string {
+		var b strings.Builder
+		for _, f := range stack {
+			switch {
+			case f.object && !f.wantKey:
+				if b.Len() > 0 {
+					b.WriteByte('.')
+				}
+				b.WriteString(f.key)
+			case !f.object:
+				b.WriteString("[" + strconv.Itoa(f.index) + "]")
+			}
+		}
+		return b.String()
+	}
+	// target is the type the next value is decoded into.
+	target := func() reflect.Type {
+		if len(stack) == 0 {
+			return t
+		}
+		top := stack[len(stack)-1]
+		switch {
+		case top.object:
+			return top.field
+		case top.typ != nil:
+			return top.typ.Elem()
+		}
+		return nil
+	}
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := stack[len(stack)-1]; top.object {
+			top.wantKey = true
+		} else {
+			top.index++
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+		if len(stack) > 0 && stack[len(stack)-1].object && stack[len(stack)-1].wantKey {
+			top := stack[len(stack)-1]
+			if tok == json.Delim('}') {
+				stack = stack[:len(stack)-1]
+				valueDone()
+				continue
+			}
+			key, _ := tok.(string)
+			if o.DisallowDuplicateKeys {
+				if _, dup := top.keys[key]; dup {
+					top.key, top.wantKey = key, false
+					return badBody(path(), "duplicate key", ErrDuplicateKey)
+				}
+				top.keys[key] = struct{}{}
+			}
+			top.key, top.wantKey = key, false
+			top.field = nil
+			if top.typ != nil && top.typ.Kind() == reflect.Map {
+				top.field = top.typ.Elem()
+			} else if top.typ != nil {
+				field, ok := jsonFields(top.typ).lookup(key)
+				if !ok && o.DisallowUnknownFields {
+					return badBody(path(), "unknown field", ErrUnknownField)
+				}
+				top.field = field
+			}
+			continue
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			if o.MaxDepth > 0 && len(stack) >= o.MaxDepth {
+				return badBody(path(), "nested deeper than "+strconv.Itoa(o.MaxDepth)+" levels", ErrMaxDepth)
+			}
+			f := &frame{object: tok == json.Delim('{')}
+			typ := jsonTarget(target())
+			if f.object {
+				f.wantKey = true
+				f.keys = make(map[string]struct{})
+				if typ != nil && (typ.Kind() == reflect.Struct || typ.Kind() == reflect.Map) {
+					f.typ = typ
+				}
+			} else if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+				f.typ = typ
+			}
+			stack = append(stack, f)
+			continue
+		case json.Delim(']'), json.Delim('}'):
+			stack = stack[:len(stack)-1]
+		}
+		valueDone()
+	}
+}
+
+var jsonUnmarshalerType = reflect.TypeFor[json.Unmarshaler]()
+
+// jsonTarget dereferences t and returns nil when the keys of the value it
+// receives cannot be checked: interfaces and types that unmarshal
+// themselves.
+func jsonTarget(t reflect.Type) reflect.Type {
+	for t != nil {
+		if t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(jsonUnmarshalerType) || reflect.PointerTo(t).Implements(textUnmarshalerType) {
+			return nil
+		}
+		if t.Kind() != reflect.Pointer {
+			return t
+		}
+		t = t.Elem()
+	}
+	return nil
+}
+
+// fieldSet maps the JSON names of a struct's fields to their types.
+type fieldSet map[string]reflect.Type
+
+// lookup finds the field named key, matching case-insensitively as
+// encoding/json does when there is no exact match.
+func (fs fieldSet) lookup(key string) (reflect.Type, bool) {
+	if t, ok := fs[key]; ok {
+		return t, true
+	}
+	for name, t := range fs {
+		if strings.EqualFold(name, key) {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+var jsonFieldCache sync.Map // reflect.Type -> fieldSet
+
+// jsonFields returns the fields encoding/json decodes into struct type t,
+// including those promoted from embedded structs.
+func jsonFields(t reflect.Type) fieldSet {
+	if fs, ok := jsonFieldCache.Load(t); ok {
+		return fs.(fieldSet)
+	}
+	fs := fieldSet{}
+	addJSONFields(fs, t, map[reflect.Type]bool{})
+	jsonFieldCache.Store(t, fs)
+	return fs
+}
+
+func addJSONFields(fs fieldSet, t reflect.Type, seen map[reflect.Type]bool) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	var embedded []reflect.Type
+	for i := range t.NumField() {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded = append(embedded, ft)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if _, ok := fs[name]; !ok {
+			fs[name] = f.Type
+		}
+	}
+	// Fields of embedded structs are shadowed by the outer ones.
+	for _, et := range embedded {
+		addJSONFields(fs, et, seen)
+	}
+}
@@ -0,0 +1,194 @@
+package cenery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"iter"
+	"log"
+	"reflect"
+	"strconv"
+)
+
+// ErrJSONStreamItems is returned by SendJSONStream for items that are
+// neither an iter.Seq nor a receive channel.
+var ErrJSONStreamItems = errors.New("cenery: SendJSONStream needs an iter.Seq[T] or a channel")
+
+// JSONStreamFormat selects how SendJSONStream writes its items.
+type JSONStreamFormat int
+
+const (
+	// JSONArray writes the items as one JSON array.
+	JSONArray JSONStreamFormat = iota
+	// NDJSON writes one JSON value per line, as application/x-ndjson.
+	NDJSON
+)
+
+// ContentType returns the Content-Type of a stream in format f.
+func (f JSONStreamFormat) ContentType() string {
+	if f == NDJSON {
+		return "application/x-ndjson"
+	}
+	return "application/json"
+}
+
+// JSONStream prepares Ctx.SendJSONStream for engines: it checks items,
+// sets the Content-Type and returns the function that writes the stream to
+// w, flushing after every item. The function stops early, without error,
+// once ctx is done. An item that fails to marshal also stops it without
+// error, since the response has started by then; the error is logged and
+// the client is left with a truncated stream.
+func JSONStream(c Ctx, cfg *Config, items any, format ...JSONStreamFormat) (func(ctx context.Context, w io.Writer, flush func() error) error, error) {
+	f := JSONArray
+	if len(format) > 0 {
+		f = format[0]
+	}
+	rv := reflect.ValueOf(items)
+	if !isItemSeq(rv) && !(rv.Kind() == reflect.Chan && rv.Type().ChanDir()&reflect.RecvDir != 0) {
+		return nil, ErrJSONStreamItems
+	}
+	j := cfg.JSONCodec()
+	c.Response().SetHeader("Content-Type", f.ContentType())
+
+	return func(ctx context.Context, w io.Writer, flush func() error) error {
+		if f == JSONArray {
+			if _, err := io.WriteString(w, "["); err != nil {
+				return nil
+			}
+		}
+		var err error
+		n := 0
+		for item := range itemSeq(ctx, rv) {
+			var data []byte
+			if data, err = j.Marshal(item); err != nil {
+				break
+			}
+			switch {
+			case f == NDJSON:
+				data = append(data, '\n')
+			case n > 0:
+				data = append([]byte{','}, data...)
+			}
+			n++
+			if _, werr := w.Write(data); werr != nil {
+				return nil
+			}
+			if ferr := flush(); ferr != nil {
+				return nil
+			}
+		}
+		if err != nil {
+			log.Printf("cenery: SendJSONStream stopped after %d items: %v", n, err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+		if f == JSONArray {
+			if _, werr := io.WriteString(w, "]"); werr == nil {
+				_ = flush()
+			}
+		}
+		return nil
+	}, nil
+}
+
+// isItemSeq reports whether rv is a func(yield func(T) bool), such as an
+// iter.Seq[T].
+func isItemSeq(rv reflect.Value) bool {
+	if rv.Kind() != reflect.Func || rv.IsNil() {
+		return false
+	}
+	t := rv.Type()
+	if t.NumIn() != 1 || t.NumOut() != 0 {
+		return false
+	}
+	yield := t.In(0)
+	return yield.Kind() == reflect.Func && yield.NumIn() == 1 && yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool
+}
+
+// itemSeq ranges over an iter.Seq[T] or a channel until it ends or ctx is
+// done.
+func itemSeq(ctx context.Context, rv reflect.Value) iter.Seq[any] {
+	if seq, ok := rv.Interface().(iter.Seq[any]); ok {
+		return func(yield func(any) bool) {
+			for item := range seq {
+				if ctx.Err() != nil || !yield(item) {
+					return
+				}
+			}
+		}
+	}
+	if rv.Kind() == reflect.Chan {
+		return func(yield func(any) bool) {
+			cases := []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: rv},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+			}
+			for {
+				chosen, item, ok := reflect.Select(cases)
+				if chosen == 1 || !ok || !yield(item.Interface()) {
+					return
+				}
+			}
+		}
+	}
+	return func(yield func(any) bool) {
+		fn := reflect.MakeFunc(rv.Type().In(0), func(args []reflect.Value) []reflect.Value {
+			more := ctx.Err() == nil && yield(args[0].Interface())
+			return []reflect.Value{reflect.ValueOf(more)}
+		})
+		rv.Call([]reflect.Value{fn})
+	}
+}
+
+// DecodeNDJSON implements Ctx.BodyParserNDJSON for engines: it decodes
+// every non-blank line of r into out, zeroed first, and calls fn after
+// each. Lines must hold exactly one JSON value and honour the route's
+// JSONDecodeOptions; a bad line is a 400 whose field starts with its item
+// index, such as "[3].name".
+func DecodeNDJSON(c Ctx, cfg *Config, r io.Reader, out any, fn func() error) error {
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return errors.New("cenery: BodyParserNDJSON needs a non-nil pointer")
+	}
+	j := cfg.JSONCodec()
+	o := jsonDecodeOptions(c, cfg)
+	o.DisallowTrailingData = true
+
+	br := bufio.NewReader(r)
+	for index := 0; ; {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			rv.Elem().SetZero()
+			if derr := decodeJSON(j, o, bytes.NewReader(trimmed), out); derr != nil {
+				return itemError(index, derr)
+			}
+			if ferr := fn(); ferr != nil {
+				return ferr
+			}
+			index++
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// itemError prefixes the field of a *BodyError with the item index.
+func itemError(index int, err error) error {
+	var be *BodyError
+	if !errors.As(err, &be) {
+		return err
+	}
+	field := "[" + strconv.Itoa(index) + "]"
+	if be.Field != "" {
+		field += "." + be.Field
+	}
+	return badBody(field, be.Reason, be.Err)
+}
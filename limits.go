@@ -0,0 +1,219 @@
+package cenery
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+)
+
+const (
+	// DefaultBodyLimit matches the default of fasthttp and fiber.
+	DefaultBodyLimit = 4 << 20
+	// DefaultMultipartMemory matches net/http's ParseMultipartForm default
+	// used by most frameworks.
+	DefaultMultipartMemory = 32 << 20
+)
+
+// Errors wrapped by the 413 *Error returned when a request breaks its
+// Limits. Each request gets its own *Error, made by RequestError; match
+// them with errors.Is.
+var (
+	// ErrBodyTooLarge is returned when the request body exceeds BodyLimit.
+	ErrBodyTooLarge = errors.New("cenery: request body too large")
+	// ErrTooManyFiles is returned when a multipart form exceeds MaxFiles.
+	ErrTooManyFiles = errors.New("cenery: too many files")
+	// ErrFileTooLarge is returned when an uploaded file exceeds MaxFileSize.
+	ErrFileTooLarge = errors.New("cenery: file too large")
+)
+
+// RequestError returns a new *Error wrapping err, with the status and
+// message clients get for it, when err is ErrBodyTooLarge,
+// ErrTooManyFiles, ErrFileTooLarge, ErrNotMultipart or ErrFileNotFound.
+// Engines return it in place of those errors, so a handler that changes
+// the *Error it got does not change it for other requests. Any other err
+// is returned as is.
+func RequestError(err error) error {
+	switch err {
+	case ErrBodyTooLarge:
+		return &Error{Code: http.StatusRequestEntityTooLarge, Message: http.StatusText(http.StatusRequestEntityTooLarge), Err: err}
+	case ErrTooManyFiles:
+		return &Error{Code: http.StatusRequestEntityTooLarge, Message: "too many files", Err: err}
+	case ErrFileTooLarge:
+		return &Error{Code: http.StatusRequestEntityTooLarge, Message: "file too large", Err: err}
+	case ErrNotMultipart:
+		return &Error{Code: http.StatusBadRequest, Message: "request is not multipart/form-data", Err: err}
+	case ErrFileNotFound:
+		return &Error{Code: http.StatusBadRequest, Message: "file not found", Err: err}
+	}
+	return err
+}
+
+// NoFiles is the MaxFiles of Limits that rejects every file, as a zero
+// MaxFiles is unset.
+const NoFiles = -2
+
+// Limits bounds how much of a request body handlers may read. Zero fields
+// are unset: they take the default, or the app's value in a route override.
+// A negative BodyLimit, MaxFiles or MaxFileSize disables that check, except
+// for a MaxFiles of NoFiles.
+//
+// On fiber and fasthttp the body is read by the server before routing, so
+// the app-level BodyLimit is also used as the server's limit and a route
+// cannot raise it; MultipartMemory does not apply there.
+type Limits struct {
+	// BodyLimit is the maximum request body size in bytes.
+	// Defaults to DefaultBodyLimit.
+	BodyLimit int64
+	// MultipartMemory is how much of a multipart form is held in memory;
+	// larger files are stored in temporary files. Defaults to
+	// DefaultMultipartMemory.
+	MultipartMemory int64
+	// MaxFiles is the maximum number of files in a multipart form, or
+	// NoFiles to allow none. Unlimited by default.
+	MaxFiles int
+	// MaxFileSize is the maximum size of a single uploaded file in bytes.
+	// Unlimited by default.
+	MaxFileSize int64
+}
+
+// DefaultLimits returns the limits used when none are configured.
+func DefaultLimits() Limits {
+	return Limits{
+		BodyLimit:       DefaultBodyLimit,
+		MultipartMemory: DefaultMultipartMemory,
+		MaxFiles:        -1,
+		MaxFileSize:     -1,
+	}
+}
+
+// Merge returns l with the set fields of o applied over it.
+func (l Limits) Merge(o Limits) Limits {
+	if o.BodyLimit != 0 {
+		l.BodyLimit = o.BodyLimit
+	}
+	if o.MultipartMemory != 0 {
+		l.MultipartMemory = o.MultipartMemory
+	}
+	if o.MaxFiles != 0 {
+		l.MaxFiles = o.MaxFiles
+	}
+	if o.MaxFileSize != 0 {
+		l.MaxFileSize = o.MaxFileSize
+	}
+	return l
+}
+
+// maxFiles returns the number of files MaxFiles allows, or -1 for any.
+func (l Limits) maxFiles() int {
+	switch {
+	case l.MaxFiles == NoFiles:
+		return 0
+	case l.MaxFiles < 0:
+		return -1
+	}
+	return l.MaxFiles
+}
+
+// ChecksFiles reports whether MaxFiles or MaxFileSize limits the files of
+// a multipart form.
+func (l Limits) ChecksFiles() bool {
+	return l.maxFiles() >= 0 || l.MaxFileSize >= 0
+}
+
+// CheckBodySize returns ErrBodyTooLarge if size exceeds BodyLimit.
+func (l Limits) CheckBodySize(size int64) error {
+	if l.BodyLimit >= 0 && size > l.BodyLimit {
+		return RequestError(ErrBodyTooLarge)
+	}
+	return nil
+}
+
+// CheckForm returns ErrTooManyFiles or ErrFileTooLarge if the files of a
+// parsed multipart form exceed MaxFiles or MaxFileSize.
+func (l Limits) CheckForm(form *multipart.Form) error {
+	if form == nil || !l.ChecksFiles() {
+		return nil
+	}
+	maxFiles := l.maxFiles()
+	count := 0
+	for _, files := range form.File {
+		count += len(files)
+		if maxFiles >= 0 && count > maxFiles {
+			return RequestError(ErrTooManyFiles)
+		}
+		for _, f := range files {
+			if l.MaxFileSize >= 0 && f.Size > l.MaxFileSize {
+				return RequestError(ErrFileTooLarge)
+			}
+		}
+	}
+	return nil
+}
+
+// LimitBody wraps a request body so reading past BodyLimit fails with
+// ErrBodyTooLarge. If the declared contentLength already exceeds the limit,
+// the first read fails without consuming the body. A negative contentLength
+// means unknown.
+func (l Limits) LimitBody(body io.ReadCloser, contentLength int64) io.ReadCloser {
+	if l.BodyLimit < 0 || body == nil {
+		return body
+	}
+	lb := &limitedBody{body: body, remaining: l.BodyLimit}
+	if contentLength > l.BodyLimit {
+		lb.err = RequestError(ErrBodyTooLarge)
+	}
+	return lb
+}
+
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	err       error
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// Read one byte past the limit to tell an exact fit from an overflow.
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.body.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		b.err = RequestError(ErrBodyTooLarge)
+		return n, b.err
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.body.Close()
+}
+
+// LimitSetter is implemented by engine contexts whose limits can be changed
+// for the rest of a request.
+type LimitSetter interface {
+	// SetLimits applies the set fields of l for this and later handlers.
+	SetLimits(l Limits)
+}
+
+// RouteLimits returns a handler that overrides the app's limits for the
+// rest of the chain, typically placed first in a route's handlers:
+//
+//	app.Post("/upload", cenery.RouteLimits(cenery.Limits{BodyLimit: 100 << 20}), upload)
+func RouteLimits(l Limits) Handler {
+	return func(c Ctx) error {
+		if ls, ok := c.(LimitSetter); ok {
+			ls.SetLimits(l)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/dreamph/cenery"
+)
+
+type APIKeyConfig struct {
+	// KeyLookup is where the key is read from, as "<source>:<name>" with
+	// source one of header, query or cookie. Defaults to "header:X-API-Key".
+	KeyLookup string
+
+	// AuthScheme is the scheme that must prefix a header key, such as
+	// "Bearer" with a KeyLookup of "header:Authorization".
+	AuthScheme string
+
+	// Keys maps valid keys to the subject they belong to. Keys are compared
+	// in constant time. Ignored when Validator is set.
+	Keys map[string]string
+
+	// Validator checks a key and returns the subject it belongs to.
+	Validator func(c cenery.Ctx, key string) (subject string, ok bool)
+
+	// Skip bypasses authentication for requests it returns true for.
+	Skip func(c cenery.Ctx) bool
+}
+
+// APIKey returns a middleware that requires a valid API key.
+func APIKey(config APIKeyConfig) cenery.Handler {
+	if config.KeyLookup == "" {
+		config.KeyLookup = "header:X-API-Key"
+	}
+	if config.Validator == nil {
+		keys := config.Keys
+		config.Validator = func(_ cenery.Ctx, key string) (string, bool) {
+			// Check every key so timing does not reveal which one matched.
+			var subject string
+			found := false
+			for k, s := range keys {
+				if secureCompare(key, k) {
+					subject, found = s, true
+				}
+			}
+			return subject, found
+		}
+	}
+	extract := newExtractor(config.KeyLookup, config.AuthScheme)
+
+	var challenge string
+	if config.AuthScheme != "" && strings.HasPrefix(config.KeyLookup, "header:") {
+		challenge = config.AuthScheme + " realm=" + strconv.Quote("Restricted")
+	}
+
+	return func(c cenery.Ctx) error {
+		if config.Skip != nil && config.Skip(c) {
+			return c.Next()
+		}
+
+		key := extract(c)
+		if key == "" {
+			return unauthorized(c, challenge, ErrUnauthorized)
+		}
+		subject, ok := config.Validator(c, key)
+		if !ok {
+			return unauthorized(c, challenge, errInvalidCredentials)
+		}
+
+		c.Locals(PrincipalKey, &Principal{Scheme: "apikey", Subject: subject})
+		return c.Next()
+	}
+}
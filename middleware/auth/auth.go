@@ -0,0 +1,106 @@
+// Package auth provides authentication middleware: HTTP Basic, API keys and
+// JWT bearer tokens.
+//
+// Each middleware stores the authenticated *Principal in the request locals
+// under PrincipalKey, where later handlers read it with FromCtx. Failed
+// requests get a 401 *cenery.Error so the app's error handler writes the
+// response, with a WWW-Authenticate header where the scheme defines one.
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/dreamph/cenery"
+)
+
+// PrincipalKey is the Ctx locals key the authenticated principal is stored under.
+const PrincipalKey = "cenery.auth.principal"
+
+// Principal is the authenticated client of a request.
+type Principal struct {
+	// Scheme is the middleware that authenticated the request:
+	// "basic", "apikey" or "jwt".
+	Scheme string
+	// Subject identifies the client: the username, the subject returned by
+	// the API key validator, or the JWT "sub" claim.
+	Subject string
+	// Claims holds the token claims for JWT; nil otherwise.
+	Claims Claims
+}
+
+// FromCtx returns the principal stored by an auth middleware.
+func FromCtx(c cenery.Ctx) (*Principal, bool) {
+	p, ok := c.Locals(PrincipalKey).(*Principal)
+	return p, ok
+}
+
+// ErrUnauthorized is the cause of 401 responses when no credentials were sent.
+var ErrUnauthorized = cenery.NewError(http.StatusUnauthorized)
+
+// unauthorized returns a 401 error wrapping cause and sets challenge, if
+// any, as the WWW-Authenticate header.
+func unauthorized(c cenery.Ctx, challenge string, cause error) error {
+	if challenge != "" {
+		c.Response().SetHeader("WWW-Authenticate", challenge)
+	}
+	if cause == nil || cause == ErrUnauthorized {
+		return ErrUnauthorized
+	}
+	return &cenery.Error{Code: http.StatusUnauthorized, Message: http.StatusText(http.StatusUnauthorized), Err: cause}
+}
+
+// secureCompare reports whether a and b are equal in constant time. Both
+// are hashed first so the comparison does not leak their lengths.
+func secureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// extractor reads a credential from a request.
+type extractor func(c cenery.Ctx) string
+
+// newExtractor parses a lookup of the form "<source>:<name>", where source
+// is header, query or cookie. For headers, a non-empty scheme such as
+// "Bearer" must prefix the value and is stripped from it.
+func newExtractor(lookup, scheme string) extractor {
+	source, name, ok := strings.Cut(lookup, ":")
+	if !ok || name == "" {
+		panic("auth: invalid key lookup " + lookup)
+	}
+	switch source {
+	case "header":
+		return func(c cenery.Ctx) string {
+			val := c.Request().GetHeader(name)
+			if scheme == "" {
+				return val
+			}
+			if len(val) > len(scheme) && strings.EqualFold(val[:len(scheme)], scheme) && val[len(scheme)] == ' ' {
+				return strings.TrimSpace(val[len(scheme)+1:])
+			}
+			return ""
+		}
+	case "query":
+		return func(c cenery.Ctx) string {
+			return c.QueryParam(name)
+		}
+	case "cookie":
+		return func(c cenery.Ctx) string {
+			header := c.Request().GetHeader("Cookie")
+			if header == "" {
+				return ""
+			}
+			r := http.Request{Header: http.Header{"Cookie": {header}}}
+			cookie, err := r.Cookie(name)
+			if err != nil {
+				return ""
+			}
+			return cookie.Value
+		}
+	default:
+		panic("auth: invalid key lookup source " + source)
+	}
+}
@@ -0,0 +1,439 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+type testHeaders map[string]string
+
+type testRequest struct {
+	cenery.Request
+	headers testHeaders
+}
+
+func (r *testRequest) GetHeader(key string) string { return r.headers[key] }
+
+type testResponse struct {
+	cenery.Response
+	headers testHeaders
+}
+
+func (r *testResponse) SetHeader(key string, val string) { r.headers[key] = val }
+
+type testCtx struct {
+	cenery.Ctx
+	req    *testRequest
+	resp   *testResponse
+	query  map[string]string
+	locals map[string]any
+	next   bool
+}
+
+func newTestCtx() *testCtx {
+	return &testCtx{
+		req:    &testRequest{headers: testHeaders{}},
+		resp:   &testResponse{headers: testHeaders{}},
+		query:  map[string]string{},
+		locals: map[string]any{},
+	}
+}
+
+func (c *testCtx) Context() context.Context  { return context.Background() }
+func (c *testCtx) Request() cenery.Request   { return c.req }
+func (c *testCtx) Response() cenery.Response { return c.resp }
+
+func (c *testCtx) QueryParam(key string, _ ...string) string { return c.query[key] }
+
+func (c *testCtx) Locals(key string, value ...any) any {
+	if len(value) > 0 {
+		c.locals[key] = value[0]
+	}
+	return c.locals[key]
+}
+
+func (c *testCtx) Next() error {
+	c.next = true
+	return nil
+}
+
+func wantStatus(t *testing.T, err error, code int) {
+	t.Helper()
+	var e *cenery.Error
+	if !errors.As(err, &e) || e.Code != code {
+		t.Fatalf("error = %v, want status %v", err, code)
+	}
+}
+
+func TestBasic(t *testing.T) {
+	handler := Basic(BasicConfig{Users: map[string]string{"alice": "secret"}, Realm: "api"})
+
+	c := newTestCtx()
+	c.req.headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret"))
+	if err := handler(c); err != nil {
+		t.Fatalf("handler() error = %v", err)
+	}
+	p, ok := FromCtx(c)
+	if !ok || p.Subject != "alice" || p.Scheme != "basic" {
+		t.Fatalf("FromCtx() = %+v, want alice", p)
+	}
+
+	for _, header := range []string{
+		"",
+		"Basic " + base64.StdEncoding.EncodeToString([]byte("alice:wrong")),
+		"Basic " + base64.StdEncoding.EncodeToString([]byte("bob:secret")),
+		"Basic not-base64",
+	} {
+		c := newTestCtx()
+		c.req.headers["Authorization"] = header
+		wantStatus(t, handler(c), http.StatusUnauthorized)
+		if c.next {
+			t.Errorf("%q reached next handler", header)
+		}
+		if got := c.resp.headers["WWW-Authenticate"]; got != `Basic realm="api", charset="UTF-8"` {
+			t.Errorf("WWW-Authenticate = %v", got)
+		}
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	keys := map[string]string{"k1": "service-a"}
+	tests := []struct {
+		name   string
+		config APIKeyConfig
+		set    func(c *testCtx)
+	}{
+		{"header", APIKeyConfig{}, func(c *testCtx) { c.req.headers["X-API-Key"] = "k1" }},
+		{"bearer", APIKeyConfig{KeyLookup: "header:Authorization", AuthScheme: "Bearer"}, func(c *testCtx) {
+			c.req.headers["Authorization"] = "Bearer k1"
+		}},
+		{"query", APIKeyConfig{KeyLookup: "query:api_key"}, func(c *testCtx) { c.query["api_key"] = "k1" }},
+		{"cookie", APIKeyConfig{KeyLookup: "cookie:api_key"}, func(c *testCtx) {
+			c.req.headers["Cookie"] = "theme=dark; api_key=k1"
+		}},
+	}
+	for _, tt := range tests {
+		tt.config.Keys = keys
+		handler := APIKey(tt.config)
+
+		c := newTestCtx()
+		tt.set(c)
+		if err := handler(c); err != nil {
+			t.Fatalf("%v: handler() error = %v", tt.name, err)
+		}
+		if p, _ := FromCtx(c); p == nil || p.Subject != "service-a" {
+			t.Errorf("%v: principal = %+v, want service-a", tt.name, p)
+		}
+
+		wantStatus(t, handler(newTestCtx()), http.StatusUnauthorized)
+	}
+
+	c := newTestCtx()
+	c.req.headers["X-API-Key"] = "k2"
+	wantStatus(t, APIKey(APIKeyConfig{Keys: keys})(c), http.StatusUnauthorized)
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign builds a compact JWT. It only covers what the tests need.
+func sign(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	p, _ := json.Marshal(claims)
+	input := b64(h) + "." + b64(p)
+
+	var sig []byte
+	var err error
+	digest := func(hash crypto.Hash) []byte {
+		d := hash.New()
+		d.Write([]byte(input))
+		return d.Sum(nil)
+	}
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg == "PS256" {
+			sig, err = rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest(crypto.SHA256), &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		} else {
+			sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest(crypto.SHA256))
+		}
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest(crypto.SHA256))
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(input))
+	}
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return input + "." + b64(sig)
+}
+
+func runJWT(handler cenery.Handler, token string) (*testCtx, error) {
+	c := newTestCtx()
+	c.req.headers["Authorization"] = "Bearer " + token
+	return c, handler(c)
+}
+
+func TestJWTAlgorithms(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	claims := map[string]any{"sub": "alice"}
+
+	tests := []struct {
+		alg    string
+		signer any
+		verify any
+	}{
+		{"HS256", secret, secret},
+		{"RS256", rsaKey, &rsaKey.PublicKey},
+		{"PS256", rsaKey, &rsaKey.PublicKey},
+		{"ES256", ecKey, &ecKey.PublicKey},
+		{"EdDSA", edKey, edKey.Public()},
+	}
+	for _, tt := range tests {
+		handler := JWT(JWTConfig{Key: tt.verify})
+		c, err := runJWT(handler, sign(t, tt.alg, "", tt.signer, claims))
+		if err != nil {
+			t.Fatalf("%v: handler() error = %v", tt.alg, err)
+		}
+		if p, _ := FromCtx(c); p == nil || p.Subject != "alice" || p.Claims.Subject() != "alice" {
+			t.Errorf("%v: principal = %+v, want alice", tt.alg, p)
+		}
+
+		// Flip a byte of the signature.
+		token := sign(t, tt.alg, "", tt.signer, claims)
+		sig, _ := base64.RawURLEncoding.DecodeString(token[strings.LastIndex(token, ".")+1:])
+		sig[0] ^= 0xff
+		token = token[:strings.LastIndex(token, ".")+1] + b64(sig)
+		if _, err := runJWT(handler, token); !errors.Is(err, ErrTokenSignature) {
+			t.Errorf("%v: tampered error = %v, want %v", tt.alg, err, ErrTokenSignature)
+		}
+	}
+
+	// An RSA public key must not be usable as an HMAC secret.
+	pem := []byte("not a secret")
+	handler := JWT(JWTConfig{Key: &rsaKey.PublicKey})
+	if _, err := runJWT(handler, sign(t, "HS256", "", pem, claims)); !errors.Is(err, ErrTokenAlgorithm) {
+		t.Errorf("alg confusion error = %v, want %v", err, ErrTokenAlgorithm)
+	}
+
+	// "none" is never accepted.
+	h, _ := json.Marshal(map[string]any{"alg": "none"})
+	p, _ := json.Marshal(claims)
+	if _, err := runJWT(JWT(JWTConfig{Key: secret}), b64(h)+"."+b64(p)+"."); !errors.Is(err, ErrTokenAlgorithm) {
+		t.Errorf("none error = %v, want %v", err, ErrTokenAlgorithm)
+	}
+}
+
+func TestJWTClaims(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1_700_000_000, 0)
+	handler := JWT(JWTConfig{
+		Key:      secret,
+		Issuer:   "https://issuer.example",
+		Audience: []string{"api"},
+		Leeway:   time.Minute,
+		Clock:    func() time.Time { return now },
+	})
+	valid := func() map[string]any {
+		return map[string]any{
+			"sub": "alice",
+			"iss": "https://issuer.example",
+			"aud": []string{"web", "api"},
+			"exp": now.Add(time.Hour).Unix(),
+			"nbf": now.Add(-time.Hour).Unix(),
+		}
+	}
+
+	if _, err := runJWT(handler, sign(t, "HS256", "", secret, valid())); err != nil {
+		t.Fatalf("valid token error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(m map[string]any)
+		want   error
+	}{
+		{"expired", func(m map[string]any) { m["exp"] = now.Add(-2 * time.Minute).Unix() }, ErrTokenExpired},
+		{"not yet valid", func(m map[string]any) { m["nbf"] = now.Add(2 * time.Minute).Unix() }, ErrTokenNotYetValid},
+		{"issuer", func(m map[string]any) { m["iss"] = "https://evil.example" }, ErrTokenIssuer},
+		{"audience", func(m map[string]any) { m["aud"] = "web" }, ErrTokenAudience},
+	}
+	for _, tt := range tests {
+		claims := valid()
+		tt.modify(claims)
+		c, err := runJWT(handler, sign(t, "HS256", "", secret, claims))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%v: error = %v, want %v", tt.name, err, tt.want)
+		}
+		wantStatus(t, err, http.StatusUnauthorized)
+		if got := c.resp.headers["WWW-Authenticate"]; got != `Bearer error="invalid_token"` {
+			t.Errorf("%v: WWW-Authenticate = %v", tt.name, got)
+		}
+	}
+
+	// Within the leeway.
+	claims := valid()
+	claims["exp"] = now.Add(-30 * time.Second).Unix()
+	if _, err := runJWT(handler, sign(t, "HS256", "", secret, claims)); err != nil {
+		t.Errorf("token within leeway error = %v", err)
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	edPub := edKey.Public().(ed25519.PublicKey)
+
+	set := map[string]any{"keys": []map[string]any{
+		{"kty": "RSA", "kid": "rsa", "alg": "RS256", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(ecKey.X.FillBytes(make([]byte, 32))), "y": b64(ecKey.Y.FillBytes(make([]byte, 32)))},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": b64(edPub)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": b64(rsaKey.N.Bytes()), "e": "AQAB"},
+	}}
+	data, _ := json.Marshal(set)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		_, _ = w.Write(data)
+	}))
+	defer srv.Close()
+
+	for _, source := range []string{path, srv.URL} {
+		handler := JWT(JWTConfig{JWKS: NewJWKS(JWKSConfig{Source: source})})
+		claims := map[string]any{"sub": "alice"}
+		for _, tok := range []string{
+			sign(t, "RS256", "rsa", rsaKey, claims),
+			sign(t, "ES256", "ec", ecKey, claims),
+			sign(t, "EdDSA", "ed", edKey, claims),
+		} {
+			if _, err := runJWT(handler, tok); err != nil {
+				t.Errorf("%v: handler() error = %v", source, err)
+			}
+		}
+		if _, err := runJWT(handler, sign(t, "RS256", "enc", rsaKey, claims)); !errors.Is(err, ErrKeyNotFound) {
+			t.Errorf("%v: encryption key error = %v, want %v", source, err, ErrKeyNotFound)
+		}
+	}
+
+	// The set is cached, and the unknown "enc" kid does not trigger another
+	// fetch within MinRefreshInterval of the first.
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches = %v, want %v", n, 1)
+	}
+}
+
+func TestJWKSFailedLoad(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	jwks := NewJWKS(JWKSConfig{Source: srv.URL, MinRefreshInterval: 20 * time.Millisecond})
+	ctx := context.Background()
+	for range 5 {
+		if _, err := jwks.Key(ctx, "a", "HS256"); err == nil {
+			t.Fatalf("Key() error = nil while the source fails")
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("fetches = %v, want %v", n, 1)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	_, _ = jwks.Key(ctx, "a", "HS256")
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches after MinRefreshInterval = %v, want %v", n, 2)
+	}
+}
+
+func TestJWKSRefresh(t *testing.T) {
+	set := func(kid string) []byte {
+		data, _ := json.Marshal(map[string]any{"keys": []map[string]any{{"kty": "oct", "kid": kid, "k": b64([]byte("secret"))}}})
+		return data
+	}
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fetches.Add(1) == 1 {
+			_, _ = w.Write(set("old"))
+			return
+		}
+		<-release
+		_, _ = w.Write(set("new"))
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	jwks := NewJWKS(JWKSConfig{Source: srv.URL, RefreshInterval: time.Millisecond, MinRefreshInterval: time.Millisecond})
+	ctx := context.Background()
+	if _, err := jwks.Key(ctx, "old", "HS256"); err != nil {
+		t.Fatalf("Key() error = %v", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+
+	// The set is stale and its reload hangs: the cached keys are still served.
+	for range 3 {
+		if _, err := jwks.Key(ctx, "old", "HS256"); err != nil {
+			t.Fatalf("Key() during reload error = %v", err)
+		}
+	}
+	// An unknown kid waits for the reload, up to its context.
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := jwks.Key(waitCtx, "new", "HS256"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Key(new) during reload error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("fetches = %v, want %v", n, 2)
+	}
+
+	release <- struct{}{}
+	if _, err := jwks.Key(ctx, "new", "HS256"); err != nil {
+		t.Errorf("Key(new) after reload error = %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/dreamph/cenery"
+)
+
+type BasicConfig struct {
+	// Users maps usernames to passwords. Passwords are compared in constant
+	// time. Ignored when Validator is set.
+	Users map[string]string
+
+	// Validator checks a username and password, for example against a
+	// password hash store.
+	Validator func(c cenery.Ctx, username, password string) bool
+
+	// Realm is sent in the WWW-Authenticate challenge. Defaults to "Restricted".
+	Realm string
+
+	// Skip bypasses authentication for requests it returns true for.
+	Skip func(c cenery.Ctx) bool
+}
+
+var errInvalidCredentials = errors.New("auth: invalid credentials")
+
+// Basic returns a middleware that requires HTTP Basic authentication.
+func Basic(config BasicConfig) cenery.Handler {
+	if config.Realm == "" {
+		config.Realm = "Restricted"
+	}
+	if config.Validator == nil {
+		users := config.Users
+		config.Validator = func(_ cenery.Ctx, username, password string) bool {
+			want, ok := users[username]
+			// Compare even for unknown users so they take as long as known ones.
+			match := secureCompare(password, want)
+			return ok && match
+		}
+	}
+	challenge := "Basic realm=" + strconv.Quote(config.Realm) + `, charset="UTF-8"`
+
+	return func(c cenery.Ctx) error {
+		if config.Skip != nil && config.Skip(c) {
+			return c.Next()
+		}
+
+		username, password, ok := parseBasic(c.Request().GetHeader("Authorization"))
+		if !ok {
+			return unauthorized(c, challenge, ErrUnauthorized)
+		}
+		if !config.Validator(c, username, password) {
+			return unauthorized(c, challenge, errInvalidCredentials)
+		}
+
+		c.Locals(PrincipalKey, &Principal{Scheme: "basic", Subject: username})
+		return c.Next()
+	}
+}
+
+func parseBasic(header string) (username, password string, ok bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(header[len(prefix):]))
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type JWKSConfig struct {
+	// Source is an http(s) URL or a local file path to load the key set from.
+	Source string
+
+	// RefreshInterval is how long a loaded key set is used before it is
+	// loaded again. Defaults to 1 hour.
+	RefreshInterval time.Duration
+
+	// MinRefreshInterval is the minimum time between loads triggered by a
+	// token with an unknown "kid", for example after a key rotation, and
+	// between retries while the first load keeps failing. Defaults to 1
+	// minute.
+	MinRefreshInterval time.Duration
+
+	// Client fetches URL sources. Defaults to a client with a 10s timeout.
+	Client *http.Client
+}
+
+// JWKS is a JSON Web Key Set loaded on first use and cached. It is safe for
+// concurrent use.
+type JWKS struct {
+	config JWKSConfig
+
+	mu          sync.Mutex
+	keys        []jwk
+	loaded      time.Time
+	lastAttempt time.Time
+	lastErr     error     // of the last load
+	loading     *jwksLoad // in flight, nil when none
+}
+
+// jwksLoad is a load of the key set shared by every caller waiting for it.
+type jwksLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKS creates a key set that loads from config.Source.
+func NewJWKS(config JWKSConfig) *JWKS {
+	if config.Source == "" {
+		panic("auth: JWKS requires a Source")
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = time.Hour
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = time.Minute
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWKS{config: config}
+}
+
+// Key returns the verification key with the given kid. An empty kid matches
+// the only key in the set usable with alg. The set is loaded outside the
+// lock, once for all callers: a stale set keeps being used while it is
+// reloaded, so only the first load and unknown kids wait for the source.
+// Until a load succeeds, a failed one is retried at most once per
+// MinRefreshInterval; the requests in between get its error.
+func (j *JWKS) Key(ctx context.Context, kid, alg string) (any, error) {
+	j.mu.Lock()
+	if j.keys == nil {
+		if j.loading == nil && j.lastErr != nil && time.Since(j.lastAttempt) < j.config.MinRefreshInterval {
+			err := j.lastErr
+			j.mu.Unlock()
+			return nil, err
+		}
+		load := j.startLoad(ctx)
+		j.mu.Unlock()
+		if err := load.wait(ctx); err != nil {
+			return nil, err
+		}
+		j.mu.Lock()
+	}
+	now := time.Now()
+	if now.Sub(j.loaded) >= j.config.RefreshInterval && now.Sub(j.lastAttempt) >= j.config.MinRefreshInterval {
+		j.startLoad(ctx)
+	}
+	key, ok := j.find(kid, alg)
+	load := j.loading
+	if !ok && load == nil && now.Sub(j.lastAttempt) >= j.config.MinRefreshInterval {
+		load = j.startLoad(ctx)
+	}
+	j.mu.Unlock()
+	if ok {
+		return key, nil
+	}
+	if load == nil {
+		return nil, ErrKeyNotFound
+	}
+	// An unknown kid may be a rotated key: wait for the reload.
+	if err := load.wait(ctx); err != nil {
+		return nil, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if key, ok := j.find(kid, alg); ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// startLoad starts loading the key set unless a load is already in flight,
+// and returns that load. j.mu must be held. The load outlives ctx, as other
+// callers may wait for it.
+func (j *JWKS) startLoad(ctx context.Context) *jwksLoad {
+	if j.loading != nil {
+		return j.loading
+	}
+	load := &jwksLoad{done: make(chan struct{})}
+	j.loading = load
+	j.lastAttempt = time.Now()
+	go func() {
+		keys, err := j.load(context.WithoutCancel(ctx))
+		j.mu.Lock()
+		if err == nil {
+			j.keys = keys
+			j.loaded = time.Now()
+		}
+		load.err = err
+		j.lastErr = err
+		j.loading = nil
+		j.mu.Unlock()
+		close(load.done)
+	}()
+	return load
+}
+
+// wait returns the error of the load, or that of ctx if it is done first.
+func (l *jwksLoad) wait(ctx context.Context) error {
+	select {
+	case <-l.done:
+		return l.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (j *JWKS) find(kid, alg string) (any, bool) {
+	var match *jwk
+	for i := range j.keys {
+		k := &j.keys[i]
+		if k.Alg != "" && k.Alg != alg {
+			continue
+		}
+		if kid != "" {
+			if k.Kid == kid {
+				return k.key, true
+			}
+			continue
+		}
+		if match != nil {
+			return nil, false // ambiguous without a kid
+		}
+		match = k
+	}
+	if match == nil {
+		return nil, false
+	}
+	return match.key, true
+}
+
+// load reads and parses the key set. On failure the cached keys are kept.
+func (j *JWKS) load(ctx context.Context) ([]jwk, error) {
+	data, err := j.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func (j *JWKS) read(ctx context.Context) ([]byte, error) {
+	src := j.config.Source
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return os.ReadFile(src)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := j.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: fetching JWKS: %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+
+	key any
+}
+
+// parseJWKS decodes a key set, skipping keys that are not for signatures
+// or of an unsupported type.
+func parseJWKS(data []byte) ([]jwk, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("auth: invalid JWKS: %w", err)
+	}
+	keys := make([]jwk, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		k.key = key
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+var errUnsupportedKey = errors.New("auth: unsupported JWK")
+
+func (k *jwk) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return nil, errUnsupportedKey
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		var ecdhCurve ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, ecdhCurve = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, ecdhCurve = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, ecdhCurve = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, errUnsupportedKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errUnsupportedKey
+		}
+		// Let crypto/ecdh reject points that are not on the curve.
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdhCurve.NewPublicKey(point); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, errUnsupportedKey
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errUnsupportedKey
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, errUnsupportedKey
+		}
+		return secret, nil
+	}
+	return nil, errUnsupportedKey
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errUnsupportedKey
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,398 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+var (
+	ErrTokenMalformed   = errors.New("auth: malformed token")
+	ErrTokenAlgorithm   = errors.New("auth: token algorithm not allowed")
+	ErrTokenSignature   = errors.New("auth: invalid token signature")
+	ErrTokenExpired     = errors.New("auth: token is expired")
+	ErrTokenNotYetValid = errors.New("auth: token is not valid yet")
+	ErrTokenIssuer      = errors.New("auth: invalid token issuer")
+	ErrTokenAudience    = errors.New("auth: invalid token audience")
+	ErrKeyNotFound      = errors.New("auth: signing key not found")
+)
+
+// Claims are the claims of a verified JWT. Numbers are decoded as json.Number.
+type Claims map[string]any
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// Issuer returns the "iss" claim.
+func (c Claims) Issuer() string {
+	s, _ := c["iss"].(string)
+	return s
+}
+
+// Audience returns the "aud" claim, which may be a string or an array.
+func (c Claims) Audience() []string {
+	switch aud := c["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []any:
+		out := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// numericDate returns a NumericDate claim such as "exp" or "nbf".
+func (c Claims) numericDate(name string) (time.Time, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, false, ErrTokenMalformed
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, false, ErrTokenMalformed
+	}
+	sec, frac := int64(f), f-float64(int64(f))
+	return time.Unix(sec, int64(frac*float64(time.Second))), true, nil
+}
+
+// Token is a decoded JWT whose signature has not been verified yet. It is
+// passed to JWTConfig.KeyFunc to select the verification key.
+type Token struct {
+	Alg    string
+	Kid    string
+	Header map[string]any
+	Claims Claims
+
+	signingInput string
+	signature    []byte
+}
+
+type JWTConfig struct {
+	// Key verifies every token: []byte for HS256/384/512, *rsa.PublicKey
+	// for RS* and PS*, *ecdsa.PublicKey for ES* and ed25519.PublicKey for
+	// EdDSA. The matching private keys are accepted too.
+	Key any
+
+	// JWKS resolves keys by the token's "kid" header. Takes precedence over Key.
+	JWKS *JWKS
+
+	// KeyFunc resolves the key for a token. Takes precedence over JWKS and Key.
+	KeyFunc func(ctx context.Context, token *Token) (any, error)
+
+	// Algorithms lists the accepted "alg" values. Defaults to every
+	// supported algorithm; a token is still only accepted if its algorithm
+	// matches the type of its key.
+	Algorithms []string
+
+	// Issuer, if set, must equal the "iss" claim.
+	Issuer string
+
+	// Audience, if set, must contain one of the "aud" claim's values.
+	Audience []string
+
+	// Leeway allows for clock skew when checking "exp" and "nbf".
+	Leeway time.Duration
+
+	// KeyLookup is where the token is read from, as "<source>:<name>" with
+	// source one of header, query or cookie. Defaults to "header:Authorization".
+	KeyLookup string
+
+	// AuthScheme is the scheme that must prefix a header token.
+	// Defaults to "Bearer" when KeyLookup is the default.
+	AuthScheme string
+
+	// Skip bypasses authentication for requests it returns true for.
+	Skip func(c cenery.Ctx) bool
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// JWT returns a middleware that requires a valid JWT. The principal's
+// Subject is the "sub" claim and Claims holds every claim.
+func JWT(config JWTConfig) cenery.Handler {
+	if config.KeyFunc == nil {
+		switch {
+		case config.JWKS != nil:
+			jwks := config.JWKS
+			config.KeyFunc = func(ctx context.Context, t *Token) (any, error) {
+				return jwks.Key(ctx, t.Kid, t.Alg)
+			}
+		case config.Key != nil:
+			key := config.Key
+			config.KeyFunc = func(context.Context, *Token) (any, error) {
+				return key, nil
+			}
+		default:
+			panic("auth: JWT requires Key, JWKS or KeyFunc")
+		}
+	}
+	if config.KeyLookup == "" {
+		config.KeyLookup = "header:Authorization"
+		if config.AuthScheme == "" {
+			config.AuthScheme = "Bearer"
+		}
+	}
+	if config.Clock == nil {
+		config.Clock = time.Now
+	}
+	extract := newExtractor(config.KeyLookup, config.AuthScheme)
+
+	var challenge, invalidChallenge string
+	if config.AuthScheme != "" && strings.HasPrefix(config.KeyLookup, "header:") {
+		challenge = config.AuthScheme
+		invalidChallenge = challenge + ` error="invalid_token"`
+	}
+
+	return func(c cenery.Ctx) error {
+		if config.Skip != nil && config.Skip(c) {
+			return c.Next()
+		}
+
+		raw := extract(c)
+		if raw == "" {
+			return unauthorized(c, challenge, ErrUnauthorized)
+		}
+		token, err := verifyJWT(c.Context(), raw, &config)
+		if err != nil {
+			return unauthorized(c, invalidChallenge, err)
+		}
+
+		c.Locals(PrincipalKey, &Principal{Scheme: "jwt", Subject: token.Claims.Subject(), Claims: token.Claims})
+		return c.Next()
+	}
+}
+
+func verifyJWT(ctx context.Context, raw string, config *JWTConfig) (*Token, error) {
+	token, err := parseJWT(raw)
+	if err != nil {
+		return nil, err
+	}
+	if token.Alg == "" || token.Alg == "none" {
+		return nil, ErrTokenAlgorithm
+	}
+	if len(config.Algorithms) > 0 && !slices.Contains(config.Algorithms, token.Alg) {
+		return nil, ErrTokenAlgorithm
+	}
+
+	key, err := config.KeyFunc(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(token.Alg, key, token.signingInput, token.signature); err != nil {
+		return nil, err
+	}
+	if err := validateClaims(token.Claims, config); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func parseJWT(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	token := &Token{signingInput: parts[0] + "." + parts[1]}
+	if err := decodeSegment(parts[0], &token.Header); err != nil {
+		return nil, err
+	}
+	if err := decodeSegment(parts[1], &token.Claims); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+	token.signature = sig
+	token.Alg, _ = token.Header["alg"].(string)
+	token.Kid, _ = token.Header["kid"].(string)
+	return token, nil
+}
+
+func decodeSegment(seg string, out any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrTokenMalformed
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(out); err != nil {
+		return ErrTokenMalformed
+	}
+	return nil
+}
+
+func hashFor(alg string) (crypto.Hash, bool) {
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, true
+	case "384":
+		return crypto.SHA384, true
+	case "512":
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+// verifySignature checks sig over input. The key's type must match the
+// algorithm family, which rules out using a public key as an HMAC secret.
+func verifySignature(alg string, key any, input string, sig []byte) error {
+	if alg == "EdDSA" {
+		pub, ok := publicKey(key).(ed25519.PublicKey)
+		if !ok {
+			return ErrTokenAlgorithm
+		}
+		if !ed25519.Verify(pub, []byte(input), sig) {
+			return ErrTokenSignature
+		}
+		return nil
+	}
+
+	if len(alg) != 5 {
+		return ErrTokenAlgorithm
+	}
+	hash, ok := hashFor(alg)
+	if !ok {
+		return ErrTokenAlgorithm
+	}
+
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrTokenAlgorithm
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(input))
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return ErrTokenSignature
+		}
+		return nil
+	case "RS", "PS":
+		pub, ok := publicKey(key).(*rsa.PublicKey)
+		if !ok {
+			return ErrTokenAlgorithm
+		}
+		h := hash.New()
+		h.Write([]byte(input))
+		var err error
+		if alg[0] == 'R' {
+			err = rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), sig)
+		} else {
+			err = rsa.VerifyPSS(pub, hash, h.Sum(nil), sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return ErrTokenSignature
+		}
+		return nil
+	case "ES":
+		pub, ok := publicKey(key).(*ecdsa.PublicKey)
+		if !ok {
+			return ErrTokenAlgorithm
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if pub.Curve.Params().BitSize != curveBits(alg) {
+			return ErrTokenAlgorithm
+		}
+		if len(sig) != 2*size {
+			return ErrTokenSignature
+		}
+		h := hash.New()
+		h.Write([]byte(input))
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(pub, h.Sum(nil), r, s) {
+			return ErrTokenSignature
+		}
+		return nil
+	}
+	return ErrTokenAlgorithm
+}
+
+// curveBits returns the curve size an ES algorithm is defined for.
+func curveBits(alg string) int {
+	switch alg {
+	case "ES256":
+		return 256
+	case "ES384":
+		return 384
+	case "ES512":
+		return 521
+	}
+	return 0
+}
+
+// publicKey returns the public half of a private key, or key itself.
+func publicKey(key any) any {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &k.PublicKey
+	case *ecdsa.PrivateKey:
+		return &k.PublicKey
+	case ed25519.PrivateKey:
+		return k.Public()
+	}
+	return key
+}
+
+func validateClaims(claims Claims, config *JWTConfig) error {
+	now := config.Clock()
+
+	exp, ok, err := claims.numericDate("exp")
+	if err != nil {
+		return err
+	}
+	if ok && !now.Before(exp.Add(config.Leeway)) {
+		return ErrTokenExpired
+	}
+
+	nbf, ok, err := claims.numericDate("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(config.Leeway).Before(nbf) {
+		return ErrTokenNotYetValid
+	}
+
+	if config.Issuer != "" && claims.Issuer() != config.Issuer {
+		return fmt.Errorf("%w: %q", ErrTokenIssuer, claims.Issuer())
+	}
+
+	if len(config.Audience) > 0 {
+		aud := claims.Audience()
+		if !slices.ContainsFunc(config.Audience, func(a string) bool { return slices.Contains(aud, a) }) {
+			return ErrTokenAudience
+		}
+	}
+	return nil
+}
@@ -0,0 +1,340 @@
+// Package csrf protects state-changing requests against cross-site request
+// forgery.
+//
+// Two patterns are supported. By default the token is kept in a cookie and
+// must be echoed back in a header, form field or query parameter (double
+// submit cookie). With a Store and SessionKey the token is kept server-side
+// per session instead (synchronizer token). Either way, requests with an
+// unsafe method must also come from the same host or a trusted origin when
+// the browser sends Origin or Referer.
+//
+// Handlers read the token with Token, or TemplateField for html/template
+// forms. Rejected requests get a 403 *cenery.Error.
+package csrf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+var (
+	ErrTokenMissing   = errors.New("csrf: token missing")
+	ErrTokenInvalid   = errors.New("csrf: token invalid")
+	ErrOriginMismatch = errors.New("csrf: origin does not match")
+)
+
+const localsKey = "cenery.csrf"
+
+type Config struct {
+	// TokenLookup lists where the token is read from on unsafe requests, as
+	// comma-separated "<source>:<name>" pairs with source one of header,
+	// form or query. Defaults to "header:X-CSRF-Token,form:_csrf". Form
+	// fields are read with Ctx.FormValue, which parses the body within the
+	// request's Limits.
+	TokenLookup string
+
+	// CookieName is the cookie holding the token in double submit mode.
+	// Defaults to "_csrf".
+	CookieName     string
+	CookiePath     string // Defaults to "/".
+	CookieDomain   string
+	CookieSecure   bool
+	CookieHTTPOnly bool
+	// CookieSameSite defaults to http.SameSiteLaxMode.
+	CookieSameSite http.SameSite
+
+	// Expiration is the lifetime of a token. Defaults to 12 hours.
+	Expiration time.Duration
+
+	// Secret, if set, signs double submit tokens so a cookie planted by a
+	// sibling subdomain is rejected.
+	Secret []byte
+
+	// Store and SessionKey switch to the synchronizer token pattern: the
+	// token is kept in Store under the key SessionKey returns for the
+	// request. Requests without a session key get no token and their
+	// unsafe requests are rejected.
+	Store      Store
+	SessionKey func(c cenery.Ctx) string
+
+	// TrustedOrigins lists origins other than the request's own host that
+	// may send unsafe requests, such as "https://admin.example.com".
+	TrustedOrigins []string
+
+	// SafeMethods are exempt from checks. Defaults to GET, HEAD, OPTIONS
+	// and TRACE.
+	SafeMethods []string
+
+	// Skip bypasses the middleware for requests it returns true for.
+	Skip func(c cenery.Ctx) bool
+}
+
+type tokenInfo struct {
+	token string
+	field string
+}
+
+// Token returns the CSRF token of the request, to embed in a form or send
+// to a client that echoes it back. It is empty when the middleware did not
+// run or no session was found.
+func Token(c cenery.Ctx) string {
+	if info, ok := c.Locals(localsKey).(*tokenInfo); ok {
+		return info.token
+	}
+	return ""
+}
+
+// TemplateField returns a hidden form input carrying the token, named after
+// the first form source in TokenLookup.
+func TemplateField(c cenery.Ctx) template.HTML {
+	info, ok := c.Locals(localsKey).(*tokenInfo)
+	if !ok || info.token == "" {
+		return ""
+	}
+	return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(info.field) +
+		`" value="` + template.HTMLEscapeString(info.token) + `">`)
+}
+
+type extractor func(c cenery.Ctx) string
+
+// New returns a CSRF protection middleware.
+func New(config ...Config) cenery.Handler {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.TokenLookup == "" {
+		cfg.TokenLookup = "header:X-CSRF-Token,form:_csrf"
+	}
+	if cfg.CookieName == "" {
+		cfg.CookieName = "_csrf"
+	}
+	if cfg.CookiePath == "" {
+		cfg.CookiePath = "/"
+	}
+	if cfg.CookieSameSite == 0 {
+		cfg.CookieSameSite = http.SameSiteLaxMode
+	}
+	if cfg.Expiration <= 0 {
+		cfg.Expiration = 12 * time.Hour
+	}
+	if len(cfg.SafeMethods) == 0 {
+		cfg.SafeMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace}
+	}
+	if cfg.Store != nil && cfg.SessionKey == nil {
+		panic("csrf: Store requires SessionKey")
+	}
+
+	var field string
+	var extractors []extractor
+	for _, lookup := range strings.Split(cfg.TokenLookup, ",") {
+		source, name, ok := strings.Cut(strings.TrimSpace(lookup), ":")
+		if !ok || name == "" {
+			panic("csrf: invalid token lookup " + lookup)
+		}
+		switch source {
+		case "header":
+			extractors = append(extractors, func(c cenery.Ctx) string { return c.Request().GetHeader(name) })
+		case "form":
+			if field == "" {
+				field = name
+			}
+			extractors = append(extractors, func(c cenery.Ctx) string { return c.FormValue(name) })
+		case "query":
+			extractors = append(extractors, func(c cenery.Ctx) string { return c.QueryParam(name) })
+		default:
+			panic("csrf: invalid token lookup source " + source)
+		}
+	}
+
+	if field == "" {
+		field = "_csrf"
+	}
+
+	m := &middleware{cfg: cfg, field: field, extractors: extractors}
+	return m.handle
+}
+
+type middleware struct {
+	cfg        Config
+	field      string
+	extractors []extractor
+}
+
+func (m *middleware) handle(c cenery.Ctx) error {
+	if m.cfg.Skip != nil && m.cfg.Skip(c) {
+		return c.Next()
+	}
+
+	var token, expected string
+	var err error
+	if m.cfg.Store != nil {
+		token, expected, err = m.sessionToken(c)
+	} else {
+		token, expected = m.cookieToken(c)
+	}
+	if err != nil {
+		return err
+	}
+	c.Locals(localsKey, &tokenInfo{token: token, field: m.field})
+
+	if slices.Contains(m.cfg.SafeMethods, c.Request().Method()) {
+		return c.Next()
+	}
+
+	if err := m.checkOrigin(c); err != nil {
+		return forbidden(err)
+	}
+	sent := m.extract(c)
+	if sent == "" {
+		return forbidden(ErrTokenMissing)
+	}
+	if expected == "" || !secureCompare(sent, expected) {
+		return forbidden(ErrTokenInvalid)
+	}
+	return c.Next()
+}
+
+// cookieToken returns the token to expose and the one a request must match,
+// issuing a new cookie if the request has no valid one.
+func (m *middleware) cookieToken(c cenery.Ctx) (token, expected string) {
+	if val := readCookie(c, m.cfg.CookieName); val != "" && m.validSignature(val) {
+		return val, val
+	}
+
+	token = m.sign(newToken())
+	cookie := &http.Cookie{
+		Name:     m.cfg.CookieName,
+		Value:    token,
+		Path:     m.cfg.CookiePath,
+		Domain:   m.cfg.CookieDomain,
+		MaxAge:   int(m.cfg.Expiration / time.Second),
+		Secure:   m.cfg.CookieSecure,
+		HttpOnly: m.cfg.CookieHTTPOnly,
+		SameSite: m.cfg.CookieSameSite,
+	}
+	c.Response().AddHeader("Set-Cookie", cookie.String())
+	return token, ""
+}
+
+func (m *middleware) sessionToken(c cenery.Ctx) (token, expected string, err error) {
+	key := m.cfg.SessionKey(c)
+	if key == "" {
+		return "", "", nil
+	}
+	stored, err := m.cfg.Store.Get(c.Context(), key)
+	if err != nil {
+		return "", "", err
+	}
+	if stored != "" {
+		return stored, stored, nil
+	}
+	token = newToken()
+	if err := m.cfg.Store.Set(c.Context(), key, token, m.cfg.Expiration); err != nil {
+		return "", "", err
+	}
+	return token, "", nil
+}
+
+func (m *middleware) extract(c cenery.Ctx) string {
+	for _, extract := range m.extractors {
+		if val := extract(c); val != "" {
+			return val
+		}
+	}
+	return ""
+}
+
+// checkOrigin compares the Origin header, or the Referer when Origin is
+// absent, with the request host and TrustedOrigins. Requests carrying
+// neither rely on the token alone.
+func (m *middleware) checkOrigin(c cenery.Ctx) error {
+	req := c.Request()
+	origin := req.GetHeader("Origin")
+	if origin == "" {
+		ref := req.GetHeader("Referer")
+		if ref == "" {
+			return nil
+		}
+		u, err := url.Parse(ref)
+		if err != nil || u.Host == "" {
+			return ErrOriginMismatch
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	for _, trusted := range m.cfg.TrustedOrigins {
+		if strings.EqualFold(origin, trusted) {
+			return nil
+		}
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return ErrOriginMismatch
+	}
+	// Only the host is compared: behind a TLS-terminating proxy the request
+	// scheme does not reflect what the browser used.
+	if !strings.EqualFold(u.Host, req.Host()) {
+		return ErrOriginMismatch
+	}
+	return nil
+}
+
+func (m *middleware) sign(token string) string {
+	if len(m.cfg.Secret) == 0 {
+		return token
+	}
+	mac := hmac.New(sha256.New, m.cfg.Secret)
+	mac.Write([]byte(token))
+	return token + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (m *middleware) validSignature(val string) bool {
+	if len(m.cfg.Secret) == 0 {
+		return true
+	}
+	token, _, ok := strings.Cut(val, ".")
+	return ok && hmac.Equal([]byte(val), []byte(m.sign(token)))
+}
+
+func forbidden(err error) error {
+	return &cenery.Error{Code: http.StatusForbidden, Message: http.StatusText(http.StatusForbidden), Err: err}
+}
+
+func newToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// secureCompare reports whether a and b are equal in constant time.
+func secureCompare(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+func readCookie(c cenery.Ctx, name string) string {
+	header := c.Request().GetHeader("Cookie")
+	if header == "" {
+		return ""
+	}
+	r := http.Request{Header: http.Header{"Cookie": {header}}}
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
@@ -0,0 +1,278 @@
+package csrf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/dreamph/cenery"
+)
+
+type testRequest struct {
+	cenery.Request
+	method  string
+	headers http.Header
+	body    []byte
+}
+
+func (r *testRequest) Method() string              { return r.method }
+func (r *testRequest) Host() string                { return "example.com" }
+func (r *testRequest) GetHeader(key string) string { return r.headers.Get(key) }
+
+type testResponse struct {
+	cenery.Response
+	headers http.Header
+}
+
+func (r *testResponse) AddHeader(key string, val string) { r.headers.Add(key, val) }
+
+type testCtx struct {
+	cenery.Ctx
+	req    *testRequest
+	resp   *testResponse
+	query  map[string]string
+	locals map[string]any
+	next   bool
+}
+
+func newTestCtx(method string) *testCtx {
+	return &testCtx{
+		req:    &testRequest{method: method, headers: http.Header{}},
+		resp:   &testResponse{headers: http.Header{}},
+		query:  map[string]string{},
+		locals: map[string]any{},
+	}
+}
+
+func (c *testCtx) Context() context.Context  { return context.Background() }
+func (c *testCtx) Request() cenery.Request   { return c.req }
+func (c *testCtx) Response() cenery.Response { return c.resp }
+
+// FormValue parses the body with net/http, as the net/http engines do.
+func (c *testCtx) FormValue(key string, _ ...string) string {
+	r, _ := http.NewRequest(c.req.method, "/", bytes.NewReader(c.req.body))
+	r.Header = c.req.headers
+	return r.PostFormValue(key)
+}
+
+func (c *testCtx) QueryParam(key string, _ ...string) string { return c.query[key] }
+
+func (c *testCtx) Locals(key string, value ...any) any {
+	if len(value) > 0 {
+		c.locals[key] = value[0]
+	}
+	return c.locals[key]
+}
+
+func (c *testCtx) Next() error {
+	c.next = true
+	return nil
+}
+
+// issue runs a GET through handler and returns the token and cookie it set.
+func issue(t *testing.T, handler cenery.Handler) (string, string) {
+	t.Helper()
+	c := newTestCtx(http.MethodGet)
+	if err := handler(c); err != nil {
+		t.Fatalf("GET error = %v", err)
+	}
+	token := Token(c)
+	cookies, err := http.ParseSetCookie(c.resp.headers.Get("Set-Cookie"))
+	if err != nil || token == "" || cookies.Value != token {
+		t.Fatalf("GET token = %q, cookie = %q", token, c.resp.headers.Get("Set-Cookie"))
+	}
+	return token, cookies.Name + "=" + cookies.Value
+}
+
+func wantForbidden(t *testing.T, name string, err, cause error) {
+	t.Helper()
+	var e *cenery.Error
+	if !errors.As(err, &e) || e.Code != http.StatusForbidden || !errors.Is(err, cause) {
+		t.Errorf("%v: error = %v, want 403 %v", name, err, cause)
+	}
+}
+
+func TestDoubleSubmit(t *testing.T) {
+	handler := New(Config{Secret: []byte("secret")})
+	token, cookie := issue(t, handler)
+
+	c := newTestCtx(http.MethodPost)
+	c.req.headers.Set("Cookie", cookie)
+	c.req.headers.Set("X-CSRF-Token", token)
+	c.req.headers.Set("Origin", "https://example.com")
+	if err := handler(c); err != nil || !c.next {
+		t.Fatalf("POST with token error = %v", err)
+	}
+	if c.resp.headers.Get("Set-Cookie") != "" {
+		t.Errorf("valid cookie was reissued")
+	}
+
+	tests := []struct {
+		name  string
+		setup func(c *testCtx)
+		want  error
+	}{
+		{"no token", func(c *testCtx) { c.req.headers.Set("Cookie", cookie) }, ErrTokenMissing},
+		{"no cookie", func(c *testCtx) { c.req.headers.Set("X-CSRF-Token", token) }, ErrTokenInvalid},
+		{"wrong token", func(c *testCtx) {
+			c.req.headers.Set("Cookie", cookie)
+			c.req.headers.Set("X-CSRF-Token", token+"x")
+		}, ErrTokenInvalid},
+		{"unsigned cookie", func(c *testCtx) {
+			c.req.headers.Set("Cookie", "_csrf=forged")
+			c.req.headers.Set("X-CSRF-Token", "forged")
+		}, ErrTokenInvalid},
+		{"cross origin", func(c *testCtx) {
+			c.req.headers.Set("Cookie", cookie)
+			c.req.headers.Set("X-CSRF-Token", token)
+			c.req.headers.Set("Origin", "https://evil.example")
+		}, ErrOriginMismatch},
+		{"cross referer", func(c *testCtx) {
+			c.req.headers.Set("Cookie", cookie)
+			c.req.headers.Set("X-CSRF-Token", token)
+			c.req.headers.Set("Referer", "https://evil.example/form")
+		}, ErrOriginMismatch},
+	}
+	for _, tt := range tests {
+		c := newTestCtx(http.MethodPost)
+		tt.setup(c)
+		wantForbidden(t, tt.name, handler(c), tt.want)
+		if c.next {
+			t.Errorf("%v: reached next handler", tt.name)
+		}
+	}
+}
+
+func TestTokenSources(t *testing.T) {
+	handler := New(Config{
+		TokenLookup:    "header:X-CSRF-Token,form:csrf_token,query:csrf",
+		TrustedOrigins: []string{"https://admin.example.org"},
+	})
+	token, cookie := issue(t, handler)
+
+	form := &bytes.Buffer{}
+	writer := multipart.NewWriter(form)
+	_ = writer.WriteField("csrf_token", token)
+	writer.Close()
+
+	tests := []struct {
+		name  string
+		setup func(c *testCtx)
+	}{
+		{"urlencoded", func(c *testCtx) {
+			c.req.headers.Set("Content-Type", "application/x-www-form-urlencoded")
+			c.req.body = []byte("name=x&csrf_token=" + token)
+		}},
+		{"multipart", func(c *testCtx) {
+			c.req.headers.Set("Content-Type", writer.FormDataContentType())
+			c.req.headers.Set("Content-Length", strconv.Itoa(form.Len()))
+			c.req.body = form.Bytes()
+		}},
+		{"query", func(c *testCtx) { c.query["csrf"] = token }},
+		{"trusted origin", func(c *testCtx) {
+			c.req.headers.Set("Origin", "https://admin.example.org")
+			c.req.headers.Set("X-CSRF-Token", token)
+		}},
+	}
+	for _, tt := range tests {
+		c := newTestCtx(http.MethodPost)
+		c.req.headers.Set("Cookie", cookie)
+		tt.setup(c)
+		if err := handler(c); err != nil || !c.next {
+			t.Errorf("%v: error = %v", tt.name, err)
+		}
+	}
+
+	c := newTestCtx(http.MethodGet)
+	c.req.headers.Set("Cookie", cookie)
+	_ = handler(c)
+	want := `<input type="hidden" name="csrf_token" value="` + token + `">`
+	if got := string(TemplateField(c)); got != want {
+		t.Errorf("TemplateField() = %v, want %v", got, want)
+	}
+}
+
+func TestFormTokenBody(t *testing.T) {
+	handler := New()
+	token, cookie := issue(t, handler)
+
+	multipartForm := func(fields ...string) (string, []byte) {
+		form := &bytes.Buffer{}
+		writer := multipart.NewWriter(form)
+		for _, field := range fields {
+			if field == "file" {
+				part, _ := writer.CreateFormFile("file", "big.bin")
+				_, _ = part.Write(bytes.Repeat([]byte("x"), 1<<10))
+				continue
+			}
+			_ = writer.WriteField(field, token)
+		}
+		writer.Close()
+		return writer.FormDataContentType(), form.Bytes()
+	}
+	tokenFirst, tokenFirstBody := multipartForm("_csrf", "file")
+	fileFirst, fileFirstBody := multipartForm("file", "_csrf")
+	large, largeBody := multipartForm("file", "file", "_csrf")
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		allowed     bool
+	}{
+		{"urlencoded", "application/x-www-form-urlencoded", []byte("name=x&_csrf=" + token), true},
+		{"token first", tokenFirst, tokenFirstBody, true},
+		{"file first", fileFirst, fileFirstBody, true},
+		{"large", large, largeBody, true},
+		{"missing", fileFirst, fileFirstBody[:len(fileFirstBody)/2], false},
+	}
+	for _, tt := range tests {
+		c := newTestCtx(http.MethodPost)
+		c.req.headers.Set("Cookie", cookie)
+		c.req.headers.Set("Content-Type", tt.contentType)
+		c.req.headers.Set("Content-Length", strconv.Itoa(len(tt.body)))
+		c.req.body = tt.body
+		err := handler(c)
+		if tt.allowed && (err != nil || !c.next) {
+			t.Errorf("%v: error = %v", tt.name, err)
+		}
+		if !tt.allowed {
+			wantForbidden(t, tt.name, err, ErrTokenMissing)
+		}
+	}
+}
+
+func TestSynchronizerToken(t *testing.T) {
+	handler := New(Config{
+		Store: NewMemoryStore(),
+		SessionKey: func(c cenery.Ctx) string {
+			return c.Request().GetHeader("X-Session")
+		},
+	})
+
+	c := newTestCtx(http.MethodGet)
+	c.req.headers.Set("X-Session", "s1")
+	_ = handler(c)
+	token := Token(c)
+	if token == "" || c.resp.headers.Get("Set-Cookie") != "" {
+		t.Fatalf("GET token = %q, Set-Cookie = %q", token, c.resp.headers.Get("Set-Cookie"))
+	}
+
+	c = newTestCtx(http.MethodPut)
+	c.req.headers.Set("X-Session", "s1")
+	c.req.headers.Set("X-CSRF-Token", token)
+	if err := handler(c); err != nil || !c.next {
+		t.Fatalf("PUT with token error = %v", err)
+	}
+
+	for _, session := range []string{"s2", ""} {
+		c = newTestCtx(http.MethodPut)
+		c.req.headers.Set("X-Session", session)
+		c.req.headers.Set("X-CSRF-Token", token)
+		wantForbidden(t, "session "+session, handler(c), ErrTokenInvalid)
+	}
+}
@@ -0,0 +1,58 @@
+package csrf
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps synchronizer tokens server-side, keyed by session. Get
+// returns "" when no token is stored or it has expired.
+type Store interface {
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, token string, ttl time.Duration) error
+}
+
+type memoryEntry struct {
+	token   string
+	expires time.Time
+}
+
+// MemoryStore is an in-process Store. Expired tokens are swept lazily.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	ops     int
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]memoryEntry)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[key]
+	if !ok || !time.Now().Before(e.expires) {
+		return "", nil
+	}
+	return e.token, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key, token string, ttl time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ops++
+	if s.ops >= 1024 {
+		s.ops = 0
+		for k, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	s.entries[key] = memoryEntry{token: token, expires: now.Add(ttl)}
+	return nil
+}
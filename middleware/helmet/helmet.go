@@ -0,0 +1,151 @@
+// Package helmet sets security-related response headers.
+//
+// Every header has a default suitable for most sites. Set a field to Omit to
+// leave its header out. Content-Security-Policy may contain the placeholder
+// {nonce}, which is replaced by a fresh random value on every request;
+// handlers read it with Nonce to mark inline scripts and styles.
+package helmet
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/dreamph/cenery"
+)
+
+// Omit leaves a header out when used as a Config value.
+const Omit = "-"
+
+// NonceKey is the Ctx locals key the CSP nonce is stored under.
+const NonceKey = "cenery.helmet.nonce"
+
+const nonceTag = "{nonce}"
+
+const defaultCSP = "default-src 'self'; base-uri 'self'; font-src 'self' https: data:; " +
+	"form-action 'self'; frame-ancestors 'self'; img-src 'self' data:; object-src 'none'; " +
+	"script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; upgrade-insecure-requests"
+
+type Config struct {
+	// HSTSMaxAge is the Strict-Transport-Security max-age in seconds.
+	// Defaults to one year; a negative value leaves the header out.
+	HSTSMaxAge int
+	// HSTSExcludeSubdomains drops includeSubDomains from the HSTS header.
+	HSTSExcludeSubdomains bool
+	// HSTSPreload adds the preload directive.
+	HSTSPreload bool
+
+	// ContentSecurityPolicy defaults to a same-origin policy that allows
+	// inline scripts and styles carrying the request nonce.
+	ContentSecurityPolicy string
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only.
+	CSPReportOnly bool
+
+	// ContentTypeOptions defaults to "nosniff".
+	ContentTypeOptions string
+	// FrameOptions is the X-Frame-Options value. Defaults to "SAMEORIGIN".
+	FrameOptions string
+	// ReferrerPolicy defaults to "no-referrer".
+	ReferrerPolicy string
+	// CrossOriginOpenerPolicy defaults to "same-origin".
+	CrossOriginOpenerPolicy string
+	// CrossOriginResourcePolicy defaults to "same-origin".
+	CrossOriginResourcePolicy string
+	// CrossOriginEmbedderPolicy is left out unless set, as "require-corp"
+	// breaks pages embedding cross-origin resources without CORP headers.
+	CrossOriginEmbedderPolicy string
+	// PermissionsPolicy defaults to disabling camera, microphone,
+	// geolocation and payment.
+	PermissionsPolicy string
+
+	// Skip bypasses the middleware for requests it returns true for.
+	Skip func(c cenery.Ctx) bool
+}
+
+// Nonce returns the CSP nonce of the request, or "" when the policy has none.
+func Nonce(c cenery.Ctx) string {
+	nonce, _ := c.Locals(NonceKey).(string)
+	return nonce
+}
+
+type header struct {
+	name  string
+	value string
+}
+
+// New returns a middleware that sets security headers on every response.
+func New(config ...Config) cenery.Handler {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+
+	var headers []header
+	add := func(name, value, def string) {
+		if value == "" {
+			value = def
+		}
+		if value != "" && value != Omit {
+			headers = append(headers, header{name, value})
+		}
+	}
+
+	if cfg.HSTSMaxAge == 0 {
+		cfg.HSTSMaxAge = 365 * 24 * 60 * 60
+	}
+	if cfg.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.Itoa(cfg.HSTSMaxAge)
+		if !cfg.HSTSExcludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+		add("Strict-Transport-Security", hsts, "")
+	}
+	add("X-Content-Type-Options", cfg.ContentTypeOptions, "nosniff")
+	add("X-Frame-Options", cfg.FrameOptions, "SAMEORIGIN")
+	add("Referrer-Policy", cfg.ReferrerPolicy, "no-referrer")
+	add("Cross-Origin-Opener-Policy", cfg.CrossOriginOpenerPolicy, "same-origin")
+	add("Cross-Origin-Resource-Policy", cfg.CrossOriginResourcePolicy, "same-origin")
+	add("Cross-Origin-Embedder-Policy", cfg.CrossOriginEmbedderPolicy, "")
+	add("Permissions-Policy", cfg.PermissionsPolicy, "camera=(), microphone=(), geolocation=(), payment=()")
+
+	csp := cfg.ContentSecurityPolicy
+	if csp == "" {
+		csp = defaultCSP
+	}
+	cspHeader := "Content-Security-Policy"
+	if cfg.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(csp, nonceTag)
+
+	return func(c cenery.Ctx) error {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return c.Next()
+		}
+
+		resp := c.Response()
+		for _, h := range headers {
+			resp.SetHeader(h.name, h.value)
+		}
+		if csp != Omit {
+			policy := csp
+			if useNonce {
+				nonce := newNonce()
+				c.Locals(NonceKey, nonce)
+				policy = strings.ReplaceAll(csp, nonceTag, nonce)
+			}
+			resp.SetHeader(cspHeader, policy)
+		}
+		return c.Next()
+	}
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,108 @@
+package helmet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dreamph/cenery"
+)
+
+type testHeaders map[string]string
+
+type testResponse struct {
+	cenery.Response
+	headers testHeaders
+}
+
+func (r *testResponse) SetHeader(key string, val string) { r.headers[key] = val }
+
+type testCtx struct {
+	cenery.Ctx
+	resp   *testResponse
+	locals map[string]any
+	next   bool
+}
+
+func newTestCtx() *testCtx {
+	return &testCtx{resp: &testResponse{headers: testHeaders{}}, locals: map[string]any{}}
+}
+
+func (c *testCtx) Response() cenery.Response { return c.resp }
+
+func (c *testCtx) Locals(key string, value ...any) any {
+	if len(value) > 0 {
+		c.locals[key] = value[0]
+	}
+	return c.locals[key]
+}
+
+func (c *testCtx) Next() error {
+	c.next = true
+	return nil
+}
+
+func TestDefaults(t *testing.T) {
+	c := newTestCtx()
+	if err := New()(c); err != nil || !c.next {
+		t.Fatalf("handler() error = %v", err)
+	}
+
+	want := map[string]string{
+		"Strict-Transport-Security":    "max-age=31536000; includeSubDomains",
+		"X-Content-Type-Options":       "nosniff",
+		"X-Frame-Options":              "SAMEORIGIN",
+		"Referrer-Policy":              "no-referrer",
+		"Cross-Origin-Opener-Policy":   "same-origin",
+		"Cross-Origin-Resource-Policy": "same-origin",
+		"Permissions-Policy":           "camera=(), microphone=(), geolocation=(), payment=()",
+	}
+	for name, val := range want {
+		if got := c.resp.headers[name]; got != val {
+			t.Errorf("%v = %v, want %v", name, got, val)
+		}
+	}
+	if _, ok := c.resp.headers["Cross-Origin-Embedder-Policy"]; ok {
+		t.Errorf("Cross-Origin-Embedder-Policy set by default")
+	}
+
+	nonce := Nonce(c)
+	if nonce == "" {
+		t.Fatalf("Nonce() is empty")
+	}
+	if csp := c.resp.headers["Content-Security-Policy"]; !strings.Contains(csp, "'nonce-"+nonce+"'") {
+		t.Errorf("Content-Security-Policy = %v, want nonce %v", csp, nonce)
+	}
+
+	other := newTestCtx()
+	_ = New()(other)
+	if Nonce(other) == nonce {
+		t.Errorf("nonce reused across requests")
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c := newTestCtx()
+	_ = New(Config{
+		HSTSMaxAge:            -1,
+		ContentSecurityPolicy: "default-src 'none'",
+		CSPReportOnly:         true,
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        Omit,
+	})(c)
+
+	if _, ok := c.resp.headers["Strict-Transport-Security"]; ok {
+		t.Errorf("Strict-Transport-Security set with negative max-age")
+	}
+	if _, ok := c.resp.headers["Referrer-Policy"]; ok {
+		t.Errorf("Referrer-Policy set when omitted")
+	}
+	if got := c.resp.headers["X-Frame-Options"]; got != "DENY" {
+		t.Errorf("X-Frame-Options = %v, want %v", got, "DENY")
+	}
+	if got := c.resp.headers["Content-Security-Policy-Report-Only"]; got != "default-src 'none'" {
+		t.Errorf("Content-Security-Policy-Report-Only = %v", got)
+	}
+	if Nonce(c) != "" {
+		t.Errorf("Nonce() = %v for a policy without a nonce", Nonce(c))
+	}
+}
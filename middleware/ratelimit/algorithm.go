@@ -0,0 +1,189 @@
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+// State is the per-key bookkeeping an Algorithm works on. Stores persist it
+// between requests; its meaning depends on the algorithm that produced it.
+type State struct {
+	// Tokens left in the bucket (TokenBucket).
+	Tokens float64 `json:"tokens,omitempty"`
+	// Last is the Unix nanosecond timestamp of the last refill (TokenBucket)
+	// or the start of the current window (SlidingWindow).
+	Last int64 `json:"last,omitempty"`
+	// Count is the number of hits in the current window (SlidingWindow).
+	Count int64 `json:"count,omitempty"`
+	// PrevCount is the number of hits in the previous window (SlidingWindow).
+	PrevCount int64 `json:"prevCount,omitempty"`
+}
+
+// Result is the outcome of a single Take.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the limit is fully restored.
+	Reset time.Duration
+	// RetryAfter is the time until the next request would be allowed.
+	// It is zero when Allowed is true.
+	RetryAfter time.Duration
+}
+
+// Algorithm decides whether a hit is allowed, updating state in place.
+// Implementations must be pure so stores can run them under their own locking.
+type Algorithm interface {
+	Take(state *State, now time.Time) Result
+	// TTL is how long an untouched state must be kept before it is
+	// equivalent to a fresh one.
+	TTL() time.Duration
+	// Policy describes the limit for the RateLimit-Policy header.
+	Policy() (limit int, window time.Duration)
+}
+
+// TokenBucket refills Limit tokens every Period, holding at most Burst tokens.
+// Each request consumes one token.
+type TokenBucket struct {
+	Limit  int
+	Period time.Duration
+	// Burst is the bucket capacity. Defaults to Limit.
+	Burst int
+}
+
+func (b TokenBucket) validate() error {
+	if b.Limit <= 0 || b.Period <= 0 || b.Burst < 0 {
+		return errors.New("ratelimit: TokenBucket needs a positive Limit and Period")
+	}
+	return nil
+}
+
+func (b TokenBucket) capacity() float64 {
+	if b.Burst > 0 {
+		return float64(b.Burst)
+	}
+	return float64(b.Limit)
+}
+
+// rate returns tokens per nanosecond.
+func (b TokenBucket) rate() float64 {
+	return float64(b.Limit) / float64(b.Period)
+}
+
+func (b TokenBucket) Take(state *State, now time.Time) Result {
+	capacity := b.capacity()
+	rate := b.rate()
+	nowNano := now.UnixNano()
+
+	tokens := capacity
+	if state.Last != 0 {
+		elapsed := float64(nowNano - state.Last)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		tokens = math.Min(capacity, state.Tokens+elapsed*rate)
+	}
+
+	res := Result{Limit: int(capacity)}
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = time.Duration(math.Ceil((1 - tokens) / rate))
+	}
+	state.Tokens = tokens
+	state.Last = nowNano
+
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = time.Duration(math.Ceil((capacity - tokens) / rate))
+	return res
+}
+
+func (b TokenBucket) TTL() time.Duration {
+	return time.Duration(math.Ceil(b.capacity() / b.rate()))
+}
+
+func (b TokenBucket) Policy() (int, time.Duration) {
+	return int(b.capacity()), b.Period
+}
+
+// SlidingWindow allows Limit requests in any Window, approximated by
+// weighting the previous fixed window's count by its overlap with the
+// sliding one.
+type SlidingWindow struct {
+	Limit  int
+	Window time.Duration
+}
+
+func (w SlidingWindow) validate() error {
+	if w.Limit <= 0 || w.Window <= 0 {
+		return errors.New("ratelimit: SlidingWindow needs a positive Limit and Window")
+	}
+	return nil
+}
+
+func (w SlidingWindow) Take(state *State, now time.Time) Result {
+	window := int64(w.Window)
+	nowNano := now.UnixNano()
+	start := nowNano - nowNano%window
+
+	switch {
+	case state.Last == start:
+	case state.Last == start-window:
+		state.PrevCount, state.Count = state.Count, 0
+		state.Last = start
+	default:
+		state.PrevCount, state.Count = 0, 0
+		state.Last = start
+	}
+
+	elapsed := float64(nowNano-start) / float64(window)
+	estimate := float64(state.PrevCount)*(1-elapsed) + float64(state.Count)
+	limit := float64(w.Limit)
+
+	res := Result{Limit: w.Limit, Reset: time.Duration(start + window - nowNano)}
+	if estimate+1 <= limit {
+		state.Count++
+		estimate++
+		res.Allowed = true
+	} else {
+		res.RetryAfter = w.retryAfter(state, nowNano, start)
+	}
+	res.Remaining = int(math.Max(0, math.Floor(limit-estimate)))
+	if state.Count > 0 {
+		// The current window's hits only age out once the next window ends.
+		res.Reset += w.Window
+	}
+	return res
+}
+
+// retryAfter solves for the earliest time the weighted estimate leaves room
+// for one more request.
+func (w SlidingWindow) retryAfter(state *State, now, start int64) time.Duration {
+	window := float64(w.Window)
+	budget := float64(w.Limit - 1)
+	count := float64(state.Count)
+
+	if prev := float64(state.PrevCount); prev > 0 && count <= budget {
+		// Within this window: prev*(1-f) + count <= budget.
+		f := 1 - (budget-count)/prev
+		at := float64(start) + f*window
+		return time.Duration(math.Ceil(at - float64(now)))
+	}
+	if count == 0 {
+		return time.Duration(start + int64(w.Window) - now)
+	}
+	// In the next window the current count becomes the previous one.
+	f := math.Max(0, 1-budget/count)
+	at := float64(start) + window + f*window
+	return time.Duration(math.Ceil(at - float64(now)))
+}
+
+func (w SlidingWindow) TTL() time.Duration {
+	return 2 * w.Window
+}
+
+func (w SlidingWindow) Policy() (int, time.Duration) {
+	return w.Limit, w.Window
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+// KeyFunc extracts the client identity a limit is applied to.
+type KeyFunc = func(c cenery.Ctx) string
+
+type Config struct {
+	// Algorithm decides whether a request is allowed.
+	// Defaults to a TokenBucket of 100 requests per minute.
+	Algorithm Algorithm
+
+	// KeyFunc identifies the client. Defaults to KeyByIP().
+	KeyFunc KeyFunc
+
+	// Prefix namespaces keys so several limiters can share one Store,
+	// e.g. one per route.
+	Prefix string
+
+	// Store holds per-key state. Defaults to a new MemoryStore.
+	Store Store
+
+	// Skip bypasses the limiter for requests it returns true for.
+	Skip func(c cenery.Ctx) bool
+
+	// LimitReached writes the response for rejected requests.
+	// Defaults to a plain 429 Too Many Requests.
+	LimitReached cenery.Handler
+
+	// DisableHeaders stops RateLimit-* headers from being set.
+	// Retry-After is still sent on rejected requests.
+	DisableHeaders bool
+
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// KeyByIP keys requests by the remote address of the connection.
+func KeyByIP() KeyFunc {
+	return func(c cenery.Ctx) string {
+		return c.IP()
+	}
+}
+
+// KeyByHeader keys requests by a request header, such as an API key or a
+// proxy-supplied client address. Requests without the header fall back to
+// the remote address. The two kinds of key are prefixed differently, so a
+// header value equal to another client's address does not share its limit.
+func KeyByHeader(name string) KeyFunc {
+	return func(c cenery.Ctx) string {
+		if val := c.Request().GetHeader(name); val != "" {
+			return "header:" + val
+		}
+		return "ip:" + c.IP()
+	}
+}
+
+// New returns a middleware that rejects requests exceeding the configured limit.
+// It panics when a TokenBucket or SlidingWindow has a Limit or period that is
+// not positive.
+func New(config ...Config) cenery.Handler {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Algorithm == nil {
+		cfg.Algorithm = TokenBucket{Limit: 100, Period: time.Minute}
+	}
+	if v, ok := cfg.Algorithm.(interface{ validate() error }); ok {
+		if err := v.validate(); err != nil {
+			panic(err.Error())
+		}
+	}
+	if cfg.KeyFunc == nil {
+		cfg.KeyFunc = KeyByIP()
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.LimitReached == nil {
+		cfg.LimitReached = func(c cenery.Ctx) error {
+			return c.SendString(http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+		}
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+
+	limit, window := cfg.Algorithm.Policy()
+	policy := strconv.Itoa(limit) + ";w=" + strconv.Itoa(ceilSeconds(window))
+
+	return func(c cenery.Ctx) error {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return c.Next()
+		}
+
+		key := cfg.Prefix + cfg.KeyFunc(c)
+		res, err := cfg.Store.Take(c.Context(), key, cfg.Algorithm, cfg.Clock())
+		if err != nil {
+			return err
+		}
+
+		resp := c.Response()
+		if !cfg.DisableHeaders {
+			resp.SetHeader("RateLimit-Policy", policy)
+			resp.SetHeader("RateLimit-Limit", strconv.Itoa(res.Limit))
+			resp.SetHeader("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			resp.SetHeader("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		}
+		if !res.Allowed {
+			resp.SetHeader("Retry-After", strconv.Itoa(max(1, ceilSeconds(res.RetryAfter))))
+			return cfg.LimitReached(c)
+		}
+		return c.Next()
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
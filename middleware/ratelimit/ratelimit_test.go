@@ -0,0 +1,237 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+type testHeaders map[string]string
+
+type testRequest struct {
+	cenery.Request
+	headers testHeaders
+}
+
+func (r *testRequest) GetHeader(key string) string { return r.headers[key] }
+
+type testResponse struct {
+	cenery.Response
+	headers testHeaders
+}
+
+func (r *testResponse) GetHeader(key string) string      { return r.headers[key] }
+func (r *testResponse) SetHeader(key string, val string) { r.headers[key] = val }
+
+type testCtx struct {
+	cenery.Ctx
+	ip     string
+	req    *testRequest
+	resp   *testResponse
+	status int
+	next   bool
+}
+
+func newTestCtx(ip string) *testCtx {
+	return &testCtx{
+		ip:   ip,
+		req:  &testRequest{headers: testHeaders{}},
+		resp: &testResponse{headers: testHeaders{}},
+	}
+}
+
+func (c *testCtx) IP() string                { return c.ip }
+func (c *testCtx) Context() context.Context  { return context.Background() }
+func (c *testCtx) Request() cenery.Request   { return c.req }
+func (c *testCtx) Response() cenery.Response { return c.resp }
+
+func (c *testCtx) SendString(status int, _ string) error {
+	c.status = status
+	return nil
+}
+
+func (c *testCtx) Next() error {
+	c.next = true
+	return nil
+}
+
+func TestTokenBucket(t *testing.T) {
+	alg := TokenBucket{Limit: 2, Period: time.Second}
+	now := time.Unix(1000, 0)
+	var state State
+
+	for i := 0; i < 2; i++ {
+		if res := alg.Take(&state, now); !res.Allowed {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	res := alg.Take(&state, now)
+	if res.Allowed {
+		t.Fatalf("third request allowed")
+	}
+	if res.RetryAfter != 500*time.Millisecond {
+		t.Errorf("RetryAfter = %v, want %v", res.RetryAfter, 500*time.Millisecond)
+	}
+
+	if res := alg.Take(&state, now.Add(500*time.Millisecond)); !res.Allowed {
+		t.Errorf("request after refill rejected")
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	alg := SlidingWindow{Limit: 4, Window: time.Minute}
+	start := time.Unix(600, 0)
+	var state State
+
+	for i := 0; i < 4; i++ {
+		if res := alg.Take(&state, start); !res.Allowed {
+			t.Fatalf("request %d rejected", i)
+		}
+	}
+	if res := alg.Take(&state, start.Add(30*time.Second)); res.Allowed {
+		t.Fatalf("fifth request in window allowed")
+	}
+
+	// Halfway through the next window the previous four count as two.
+	mid := start.Add(90 * time.Second)
+	for i := 0; i < 2; i++ {
+		if res := alg.Take(&state, mid); !res.Allowed {
+			t.Fatalf("request %d in next window rejected", i)
+		}
+	}
+	res := alg.Take(&state, mid)
+	if res.Allowed {
+		t.Fatalf("request over weighted limit allowed")
+	}
+	if res.RetryAfter != 15*time.Second {
+		t.Errorf("RetryAfter = %v, want %v", res.RetryAfter, 15*time.Second)
+	}
+}
+
+func TestMiddlewareHeaders(t *testing.T) {
+	now := time.Unix(1000, 0)
+	handler := New(Config{
+		Algorithm: TokenBucket{Limit: 1, Period: 10 * time.Second},
+		Clock:     func() time.Time { return now },
+	})
+
+	c := newTestCtx("10.0.0.1")
+	if err := handler(c); err != nil {
+		t.Fatalf("handler() error = %v", err)
+	}
+	if !c.next {
+		t.Fatalf("first request did not reach next handler")
+	}
+	if got := c.resp.headers["RateLimit-Remaining"]; got != "0" {
+		t.Errorf("RateLimit-Remaining = %v, want %v", got, "0")
+	}
+	if got := c.resp.headers["RateLimit-Policy"]; got != "1;w=10" {
+		t.Errorf("RateLimit-Policy = %v, want %v", got, "1;w=10")
+	}
+
+	c = newTestCtx("10.0.0.1")
+	_ = handler(c)
+	if c.next {
+		t.Fatalf("second request reached next handler")
+	}
+	if c.status != 429 {
+		t.Errorf("status = %v, want %v", c.status, 429)
+	}
+	if got := c.resp.headers["Retry-After"]; got != "10" {
+		t.Errorf("Retry-After = %v, want %v", got, "10")
+	}
+
+	c = newTestCtx("10.0.0.2")
+	_ = handler(c)
+	if !c.next {
+		t.Errorf("other client was limited")
+	}
+}
+
+func TestKeyByHeader(t *testing.T) {
+	handler := New(Config{
+		Algorithm: SlidingWindow{Limit: 1, Window: time.Minute},
+		KeyFunc:   KeyByHeader("X-API-Key"),
+	})
+
+	for _, key := range []string{"a", "b"} {
+		c := newTestCtx("10.0.0.1")
+		c.req.headers["X-API-Key"] = key
+		_ = handler(c)
+		if !c.next {
+			t.Errorf("key %v was limited", key)
+		}
+	}
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	store := NewMemoryStore(4)
+	alg := TokenBucket{Limit: 100, Period: time.Hour}
+	now := time.Now()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				res, _ := store.Take(t.Context(), "key", alg, now)
+				if res.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 100 {
+		t.Errorf("allowed = %v, want %v", allowed, 100)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Len() = %v, want %v", store.Len(), 1)
+	}
+}
+
+func TestKeyByHeaderSpoofedIP(t *testing.T) {
+	handler := New(Config{
+		Algorithm: SlidingWindow{Limit: 1, Window: time.Minute},
+		KeyFunc:   KeyByHeader("X-API-Key"),
+	})
+
+	c := newTestCtx("10.0.0.1")
+	c.req.headers["X-API-Key"] = "10.0.0.2"
+	_ = handler(c)
+
+	c = newTestCtx("10.0.0.2")
+	_ = handler(c)
+	if !c.next {
+		t.Errorf("header value drained the bucket of the address it names")
+	}
+}
+
+func TestInvalidAlgorithm(t *testing.T) {
+	for _, alg := range []Algorithm{
+		TokenBucket{Limit: 0, Period: time.Minute},
+		TokenBucket{Limit: 10},
+		SlidingWindow{Limit: 10},
+		&SlidingWindow{Window: time.Minute},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("New(%+v) did not panic", alg)
+				}
+			}()
+			New(Config{Algorithm: alg})
+		}()
+	}
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// Store keeps rate limit state per key. Take must apply alg to the key's
+// state atomically; a Redis-like backend would typically load the State,
+// run alg.Take and write it back with alg.TTL() inside a transaction or
+// script.
+type Store interface {
+	Take(ctx context.Context, key string, alg Algorithm, now time.Time) (Result, error)
+}
+
+const (
+	defaultShards = 64
+	sweepEvery    = 1024
+)
+
+type memoryEntry struct {
+	state   State
+	expires int64
+}
+
+type memoryShard struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	ops     int
+}
+
+// MemoryStore is an in-process Store split into independently locked shards
+// to keep contention low. Expired keys are swept lazily.
+type MemoryStore struct {
+	shards []*memoryShard
+}
+
+// NewMemoryStore creates a MemoryStore with the given number of shards.
+// A non-positive value uses the default of 64.
+func NewMemoryStore(shards ...int) *MemoryStore {
+	n := defaultShards
+	if len(shards) == 1 && shards[0] > 0 {
+		n = shards[0]
+	}
+	s := &MemoryStore{shards: make([]*memoryShard, n)}
+	for i := range s.shards {
+		s.shards[i] = &memoryShard{entries: make(map[string]*memoryEntry)}
+	}
+	return s
+}
+
+func (s *MemoryStore) shard(key string) *memoryShard {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return s.shards[h.Sum32()%uint32(len(s.shards))]
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, alg Algorithm, now time.Time) (Result, error) {
+	sh := s.shard(key)
+	nowNano := now.UnixNano()
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+
+	sh.ops++
+	if sh.ops >= sweepEvery {
+		sh.ops = 0
+		for k, e := range sh.entries {
+			if e.expires <= nowNano {
+				delete(sh.entries, k)
+			}
+		}
+	}
+
+	entry, ok := sh.entries[key]
+	if !ok || entry.expires <= nowNano {
+		entry = &memoryEntry{}
+		sh.entries[key] = entry
+	}
+	res := alg.Take(&entry.state, now)
+	entry.expires = nowNano + int64(alg.TTL())
+	return res, nil
+}
+
+// Len returns the number of keys currently held, including expired keys
+// that have not been swept yet.
+func (s *MemoryStore) Len() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.mu.Lock()
+		n += len(sh.entries)
+		sh.mu.Unlock()
+	}
+	return n
+}
@@ -0,0 +1,91 @@
+// Package timeout bounds how long the rest of the handler chain may run.
+//
+// The request context is wrapped with a deadline. When it expires, the
+// middleware returns a *cenery.Error (503 by default) so the app's error
+// handler writes the response, and writes the handler makes afterwards are
+// discarded. How that is achieved depends on the engine:
+//
+//   - chi, gin, echo: the chain runs on its own goroutine writing into a
+//     buffer. At the deadline the error response is sent with an explicit
+//     Content-Length; the request is held until the chain returns because
+//     these engines pool their contexts.
+//   - fasthttp: the chain runs on its own goroutine and the error response
+//     is handed to fasthttp's TimeoutErrorWithResponse, which releases the
+//     connection at once and ignores the chain's later writes. Middleware
+//     placed before the timeout must not use the request after Next returns
+//     the timeout error.
+//   - fiber: the chain runs on the calling goroutine, so handlers must
+//     honour c.Context(). If it has expired when the chain returns, its
+//     output is dropped in favour of the error response.
+//
+// Responses are buffered on chi, gin and echo, so streaming handlers should
+// not run behind this middleware there, and WebSocket routes cannot: the
+// buffer cannot hand over the connection, so the handshake fails with a 500
+// *cenery.Error.
+package timeout
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+type Config struct {
+	// Timeout is the time the rest of the chain may take. Defaults to 30s.
+	Timeout time.Duration
+
+	// StatusCode is sent when the deadline passes, usually 503 or 504.
+	// Defaults to 503 Service Unavailable.
+	StatusCode int
+
+	// Message is the error message. Defaults to the status text.
+	Message string
+
+	// Skip bypasses the timeout for requests it returns true for.
+	Skip func(c cenery.Ctx) bool
+}
+
+// New returns a middleware that fails requests running longer than the
+// configured timeout.
+func New(config ...Config) cenery.Handler {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.StatusCode == 0 {
+		cfg.StatusCode = http.StatusServiceUnavailable
+	}
+	if cfg.Message == "" {
+		cfg.Message = http.StatusText(cfg.StatusCode)
+	}
+
+	return func(c cenery.Ctx) error {
+		if cfg.Skip != nil && cfg.Skip(c) {
+			return c.Next()
+		}
+
+		ctx, cancel := context.WithTimeout(c.Context(), cfg.Timeout)
+		defer cancel()
+
+		var err error
+		if tn, ok := c.(cenery.TimeoutNexter); ok {
+			err = tn.NextTimeout(ctx)
+		} else {
+			prev := c.Context()
+			c.SetContext(ctx)
+			err = c.Next()
+			c.SetContext(prev)
+		}
+
+		if errors.Is(err, context.DeadlineExceeded) && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return &cenery.Error{Code: cfg.StatusCode, Message: cfg.Message, Err: context.DeadlineExceeded}
+		}
+		return err
+	}
+}
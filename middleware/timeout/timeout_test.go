@@ -0,0 +1,86 @@
+package timeout
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+type testCtx struct {
+	cenery.Ctx
+	ctx  context.Context
+	next func(ctx context.Context) error
+}
+
+func (c *testCtx) Context() context.Context       { return c.ctx }
+func (c *testCtx) SetContext(ctx context.Context) { c.ctx = ctx }
+func (c *testCtx) Next() error                    { return c.next(c.ctx) }
+
+type timeoutCtx struct {
+	*testCtx
+	called bool
+}
+
+func (c *timeoutCtx) NextTimeout(ctx context.Context) error {
+	c.called = true
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestTimeoutExpired(t *testing.T) {
+	handler := New(Config{Timeout: 10 * time.Millisecond, StatusCode: 504})
+	c := &testCtx{
+		ctx: context.Background(),
+		next: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+
+	err := handler(c)
+	var e *cenery.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("handler() error = %v, want *cenery.Error", err)
+	}
+	if e.Code != 504 {
+		t.Errorf("Code = %v, want %v", e.Code, 504)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error does not wrap context.DeadlineExceeded")
+	}
+}
+
+func TestTimeoutNotExpired(t *testing.T) {
+	handler := New(Config{Timeout: time.Second})
+	want := errors.New("boom")
+	c := &testCtx{
+		ctx: context.Background(),
+		next: func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("context has no deadline")
+			}
+			return want
+		},
+	}
+
+	if err := handler(c); err != want {
+		t.Errorf("handler() error = %v, want %v", err, want)
+	}
+}
+
+func TestTimeoutNexter(t *testing.T) {
+	handler := New(Config{Timeout: 10 * time.Millisecond})
+	c := &timeoutCtx{testCtx: &testCtx{ctx: context.Background()}}
+
+	err := handler(c)
+	if !c.called {
+		t.Fatalf("NextTimeout was not used")
+	}
+	var e *cenery.Error
+	if !errors.As(err, &e) || e.Code != 503 {
+		t.Errorf("handler() error = %v, want 503 *cenery.Error", err)
+	}
+}
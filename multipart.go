@@ -0,0 +1,268 @@
+package cenery
+
+import (
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+)
+
+// ErrNotMultipart is wrapped by the 400 *Error Ctx.MultipartReader returns
+// for requests that are not multipart/form-data.
+var ErrNotMultipart = errors.New("cenery: request is not multipart/form-data")
+
+// ErrFileNotFound is wrapped by the 400 *Error Ctx.FormFileE and friends
+// return when the form has no file under the requested key.
+var ErrFileNotFound = errors.New("cenery: file not found")
+
+// FormFileHeaders returns the files uploaded as key in form, or
+// ErrFileNotFound when there are none.
+func FormFileHeaders(form *multipart.Form, key string) ([]*multipart.FileHeader, error) {
+	if form == nil || len(form.File[key]) == 0 {
+		return nil, RequestError(ErrFileNotFound)
+	}
+	return form.File[key], nil
+}
+
+// FormFileData implements Ctx.FormFilesE for engines: it reads every file
+// uploaded as key in form.
+func FormFileData(form *multipart.Form, key string) ([]FileData, error) {
+	headers, err := FormFileHeaders(form, key)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]FileData, len(headers))
+	for i, h := range headers {
+		file, err := ReadFormFile(h)
+		if err != nil {
+			return nil, err
+		}
+		files[i] = *file
+	}
+	return files, nil
+}
+
+// FormFileStreams implements Ctx.FormFilesStream for engines: it opens
+// every file uploaded as key in form, closing them again on failure.
+func FormFileStreams(form *multipart.Form, key string) ([]*FileStream, error) {
+	headers, err := FormFileHeaders(form, key)
+	if err != nil {
+		return nil, err
+	}
+	streams := make([]*FileStream, 0, len(headers))
+	for _, h := range headers {
+		stream, err := OpenFormFile(h)
+		if err != nil {
+			for _, s := range streams {
+				s.Close()
+			}
+			return nil, err
+		}
+		streams = append(streams, stream)
+	}
+	return streams, nil
+}
+
+// ReadFormFile reads the uploaded file h into memory.
+func ReadFormFile(h *multipart.FileHeader) (*FileData, error) {
+	f, err := h.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return &FileData{
+		FileData:            data,
+		FileName:            h.Filename,
+		FileSize:            h.Size,
+		FileContentType:     declaredContentType(h),
+		DetectedContentType: http.DetectContentType(data),
+		Header:              h.Header,
+	}, nil
+}
+
+// OpenFormFile opens the uploaded file h for reading.
+func OpenFormFile(h *multipart.FileHeader) (*FileStream, error) {
+	f, err := h.Open()
+	if err != nil {
+		return nil, err
+	}
+	var head [512]byte
+	n, err := f.ReadAt(head[:], 0)
+	if err != nil && err != io.EOF {
+		f.Close()
+		return nil, err
+	}
+	return &FileStream{
+		File:                f,
+		FileName:            h.Filename,
+		FileSize:            h.Size,
+		FileContentType:     declaredContentType(h),
+		DetectedContentType: http.DetectContentType(head[:n]),
+		Header:              h.Header,
+	}, nil
+}
+
+func declaredContentType(h *multipart.FileHeader) string {
+	if ct := h.Header.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// ParseMultipartForm implements Ctx.MultipartForm for the net/http engines:
+// it parses the multipart body of r like r.ParseMultipartForm, holding up
+// to MultipartMemory of it in memory. With MaxFiles or MaxFileSize set, the
+// parts are checked as they are read, so an oversized form fails with
+// ErrTooManyFiles or ErrFileTooLarge before the rest of it is spooled to
+// disk. The body of r should already be capped at BodyLimit.
+func ParseMultipartForm(r *http.Request, l Limits) error {
+	if !l.ChecksFiles() {
+		return r.ParseMultipartForm(l.MultipartMemory)
+	}
+	if r.Form == nil {
+		if err := r.ParseForm(); err != nil {
+			return err
+		}
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+	form, err := ReadMultipartForm(NewMultipartReader(mr, l), l.MultipartMemory)
+	if err != nil {
+		r.MultipartForm = nil
+		return err
+	}
+	if r.PostForm == nil {
+		r.PostForm = make(url.Values)
+	}
+	for k, v := range form.Value {
+		r.Form[k] = append(r.Form[k], v...)
+		r.PostForm[k] = append(r.PostForm[k], v...)
+	}
+	r.MultipartForm = form
+	return nil
+}
+
+// ReadMultipartForm parses the parts of r with multipart.Reader.ReadForm,
+// holding up to maxMemory of them in memory, for engines whose own parser
+// only checks the form once it is stored. The parts are copied through a
+// pipe: a part that breaks the limits of r fails the copy, and with it
+// ReadForm, which then removes the files it has stored.
+func ReadMultipartForm(r *MultipartReader, maxMemory int64) (*multipart.Form, error) {
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	copied := make(chan error, 1)
+	go func() {
+		err := copyParts(w, r)
+		pw.CloseWithError(err)
+		copied <- err
+	}()
+	form, err := multipart.NewReader(pr, w.Boundary()).ReadForm(maxMemory)
+	pr.Close()
+	if copyErr := <-copied; err != nil && copyErr != nil && !errors.Is(copyErr, io.ErrClosedPipe) {
+		return nil, copyErr
+	}
+	return form, err
+}
+
+func copyParts(w *multipart.Writer, r *MultipartReader) error {
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			return w.Close()
+		}
+		if err != nil {
+			return err
+		}
+		dst, err := w.CreatePart(part.Header)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(dst, part); err != nil {
+			return err
+		}
+	}
+}
+
+// MultipartReader reads the parts of a multipart/form-data body one at a
+// time, straight from the request body and without temporary files. The
+// body is capped at BodyLimit; MaxFiles and MaxFileSize are enforced as
+// parts are read. A handler may stop at any part: the rest of the body is
+// never read.
+type MultipartReader struct {
+	mr     *multipart.Reader
+	limits Limits
+	files  int
+	part   *FormPart
+}
+
+// NewMultipartReader implements Ctx.MultipartReader for engines. The body
+// behind mr should already be capped at the BodyLimit of l.
+func NewMultipartReader(mr *multipart.Reader, l Limits) *MultipartReader {
+	return &MultipartReader{mr: mr, limits: l}
+}
+
+// NextPart returns the next part, closing the previous one. It returns
+// io.EOF after the last part and ErrTooManyFiles once a file part exceeds
+// MaxFiles.
+func (r *MultipartReader) NextPart() (*FormPart, error) {
+	if r.part != nil {
+		r.part.Close()
+		r.part = nil
+	}
+	p, err := r.mr.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	part := &FormPart{Part: p, limit: -1}
+	if p.FileName() != "" {
+		r.files++
+		if maxFiles := r.limits.maxFiles(); maxFiles >= 0 && r.files > maxFiles {
+			// Closing p would read the rest of it.
+			return nil, RequestError(ErrTooManyFiles)
+		}
+		part.limit = r.limits.MaxFileSize
+	}
+	r.part = part
+	return part, nil
+}
+
+// FormPart is one field or file of a multipart body. Its Header holds the
+// part's own headers, such as Content-Type. Reading a file part past
+// MaxFileSize fails with ErrFileTooLarge.
+type FormPart struct {
+	*multipart.Part
+	limit int64
+	read  int64
+	err   error
+}
+
+func (p *FormPart) Read(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.Part.Read(b)
+	if p.limit >= 0 {
+		p.read += int64(n)
+		if over := p.read - p.limit; over > 0 {
+			p.err = RequestError(ErrFileTooLarge)
+			return n - int(over), p.err
+		}
+	}
+	return n, err
+}
+
+// ContentType returns the Content-Type of the part, or
+// "application/octet-stream" when it has none.
+func (p *FormPart) ContentType() string {
+	if ct := p.Header.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,130 @@
+package cenery
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ErrUnsafeRedirect is wrapped by the 400 error returned when a redirect
+// target fails validation.
+var ErrUnsafeRedirect = errors.New("cenery: unsafe redirect target")
+
+// ErrRedirectStatus is returned for a redirect status other than 301, 302,
+// 303, 307 or 308.
+var ErrRedirectStatus = errors.New("cenery: invalid redirect status")
+
+// RedirectConfig guards Ctx.Redirect and friends against open redirects.
+// Relative targets are always allowed, except ones a browser would read as
+// another host, such as "//evil.com" or "/\evil.com". Absolute http and
+// https targets are allowed for the host of the request and AllowedHosts.
+type RedirectConfig struct {
+	// AllowedHosts lists further hosts absolute targets may point to. An
+	// entry "*.example.com" matches every subdomain of example.com.
+	AllowedHosts []string
+	// RelativeOnly rejects every absolute target, even for the request host.
+	RelativeOnly bool
+}
+
+// Redirect implements Ctx.Redirect for engines. cfg is the config of the
+// app serving c and may be nil.
+func Redirect(c Ctx, cfg *Config, status int, location string) error {
+	var rc RedirectConfig
+	if cfg != nil {
+		rc = cfg.Redirect
+	}
+	if !rc.Allow(c, location) {
+		return &Error{Code: http.StatusBadRequest, Message: "unsafe redirect target", Err: ErrUnsafeRedirect}
+	}
+	switch status {
+	case 0:
+		status = http.StatusSeeOther
+		if m := c.Request().Method(); m == http.MethodGet || m == http.MethodHead {
+			status = http.StatusFound
+		}
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return ErrRedirectStatus
+	}
+	c.Response().SetHeader("Location", location)
+	return c.Send(status, nil)
+}
+
+// RedirectToRoute implements Ctx.RedirectToRoute for engines.
+func RedirectToRoute(c Ctx, cfg *Config, name string, params map[string]string) error {
+	var routes *Routes
+	if cfg != nil {
+		routes = cfg.Routes
+	}
+	location, err := routes.URL(name, params)
+	if err != nil {
+		return err
+	}
+	return Redirect(c, cfg, 0, location)
+}
+
+// RedirectBack implements Ctx.RedirectBack for engines.
+func RedirectBack(c Ctx, cfg *Config, fallback string) error {
+	var rc RedirectConfig
+	if cfg != nil {
+		rc = cfg.Redirect
+	}
+	location := c.Request().GetHeader("Referer")
+	if location == "" || !rc.Allow(c, location) {
+		location = fallback
+	}
+	return Redirect(c, cfg, 0, location)
+}
+
+// Allow reports whether c may be redirected to location.
+func (rc RedirectConfig) Allow(c Ctx, location string) bool {
+	if location == "" {
+		return false
+	}
+	for i := 0; i < len(location); i++ {
+		// Browsers drop control characters and read a backslash as a
+		// slash, so "/\t/evil.com" and "/\evil.com" leave the site.
+		if ch := location[i]; ch < 0x20 || ch == 0x7f || ch == '\\' {
+			return false
+		}
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" && u.Opaque == "" {
+		return !strings.HasPrefix(u.Path, "//")
+	}
+	if rc.RelativeOnly || u.User != nil || u.Host == "" {
+		return false
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == strings.ToLower(hostname(c.Request().Host())) {
+		return true
+	}
+	for _, allowed := range rc.AllowedHosts {
+		allowed = strings.ToLower(allowed)
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(host, "."+suffix) {
+				return true
+			}
+		} else if host == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// hostname strips the port from host.
+func hostname(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		return h
+	}
+	return strings.Trim(host, "[]")
+}
@@ -0,0 +1,91 @@
+package cenery
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// ErrRouteNotFound is returned by Routes.URL for a name no route was given.
+var ErrRouteNotFound = errors.New("cenery: route not found")
+
+// Route is a route registered on an App. Name it to build its URL with
+// Routes.URL or Ctx.RedirectToRoute.
+type Route struct {
+	Method string
+	Path   string
+
+	routes *Routes
+}
+
+// Name registers r under name, replacing any route of the same name.
+func (r *Route) Name(name string) *Route {
+	r.routes.mu.Lock()
+	if r.routes.named == nil {
+		r.routes.named = make(map[string]*Route)
+	}
+	r.routes.named[name] = r
+	r.routes.mu.Unlock()
+	return r
+}
+
+// Routes records the named routes of an App.
+type Routes struct {
+	mu    sync.RWMutex
+	named map[string]*Route
+}
+
+// Add returns the Route for method and path. Engines call it for every
+// registered route; the route is only looked up once it has a Name.
+func (rs *Routes) Add(method, path string) *Route {
+	return &Route{Method: method, Path: path, routes: rs}
+}
+
+// Lookup returns the route registered under name.
+func (rs *Routes) Lookup(name string) (*Route, bool) {
+	if rs == nil {
+		return nil, false
+	}
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	r, ok := rs.named[name]
+	return r, ok
+}
+
+// URL builds the path of the route registered under name. Each ":param"
+// segment is replaced by the escaped value of params[param]; a wildcard
+// segment "*name" takes params[name], or params["*"] when unnamed, and keeps
+// its slashes. A missing parameter is an error.
+func (rs *Routes) URL(name string, params map[string]string) (string, error) {
+	r, ok := rs.Lookup(name)
+	if !ok {
+		return "", ErrRouteNotFound
+	}
+	segments := strings.Split(r.Path, "/")
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, ":") && len(seg) > 1:
+			val, ok := params[seg[1:]]
+			if !ok {
+				return "", errors.New("cenery: route " + name + " needs parameter " + seg[1:])
+			}
+			segments[i] = url.PathEscape(val)
+		case strings.HasPrefix(seg, "*"):
+			key := seg[1:]
+			if key == "" {
+				key = "*"
+			}
+			val, ok := params[key]
+			if !ok {
+				return "", errors.New("cenery: route " + name + " needs parameter " + key)
+			}
+			parts := strings.Split(strings.TrimPrefix(val, "/"), "/")
+			for j, part := range parts {
+				parts[j] = url.PathEscape(part)
+			}
+			segments[i] = strings.Join(parts, "/")
+		}
+	}
+	return strings.Join(segments, "/"), nil
+}
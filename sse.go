@@ -0,0 +1,117 @@
+package cenery
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidEvent is returned by EventWriter.Event when the id or name
+// contains a line break.
+var ErrInvalidEvent = errors.New("cenery: event id and name must be a single line")
+
+// EventWriter writes Server-Sent Events. Every call sends its event to the
+// client immediately.
+type EventWriter interface {
+	// Event sends an event. id and name are left out when empty; data may
+	// span several lines.
+	Event(id, name, data string) error
+	// Retry tells the client how long to wait before reconnecting.
+	Retry(d time.Duration) error
+	// Comment sends a comment line, which clients ignore. Sending one
+	// periodically keeps proxies from closing an idle stream and detects
+	// a disconnected client.
+	Comment(text string) error
+	// LastEventID returns the Last-Event-ID header sent by a reconnecting
+	// client.
+	LastEventID() string
+	// Context is done when the client disconnects or the stream fails.
+	Context() context.Context
+}
+
+// SetEventStreamHeaders sets the response headers of an event stream.
+func SetEventStreamHeaders(resp Response) {
+	resp.SetHeader("Content-Type", "text/event-stream")
+	resp.SetHeader("Cache-Control", "no-cache")
+	resp.SetHeader("X-Accel-Buffering", "no")
+}
+
+// NewEventWriter returns an EventWriter writing to w and calling flush
+// after every event. A failed write or flush cancels the writer's context.
+// Engines use it to implement Ctx.SSE.
+func NewEventWriter(ctx context.Context, w io.Writer, flush func() error, lastEventID string) (EventWriter, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &eventWriter{ctx: ctx, cancel: cancel, w: w, flush: flush, lastEventID: lastEventID}, cancel
+}
+
+type eventWriter struct {
+	ctx         context.Context
+	cancel      context.CancelFunc
+	w           io.Writer
+	flush       func() error
+	lastEventID string
+	buf         strings.Builder
+}
+
+func (e *eventWriter) Event(id, name, data string) error {
+	if strings.ContainsAny(id, "\r\n") || strings.ContainsAny(name, "\r\n") {
+		return ErrInvalidEvent
+	}
+	e.buf.Reset()
+	if id != "" {
+		e.buf.WriteString("id: " + id + "\n")
+	}
+	if name != "" {
+		e.buf.WriteString("event: " + name + "\n")
+	}
+	for _, line := range splitLines(data) {
+		e.buf.WriteString("data: " + line + "\n")
+	}
+	e.buf.WriteString("\n")
+	return e.send()
+}
+
+func (e *eventWriter) Retry(d time.Duration) error {
+	e.buf.Reset()
+	e.buf.WriteString("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+	return e.send()
+}
+
+func (e *eventWriter) Comment(text string) error {
+	e.buf.Reset()
+	for _, line := range splitLines(text) {
+		e.buf.WriteString(": " + line + "\n")
+	}
+	return e.send()
+}
+
+func (e *eventWriter) LastEventID() string {
+	return e.lastEventID
+}
+
+func (e *eventWriter) Context() context.Context {
+	return e.ctx
+}
+
+func (e *eventWriter) send() error {
+	if err := e.ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(e.w, e.buf.String()); err != nil {
+		e.cancel()
+		return err
+	}
+	if err := e.flush(); err != nil {
+		e.cancel()
+		return err
+	}
+	return nil
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.Split(strings.ReplaceAll(s, "\r", "\n"), "\n")
+}
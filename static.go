@@ -0,0 +1,302 @@
+package cenery
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"html"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type StaticConfig struct {
+	// Index is the file served for a directory. Defaults to "index.html".
+	Index string
+	// Browse lists the entries of a directory without an index file.
+	Browse bool
+	// MaxAge sets Cache-Control to "public, max-age=..." when positive.
+	MaxAge time.Duration
+	// Precompressed serves name.br or name.gz in place of name when it
+	// exists and the client accepts that encoding.
+	Precompressed bool
+}
+
+// RootFS implements App.Static for engines: it returns the files under
+// dir, opened with os.OpenRoot so that, unlike os.DirFS, symlinks cannot
+// lead out of dir; such a link is reported as not found. dir is opened for
+// each lookup and closed after it, so nothing is held open between
+// requests and a dir that cannot be opened fails every lookup.
+func RootFS(dir string) fs.FS {
+	return rootFS{dir}
+}
+
+type rootFS struct{ dir string }
+
+func (f rootFS) Open(name string) (fs.File, error) {
+	root, err := os.OpenRoot(f.dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	defer root.Close()
+	file, err := root.FS().Open(name)
+	return file, f.escapeError("open", name, err)
+}
+
+func (f rootFS) Stat(name string) (fs.FileInfo, error) {
+	root, err := os.OpenRoot(f.dir)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	defer root.Close()
+	info, err := root.FS().(fs.StatFS).Stat(name)
+	return info, f.escapeError("stat", name, err)
+}
+
+// escapeError turns the error os.Root returns for a name leading out of dir
+// into fs.ErrNotExist. os does not export that error, so when the lookup
+// failed for a reason other than a missing or forbidden file, name is
+// resolved here and checked against dir.
+func (f rootFS) escapeError(op, name string, err error) error {
+	if err == nil || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) || f.contains(name) {
+		return err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// contains reports whether name, with its symlinks resolved, is inside dir.
+func (f rootFS) contains(name string) bool {
+	dir, err := filepath.EvalSymlinks(f.dir)
+	if err != nil {
+		return false
+	}
+	target, err := filepath.EvalSymlinks(filepath.Join(f.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(dir, target)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// StaticHandler returns a GET and HEAD handler serving the files of fsys
+// below prefix. Engines route prefix and every path under it to it. Paths
+// are cleaned and checked with fs.ValidPath, so requests cannot leave fsys.
+func StaticHandler(prefix string, fsys fs.FS, config ...StaticConfig) Handler {
+	var cfg StaticConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Index == "" {
+		cfg.Index = "index.html"
+	}
+	s := &staticServer{prefix: strings.TrimRight(prefix, "/"), fsys: fsys, cfg: cfg}
+	return s.serve
+}
+
+type staticServer struct {
+	prefix string
+	fsys   fs.FS
+	cfg    StaticConfig
+	etags  sync.Map // name -> content hash, for files without a modtime
+}
+
+var precompressed = []struct {
+	encoding string
+	ext      string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+func (s *staticServer) serve(c Ctx) error {
+	urlPath := c.Request().Path()
+	rel, ok := strings.CutPrefix(urlPath, s.prefix)
+	if !ok || (rel != "" && rel[0] != '/') {
+		return NewError(http.StatusNotFound)
+	}
+	name := strings.TrimPrefix(path.Clean("/"+rel), "/")
+	if name == "" {
+		name = "."
+	}
+	if !fs.ValidPath(name) {
+		return NewError(http.StatusNotFound)
+	}
+
+	info, err := fs.Stat(s.fsys, name)
+	if err != nil {
+		return fsError(err)
+	}
+	if info.IsDir() {
+		if !strings.HasSuffix(urlPath, "/") {
+			return redirectDir(c, urlPath)
+		}
+		index := path.Join(name, s.cfg.Index)
+		if indexInfo, err := fs.Stat(s.fsys, index); err == nil && indexInfo.Mode().IsRegular() {
+			return s.serveFile(c, index, indexInfo)
+		}
+		if s.cfg.Browse {
+			return s.serveDir(c, name, urlPath)
+		}
+		return NewError(http.StatusNotFound)
+	}
+	if !info.Mode().IsRegular() {
+		return NewError(http.StatusNotFound)
+	}
+	return s.serveFile(c, name, info)
+}
+
+func (s *staticServer) serveFile(c Ctx, name string, info fs.FileInfo) error {
+	resp := c.Response()
+	typeName := name
+
+	if s.cfg.Precompressed {
+		resp.AddHeader("Vary", "Accept-Encoding")
+		accept := c.Request().GetHeader("Accept-Encoding")
+		for _, p := range precompressed {
+			if !acceptsEncoding(accept, p.encoding) {
+				continue
+			}
+			variant, err := fs.Stat(s.fsys, name+p.ext)
+			if err != nil || !variant.Mode().IsRegular() {
+				continue
+			}
+			resp.SetHeader("Content-Encoding", p.encoding)
+			name, info = name+p.ext, variant
+			break
+		}
+	}
+
+	etag, err := s.etag(name, info)
+	if err != nil {
+		return fsError(err)
+	}
+	if s.cfg.MaxAge > 0 {
+		resp.SetHeader("Cache-Control", "public, max-age="+strconv.Itoa(int(s.cfg.MaxAge/time.Second)))
+	}
+
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return fsError(err)
+	}
+	return sendContent(c, f, info, typeName, etag)
+}
+
+// etag returns "" for files with a modtime, leaving sendContent to derive
+// it, and a content hash for file systems such as embed.FS without them.
+func (s *staticServer) etag(name string, info fs.FileInfo) (string, error) {
+	if !info.ModTime().IsZero() {
+		return "", nil
+	}
+	if etag, ok := s.etags.Load(name); ok {
+		return etag.(string), nil
+	}
+	f, err := s.fsys.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	etag := `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+	s.etags.Store(name, etag)
+	return etag, nil
+}
+
+func (s *staticServer) serveDir(c Ctx, name, urlPath string) error {
+	entries, err := fs.ReadDir(s.fsys, name)
+	if err != nil {
+		return fsError(err)
+	}
+	var b strings.Builder
+	title := html.EscapeString(urlPath)
+	b.WriteString("<!doctype html>\n<title>" + title + "</title>\n<h1>" + title + "</h1>\n<ul>\n")
+	if name != "." {
+		b.WriteString("<li><a href=\"../\">../</a></li>\n")
+	}
+	for _, e := range entries {
+		entry := e.Name()
+		if e.IsDir() {
+			entry += "/"
+		}
+		href := (&url.URL{Path: entry}).EscapedPath()
+		b.WriteString("<li><a href=\"" + html.EscapeString(href) + "\">" + html.EscapeString(entry) + "</a></li>\n")
+	}
+	b.WriteString("</ul>\n")
+	c.Response().SetHeader("Content-Type", "text/html; charset=utf-8")
+	return c.SendString(http.StatusOK, b.String())
+}
+
+// acceptsEncoding reports whether an Accept-Encoding header allows
+// encoding with a non-zero quality.
+func acceptsEncoding(header, encoding string) bool {
+	for _, part := range strings.Split(header, ",") {
+		token, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(token), encoding) {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+// redirectDir redirects to urlPath with a trailing slash. The path is
+// cleaned first so "//host" cannot become a protocol-relative location.
+func redirectDir(c Ctx, urlPath string) error {
+	location := (&url.URL{Path: path.Clean(urlPath) + "/"}).EscapedPath()
+	c.Response().SetHeader("Location", location)
+	return c.Send(http.StatusMovedPermanently, nil)
+}
+
+func fsError(err error) error {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return NewError(http.StatusNotFound)
+	case errors.Is(err, fs.ErrPermission):
+		return NewError(http.StatusForbidden)
+	}
+	return err
+}
+
+// sentBody is a response body that closes c once it has been copied out.
+// Engines that copy the body within Ctx.SendStream do so through WriteTo,
+// and the others close io.Closer bodies once they are sent, so c is closed
+// either way.
+type sentBody struct {
+	r    io.Reader
+	c    io.Closer
+	once sync.Once
+}
+
+func closeAfterSend(r io.Reader, c io.Closer) *sentBody {
+	return &sentBody{r: r, c: c}
+}
+
+func (b *sentBody) Read(p []byte) (int, error) {
+	return b.r.Read(p)
+}
+
+func (b *sentBody) WriteTo(w io.Writer) (int64, error) {
+	defer b.Close()
+	return io.Copy(w, b.r)
+}
+
+func (b *sentBody) Close() error {
+	var err error
+	b.once.Do(func() { err = b.c.Close() })
+	return err
+}
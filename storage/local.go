@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/dreamph/cenery"
+)
+
+// Local stores files in a directory of the local filesystem.
+type Local struct {
+	root string
+}
+
+// NewLocal returns a Local storing files under root.
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+func (l *Local) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(key)), nil
+}
+
+// Put writes r to a temporary file next to key and renames it into place,
+// so key never holds a partial file. The content type is not recorded.
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, contentType string) (Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return Object{}, err
+	}
+	h := sha256.New()
+	n, err := cenery.WriteFile(ctx, p, io.TeeReader(r, h))
+	if err != nil {
+		return Object{}, err
+	}
+	return Object{Key: key, Size: n, ContentType: contentType, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// Get opens key. The content type comes from its extension.
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, Object{}, ErrNotFound
+		}
+		return nil, Object{}, err
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		f.Close()
+		if err == nil {
+			err = ErrNotFound
+		}
+		return nil, Object{}, err
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	return f, Object{Key: key, Size: info.Size(), ContentType: contentType}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"sync"
+)
+
+// Memory stores files in memory. It suits tests and small caches.
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	Object
+	data []byte
+}
+
+// NewMemory returns an empty Memory.
+func NewMemory() *Memory {
+	return &Memory{objects: make(map[string]memoryObject)}
+}
+
+func (m *Memory) Put(ctx context.Context, key string, r io.Reader, contentType string) (Object, error) {
+	if err := checkKey(key); err != nil {
+		return Object{}, err
+	}
+	data, err := io.ReadAll(ctxReader{ctx, r})
+	if err != nil {
+		return Object{}, err
+	}
+	sum := sha256.Sum256(data)
+	obj := Object{Key: key, Size: int64(len(data)), ContentType: contentType, SHA256: hex.EncodeToString(sum[:])}
+	m.mu.Lock()
+	m.objects[key] = memoryObject{Object: obj, data: data}
+	m.mu.Unlock()
+	return obj, nil
+}
+
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	if err := checkKey(key); err != nil {
+		return nil, Object{}, err
+	}
+	m.mu.RLock()
+	obj, ok := m.objects[key]
+	m.mu.RUnlock()
+	if !ok {
+		return nil, Object{}, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(obj.data)), obj.Object, nil
+}
+
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	delete(m.objects, key)
+	m.mu.Unlock()
+	return nil
+}
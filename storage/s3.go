@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Config configures an S3-compatible object store, such as AWS S3,
+// MinIO or Cloudflare R2.
+type S3Config struct {
+	// Endpoint is the base URL of the service, such as
+	// "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000".
+	Endpoint string
+	// Region signs requests. Defaults to "us-east-1".
+	Region string
+	Bucket string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is sent with temporary credentials.
+	SessionToken string
+
+	// VirtualHosted addresses the bucket as a subdomain of Endpoint
+	// instead of its first path segment. Path-style addressing, the
+	// default, works with most S3-compatible servers.
+	VirtualHosted bool
+
+	// Client sends the requests. Defaults to http.DefaultClient.
+	Client *http.Client
+	// Clock returns the signing time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// S3 stores files in a bucket of an S3-compatible object store. Requests
+// are signed with AWS Signature Version 4.
+type S3 struct {
+	cfg S3Config
+}
+
+// NewS3 returns an S3 for cfg.
+func NewS3(cfg S3Config) *S3 {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Client == nil {
+		cfg.Client = http.DefaultClient
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	cfg.Endpoint = strings.TrimRight(cfg.Endpoint, "/")
+	return &S3{cfg: cfg}
+}
+
+// Put spools r to a temporary file to learn its size and SHA-256, which
+// the service verifies, then uploads it in one request. The digest is also
+// stored as the x-amz-meta-sha256 metadata Get reports. A failed upload
+// leaves no object.
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, contentType string) (Object, error) {
+	if err := checkKey(key); err != nil {
+		return Object{}, err
+	}
+	tmp, err := os.CreateTemp("", "cenery-s3-*")
+	if err != nil {
+		return Object{}, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), ctxReader{ctx, r})
+	if err != nil {
+		return Object{}, err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return Object{}, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, io.NopCloser(tmp))
+	if err != nil {
+		return Object{}, err
+	}
+	req.ContentLength = n
+	if n == 0 {
+		req.Body = http.NoBody
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Amz-Meta-Sha256", sum)
+	resp, err := s.do(req, sum)
+	if err != nil {
+		return Object{}, err
+	}
+	resp.Body.Close()
+	return Object{Key: key, Size: n, ContentType: contentType, SHA256: sum}, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, Object, error) {
+	if err := checkKey(key); err != nil {
+		return nil, Object{}, err
+	}
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if err != nil {
+		return nil, Object{}, err
+	}
+	return resp.Body, Object{
+		Key:         key,
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+		SHA256:      resp.Header.Get("X-Amz-Meta-Sha256"),
+	}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(req, emptySHA256)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// emptySHA256 is the hex SHA-256 of an empty payload.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+func (s *S3) newRequest(ctx context.Context, method, key string, body io.Reader) (*http.Request, error) {
+	base, err := url.Parse(s.cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	escaped := "/" + uriEscape(key, false)
+	if s.cfg.VirtualHosted {
+		base.Host = s.cfg.Bucket + "." + base.Host
+	} else {
+		escaped = "/" + uriEscape(s.cfg.Bucket, true) + escaped
+	}
+	base.Path, err = url.PathUnescape(escaped)
+	if err != nil {
+		return nil, err
+	}
+	base.RawPath = escaped
+	return http.NewRequestWithContext(ctx, method, base.String(), body)
+}
+
+// do signs and sends req, turning error statuses into errors.
+func (s *S3) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	signV4(req, payloadHash, s.cfg, s.cfg.Clock())
+	resp, err := s.cfg.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	return nil, errors.New("storage: S3 " + req.Method + " " + req.URL.Path + ": " + resp.Status)
+}
+
+// signV4 adds the AWS Signature Version 4 headers to req, signing the
+// host and every header already set.
+func signV4(req *http.Request, payloadHash string, cfg S3Config, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if cfg.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.SessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers[strings.ToLower(name)] = strings.Join(values, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + cfg.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+cfg.SecretAccessKey), date)
+	key = hmacSHA256(key, cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+cfg.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEscape(k, true)+"="+uriEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEscape percent-encodes every byte of s but the unreserved characters
+// of RFC 3986, as Signature Version 4 requires, keeping slashes unless
+// escapeSlash is set.
+func uriEscape(s string, escapeSlash bool) string {
+	const hexDigits = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case 'a' <= ch && ch <= 'z', 'A' <= ch && ch <= 'Z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', ch == '/' && !escapeSlash:
+			b.WriteByte(ch)
+		default:
+			b.WriteString("%" + string(hexDigits[ch>>4]) + string(hexDigits[ch&0xf]))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,198 @@
+// Package storage persists uploaded files to pluggable backends: the local
+// filesystem, memory and S3-compatible object stores.
+package storage
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/dreamph/cenery"
+)
+
+var (
+	// ErrNotFound is returned by Get for keys that hold no object.
+	ErrNotFound = cenery.NewError(http.StatusNotFound)
+	// ErrInvalidKey is returned for keys that are empty, absolute or leave
+	// their root, such as "../x".
+	ErrInvalidKey = cenery.NewError(http.StatusBadRequest, "invalid key")
+	// ErrContentType is returned by Save for files whose content type is
+	// not in AllowedTypes.
+	ErrContentType = cenery.NewError(http.StatusUnsupportedMediaType, "file type not allowed")
+	// ErrChecksumMismatch is returned by Save when the uploaded file does not
+	// match the expected SHA256.
+	ErrChecksumMismatch = cenery.NewError(http.StatusBadRequest, "checksum mismatch")
+)
+
+// Object describes a stored file.
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	// SHA256 is the hex digest of the content. Get leaves it empty on
+	// backends that do not record it.
+	SHA256 string
+}
+
+// Storage stores files by key. Keys are slash-separated paths such as
+// "avatars/1f3c.png". Put must not leave a partial object behind when it
+// fails, including when r fails after its last byte, and must leave an
+// existing object under key unchanged then.
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) (Object, error)
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// SaveOptions controls Save.
+type SaveOptions struct {
+	// Key is the key to store the file under. Defaults to
+	// UniqueKey(Prefix, file.FileName).
+	Key string
+	// Prefix is the directory of generated keys.
+	Prefix string
+	// SniffContentType sets the content type from the first 512 bytes of
+	// the file instead of the declared FileContentType, which clients
+	// control. The content is always sniffed when nothing was declared or
+	// AllowedTypes is set.
+	SniffContentType bool
+	// AllowedTypes rejects files whose sniffed media type is not listed
+	// with ErrContentType. Empty allows any type.
+	AllowedTypes []string
+	// SHA256 is the expected hex digest of the file. It is checked as the
+	// file is read, so a mismatch fails Put with ErrChecksumMismatch before
+	// anything is stored and an object already under Key is kept.
+	SHA256 string
+}
+
+// Save stores an uploaded file in s. Nothing is left in s when it fails.
+func Save(ctx context.Context, s Storage, file *cenery.FileStream, opts ...SaveOptions) (Object, error) {
+	var o SaveOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if file == nil || file.File == nil {
+		return Object{}, errors.New("storage: Save needs a file")
+	}
+	key := o.Key
+	if key == "" {
+		key = UniqueKey(o.Prefix, file.FileName)
+	}
+
+	r := bufio.NewReaderSize(file.File, 512)
+	head, err := r.Peek(512)
+	if err != nil && err != io.EOF {
+		return Object{}, err
+	}
+	contentType := file.FileContentType
+	if o.SniffContentType || len(o.AllowedTypes) > 0 || contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(head)
+	}
+	if len(o.AllowedTypes) > 0 && !allowedType(contentType, o.AllowedTypes) {
+		return Object{}, ErrContentType
+	}
+
+	var body io.Reader = r
+	if o.SHA256 != "" {
+		body = &checksumReader{r: r, h: sha256.New(), want: o.SHA256}
+	}
+	obj, err := s.Put(ctx, key, body, contentType)
+	if errors.Is(err, ErrChecksumMismatch) {
+		return Object{}, ErrChecksumMismatch
+	}
+	if err != nil {
+		return Object{}, err
+	}
+	return obj, nil
+}
+
+// checksumReader hashes what is read from r and returns ErrChecksumMismatch
+// in place of io.EOF when the digest is not want, so that Put fails before
+// it commits the object.
+type checksumReader struct {
+	r    io.Reader
+	h    hash.Hash
+	want string
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.h.Write(p[:n])
+	if err == io.EOF && !strings.EqualFold(hex.EncodeToString(c.h.Sum(nil)), c.want) {
+		err = ErrChecksumMismatch
+	}
+	return n, err
+}
+
+func allowedType(contentType string, allowed []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// UniqueKey returns a random key under prefix that keeps the extension of
+// filename, such as "avatars/9b1c4f0e2a7d3b6c8e5f1a0d2c4b6e8f.png". The
+// rest of the client-supplied name is dropped.
+func UniqueKey(prefix, filename string) string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	name := hex.EncodeToString(b[:])
+	if ext := strings.ToLower(path.Ext(path.Base(strings.ReplaceAll(filename, `\`, "/")))); validExt(ext) {
+		name += ext
+	}
+	if prefix == "" {
+		return name
+	}
+	return path.Join(prefix, name)
+}
+
+func validExt(ext string) bool {
+	if len(ext) < 2 || len(ext) > 16 {
+		return false
+	}
+	for _, ch := range ext[1:] {
+		if !('a' <= ch && ch <= 'z' || '0' <= ch && ch <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func checkKey(key string) error {
+	if !fs.ValidPath(key) || key == "." {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// ctxReader fails reads once ctx is done, so an abandoned upload stops
+// being copied.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
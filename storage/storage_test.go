@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dreamph/cenery"
+)
+
+// helloSHA256 is the hex SHA-256 of "hello".
+const helloSHA256 = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return copy(p, "part"), errors.New("connection reset")
+}
+
+// testStorage checks the behaviour every Storage shares.
+func testStorage(t *testing.T, s Storage) {
+	t.Helper()
+	ctx := context.Background()
+
+	obj, err := s.Put(ctx, "docs/a b.txt", strings.NewReader("hello"), "text/plain")
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if obj.Key != "docs/a b.txt" || obj.Size != 5 || obj.SHA256 != helloSHA256 {
+		t.Errorf("Put() = %+v", obj)
+	}
+
+	rc, got, err := s.Get(ctx, "docs/a b.txt")
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "hello" || got.Size != 5 || !strings.HasPrefix(got.ContentType, "text/plain") {
+		t.Errorf("Get() = %q %+v", data, got)
+	}
+
+	if err := s.Delete(ctx, "docs/a b.txt"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, _, err := s.Get(ctx, "docs/a b.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after Delete() error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "docs/a b.txt"); err != nil {
+		t.Errorf("Delete() of a missing key error = %v", err)
+	}
+
+	if _, err := s.Put(ctx, "broken.txt", failingReader{}, "text/plain"); err == nil {
+		t.Error("Put() of a failing reader succeeded")
+	}
+	if _, _, err := s.Get(ctx, "broken.txt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get() after a failed Put() error = %v, want ErrNotFound", err)
+	}
+
+	for _, key := range []string{"", "../x", "/abs", "a//b", "."} {
+		if _, err := s.Put(ctx, key, strings.NewReader("x"), ""); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) error = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	testStorage(t, NewLocal(dir))
+
+	entries, _ := os.ReadDir(filepath.Join(dir, "docs"))
+	for _, e := range entries {
+		t.Errorf("leftover file %v", e.Name())
+	}
+	entries, _ = os.ReadDir(dir)
+	for _, e := range entries {
+		if e.Name() != "docs" {
+			t.Errorf("leftover file %v", e.Name())
+		}
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, NewMemory())
+}
+
+type testFile struct {
+	*bytes.Reader
+}
+
+func (testFile) Close() error { return nil }
+
+func fileStream(name, contentType, content string) *cenery.FileStream {
+	return &cenery.FileStream{
+		File:            testFile{bytes.NewReader([]byte(content))},
+		FileName:        name,
+		FileSize:        int64(len(content)),
+		FileContentType: contentType,
+	}
+}
+
+func TestSave(t *testing.T) {
+	ctx := context.Background()
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("\x00", 16)
+
+	tests := []struct {
+		name            string
+		file            *cenery.FileStream
+		opts            SaveOptions
+		wantErr         error
+		wantContentType string
+	}{
+		{"declared", fileStream("a.png", "image/png", "hello"), SaveOptions{}, nil, "image/png"},
+		{"sniffed", fileStream("a.png", "image/png", "hello"), SaveOptions{SniffContentType: true}, nil, "text/plain; charset=utf-8"},
+		{"undeclared", fileStream("a.bin", "", png), SaveOptions{}, nil, "image/png"},
+		{"allowed", fileStream("a.png", "image/png", png), SaveOptions{SniffContentType: true, AllowedTypes: []string{"image/png"}}, nil, "image/png"},
+		{"disallowed", fileStream("a.png", "image/png", "hello"), SaveOptions{SniffContentType: true, AllowedTypes: []string{"image/png"}}, ErrContentType, ""},
+		{"disallowed declared", fileStream("a.png", "image/png", "hello"), SaveOptions{AllowedTypes: []string{"image/png"}}, ErrContentType, ""},
+		{"checksum", fileStream("a.txt", "text/plain", "hello"), SaveOptions{SHA256: strings.ToUpper(helloSHA256)}, nil, "text/plain"},
+		{"bad checksum", fileStream("a.txt", "text/plain", "hello"), SaveOptions{SHA256: strings.Repeat("0", 64)}, ErrChecksumMismatch, ""},
+	}
+	for _, tt := range tests {
+		s := NewMemory()
+		obj, err := Save(ctx, s, tt.file, tt.opts)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%v: Save() error = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr != nil {
+			if len(s.objects) != 0 {
+				t.Errorf("%v: Save() left %v objects", tt.name, len(s.objects))
+			}
+			continue
+		}
+		if obj.ContentType != tt.wantContentType {
+			t.Errorf("%v: ContentType = %q, want %q", tt.name, obj.ContentType, tt.wantContentType)
+		}
+		if obj.Size != tt.file.FileSize {
+			t.Errorf("%v: Size = %v, want %v", tt.name, obj.Size, tt.file.FileSize)
+		}
+	}
+}
+
+func TestSaveChecksumExistingKey(t *testing.T) {
+	ctx := context.Background()
+	for name, s := range map[string]Storage{"local": NewLocal(t.TempDir()), "memory": NewMemory()} {
+		if _, err := Save(ctx, s, fileStream("a.txt", "text/plain", "hello"), SaveOptions{Key: "a.txt"}); err != nil {
+			t.Fatalf("%v: Save() error = %v", name, err)
+		}
+		_, err := Save(ctx, s, fileStream("a.txt", "text/plain", "tampered"), SaveOptions{Key: "a.txt", SHA256: helloSHA256})
+		if !errors.Is(err, ErrChecksumMismatch) {
+			t.Errorf("%v: Save() error = %v, want ErrChecksumMismatch", name, err)
+		}
+		rc, _, err := s.Get(ctx, "a.txt")
+		if err != nil {
+			t.Fatalf("%v: Get() error = %v", name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if string(data) != "hello" {
+			t.Errorf("%v: Get() after a bad checksum = %q, want %q", name, data, "hello")
+		}
+	}
+}
+
+func TestSaveKey(t *testing.T) {
+	s := NewMemory()
+	obj, err := Save(context.Background(), s, fileStream(`..\..\Evil Name.TXT`, "text/plain", "hello"), SaveOptions{Prefix: "uploads"})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if !strings.HasPrefix(obj.Key, "uploads/") || !strings.HasSuffix(obj.Key, ".txt") || len(obj.Key) != len("uploads/")+32+len(".txt") {
+		t.Errorf("Key = %q", obj.Key)
+	}
+
+	obj, err = Save(context.Background(), s, fileStream("a.txt", "text/plain", "hello"), SaveOptions{Key: "fixed/name.txt"})
+	if err != nil || obj.Key != "fixed/name.txt" {
+		t.Errorf("Save() = %+v, %v", obj, err)
+	}
+
+	if key := UniqueKey("", "archive.tar.gz"); !strings.HasSuffix(key, ".gz") || strings.Contains(key, "archive") {
+		t.Errorf("UniqueKey() = %q", key)
+	}
+	if key := UniqueKey("", "no-extension"); len(key) != 32 {
+		t.Errorf("UniqueKey() = %q", key)
+	}
+}
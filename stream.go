@@ -0,0 +1,40 @@
+package cenery
+
+import (
+	"io"
+	"os"
+)
+
+// ReaderSize returns the number of bytes left to read from r, or -1 when it
+// cannot be known without reading. It handles readers with a Len method
+// such as *bytes.Reader, regular *os.File values and other io.Seekers.
+func ReaderSize(r io.Reader) int64 {
+	switch v := r.(type) {
+	case interface{ Len() int }:
+		return int64(v.Len())
+	case *os.File:
+		info, err := v.Stat()
+		if err != nil || !info.Mode().IsRegular() {
+			return -1
+		}
+		return seekerSize(v)
+	case io.Seeker:
+		return seekerSize(v)
+	}
+	return -1
+}
+
+func seekerSize(s io.Seeker) int64 {
+	cur, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1
+	}
+	if _, err := s.Seek(cur, io.SeekStart); err != nil {
+		return -1
+	}
+	return max(end-cur, 0)
+}
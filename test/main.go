@@ -136,13 +136,14 @@ func main() {
 
 	app.Post("/upload", func(c cenery.Ctx) error {
 		fmt.Println("handler uploading..")
-		request := &UploadRequest{}
-		err := c.BodyParser(request)
+		request := &UploadRequest{Name: c.FormValue("name")}
+
+		file, err := c.FormFileStream("file")
 		if err != nil {
-			return c.SendJSON(400, &ErrorResponse{Message: err.Error()})
+			return err
 		}
-
-		request.File, _ = c.FormFileStream("file")
+		defer file.Close()
+		request.File = file
 
 		fmt.Println("handler upload success")
 
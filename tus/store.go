@@ -0,0 +1,283 @@
+package tus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upload describes an upload resource.
+type Upload struct {
+	ID string `json:"id"`
+	// Size is the Upload-Length given at creation.
+	Size int64 `json:"size"`
+	// Offset is the number of bytes received so far.
+	Offset int64 `json:"offset"`
+	// Metadata holds the decoded Upload-Metadata pairs.
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// ExpiresAt is when an unfinished upload expires. It is zero when
+	// uploads never expire.
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+}
+
+// Done reports whether every byte of the upload was received.
+func (u Upload) Done() bool {
+	return u.Offset == u.Size
+}
+
+// Store keeps uploads and their data. The Handler serializes the writes to
+// an upload, so a Store only needs to guard its own shared state.
+type Store interface {
+	// Create stores a new, empty upload. u.Offset is zero.
+	Create(ctx context.Context, u Upload) error
+	// Get returns the upload with the given ID, or ErrNotFound.
+	Get(ctx context.Context, id string) (Upload, error)
+	// Write appends r to the upload data, which is offset bytes long, and
+	// returns the number of bytes appended. The bytes appended before r
+	// fails must be kept, so that the client can resume after them.
+	Write(ctx context.Context, id string, offset int64, r io.Reader) (int64, error)
+	// Open returns the data received so far.
+	Open(ctx context.Context, id string) (io.ReadCloser, error)
+	// Delete removes an upload and its data. Deleting a missing upload is
+	// not an error.
+	Delete(ctx context.Context, id string) error
+	// List returns every upload, for PurgeExpired.
+	List(ctx context.Context) ([]Upload, error)
+}
+
+// MemoryStore keeps uploads in memory. It suits tests and single-instance
+// deployments with small files.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	uploads map[string]*memoryUpload
+}
+
+type memoryUpload struct {
+	Upload
+	data []byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{uploads: make(map[string]*memoryUpload)}
+}
+
+func (m *MemoryStore) Create(_ context.Context, u Upload) error {
+	m.mu.Lock()
+	m.uploads[u.ID] = &memoryUpload{Upload: u}
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryStore) Get(_ context.Context, id string) (Upload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.uploads[id]
+	if !ok {
+		return Upload{}, ErrNotFound
+	}
+	return u.Upload, nil
+}
+
+func (m *MemoryStore) Write(_ context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	m.mu.RLock()
+	u, ok := m.uploads[id]
+	m.mu.RUnlock()
+	if !ok {
+		return 0, ErrNotFound
+	}
+	if u.Offset != offset {
+		return 0, ErrOffsetMismatch
+	}
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, r)
+	m.mu.Lock()
+	u.data = append(u.data, buf.Bytes()...)
+	u.Offset += n
+	m.mu.Unlock()
+	return n, err
+}
+
+func (m *MemoryStore) Open(_ context.Context, id string) (io.ReadCloser, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	u, ok := m.uploads[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(u.data)), nil
+}
+
+func (m *MemoryStore) Delete(_ context.Context, id string) error {
+	m.mu.Lock()
+	delete(m.uploads, id)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *MemoryStore) List(_ context.Context) ([]Upload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	uploads := make([]Upload, 0, len(m.uploads))
+	for _, u := range m.uploads {
+		uploads = append(uploads, u.Upload)
+	}
+	return uploads, nil
+}
+
+// FileStore keeps uploads in a directory: the data of upload id in a file
+// named id and its description in id.info. The offset is the size of the
+// data file, so it stays correct when the process stops mid-write.
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore returns a FileStore keeping uploads in dir, which is created
+// on the first upload.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{dir: dir}
+}
+
+const infoExt = ".info"
+
+func (f *FileStore) path(id string) (string, error) {
+	if id == "" || strings.ContainsAny(id, `/\.`) {
+		return "", ErrNotFound
+	}
+	return filepath.Join(f.dir, id), nil
+}
+
+func (f *FileStore) Create(_ context.Context, u Upload) error {
+	p, err := f.path(u.ID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+	info, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	data, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p+infoExt, info, 0o644); err != nil {
+		os.Remove(p)
+		return err
+	}
+	return nil
+}
+
+func (f *FileStore) Get(_ context.Context, id string) (Upload, error) {
+	p, err := f.path(id)
+	if err != nil {
+		return Upload{}, err
+	}
+	info, err := os.ReadFile(p + infoExt)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Upload{}, ErrNotFound
+		}
+		return Upload{}, err
+	}
+	var u Upload
+	if err := json.Unmarshal(info, &u); err != nil {
+		return Upload{}, err
+	}
+	stat, err := os.Stat(p)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Upload{}, ErrNotFound
+		}
+		return Upload{}, err
+	}
+	u.Offset = stat.Size()
+	return u, nil
+}
+
+func (f *FileStore) Write(_ context.Context, id string, offset int64, r io.Reader) (int64, error) {
+	p, err := f.path(id)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	defer data.Close()
+	stat, err := data.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if stat.Size() != offset {
+		return 0, ErrOffsetMismatch
+	}
+	return io.Copy(data, r)
+}
+
+func (f *FileStore) Open(_ context.Context, id string) (io.ReadCloser, error) {
+	p, err := f.path(id)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (f *FileStore) Delete(_ context.Context, id string) error {
+	p, err := f.path(id)
+	if err != nil {
+		return nil
+	}
+	for _, name := range []string{p + infoExt, p} {
+		if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *FileStore) List(ctx context.Context) ([]Upload, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var uploads []Upload
+	for _, e := range entries {
+		id, ok := strings.CutSuffix(e.Name(), infoExt)
+		if !ok {
+			continue
+		}
+		u, err := f.Get(ctx, id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		uploads = append(uploads, u)
+	}
+	return uploads, nil
+}
@@ -0,0 +1,516 @@
+// Package tus serves resumable uploads with the tus 1.0 protocol
+// (https://tus.io/protocols/resumable-upload) and its creation,
+// termination, checksum and expiration extensions.
+//
+// A client creates an upload with POST, then sends its bytes in one or more
+// PATCH requests. When a PATCH is interrupted, the client asks for the
+// offset with HEAD and resumes from there. Chunks are read from
+// Request().BodyStream, so they stream into the Store on every engine, up
+// to the body limit of the route.
+package tus
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+// Version is the protocol version the Handler speaks.
+const Version = "1.0.0"
+
+// Extensions lists the supported protocol extensions, as sent in
+// Tus-Extension.
+const Extensions = "creation,termination,checksum,expiration"
+
+// ChecksumAlgorithms lists the algorithms accepted in Upload-Checksum, as
+// sent in Tus-Checksum-Algorithm.
+const ChecksumAlgorithms = "md5,sha1,sha256"
+
+// StatusChecksumMismatch is the status of a chunk whose Upload-Checksum
+// does not match.
+const StatusChecksumMismatch = 460
+
+var (
+	// ErrNotFound is returned for unknown uploads.
+	ErrNotFound = cenery.NewError(http.StatusNotFound)
+	// ErrGone is returned for uploads that expired before they finished.
+	ErrGone = cenery.NewError(http.StatusGone, "upload expired")
+	// ErrOffsetMismatch is returned when Upload-Offset is not the offset of
+	// the upload.
+	ErrOffsetMismatch = cenery.NewError(http.StatusConflict, "upload offset mismatch")
+	// ErrLocked is returned while another request writes to the upload.
+	ErrLocked = cenery.NewError(http.StatusLocked, "upload locked")
+	// ErrChecksumMismatch is returned when a chunk does not match its
+	// Upload-Checksum. The chunk is discarded.
+	ErrChecksumMismatch = cenery.NewError(StatusChecksumMismatch, "checksum mismatch")
+	// ErrTooLarge is returned for uploads longer than MaxSize and chunks
+	// that go past the end of the upload.
+	ErrTooLarge = cenery.NewError(http.StatusRequestEntityTooLarge)
+	// ErrUnsupportedVersion is returned for requests without
+	// "Tus-Resumable: 1.0.0".
+	ErrUnsupportedVersion = cenery.NewError(http.StatusPreconditionFailed, "unsupported tus version")
+)
+
+// Config configures a Handler.
+type Config struct {
+	// Store keeps the uploads. Defaults to a new MemoryStore.
+	Store Store
+	// MaxSize is the largest Upload-Length accepted, sent as Tus-Max-Size.
+	// Zero means no limit.
+	MaxSize int64
+	// Expiration is how long an upload may take from its creation. Zero
+	// means uploads never expire. Expired uploads answer 410 Gone until
+	// PurgeExpired removes them.
+	Expiration time.Duration
+	// OnComplete is called after the last byte of an upload is stored,
+	// before the PATCH is answered. Its error is returned by the PATCH.
+	OnComplete func(c cenery.Ctx, u Upload) error
+	// NewID returns the ID of a new upload. Defaults to 16 random bytes in
+	// hex.
+	NewID func() string
+	// Clock returns the current time. Defaults to time.Now.
+	Clock func() time.Time
+}
+
+// Handler serves the tus protocol. Its methods are cenery handlers; the
+// ones for an upload read its ID from the "id" route parameter. Mount
+// registers them all.
+type Handler struct {
+	cfg Config
+
+	mu     sync.Mutex
+	locked map[string]struct{}
+}
+
+// New returns a Handler for config.
+func New(config ...Config) *Handler {
+	var cfg Config
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.Store == nil {
+		cfg.Store = NewMemoryStore()
+	}
+	if cfg.NewID == nil {
+		cfg.NewID = newID
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = time.Now
+	}
+	return &Handler{cfg: cfg, locked: make(map[string]struct{})}
+}
+
+// Router is the part of cenery.App Mount registers routes on.
+type Router interface {
+	Post(path string, handlers ...cenery.Handler) *cenery.Route
+	Head(path string, handlers ...cenery.Handler) *cenery.Route
+	Patch(path string, handlers ...cenery.Handler) *cenery.Route
+	Delete(path string, handlers ...cenery.Handler) *cenery.Route
+	Options(path string, handlers ...cenery.Handler) *cenery.Route
+}
+
+// Mount registers the upload endpoint at prefix and the uploads below it,
+// at prefix+"/:id". handlers, such as authentication or
+// cenery.RouteLimits raising the body limit for large chunks, run before
+// every route.
+func (h *Handler) Mount(r Router, prefix string, handlers ...cenery.Handler) {
+	base := strings.TrimRight(prefix, "/")
+	upload := base + "/:id"
+	if base == "" {
+		base = "/"
+	}
+	with := func(handler cenery.Handler) []cenery.Handler {
+		return append(slices.Clone(handlers), handler)
+	}
+	r.Options(base, with(h.Options)...)
+	r.Post(base, with(h.Create)...)
+	r.Options(upload, with(h.Options)...)
+	r.Head(upload, with(h.Head)...)
+	r.Patch(upload, with(h.Patch)...)
+	r.Delete(upload, with(h.Delete)...)
+	r.Post(upload, with(h.Override)...)
+}
+
+// Options describes the server: its version, extensions and limits.
+func (h *Handler) Options(c cenery.Ctx) error {
+	resp := c.Response()
+	resp.SetHeader("Tus-Resumable", Version)
+	resp.SetHeader("Tus-Version", Version)
+	resp.SetHeader("Tus-Extension", Extensions)
+	resp.SetHeader("Tus-Checksum-Algorithm", ChecksumAlgorithms)
+	if h.cfg.MaxSize > 0 {
+		resp.SetHeader("Tus-Max-Size", strconv.FormatInt(h.cfg.MaxSize, 10))
+	}
+	return c.Send(http.StatusNoContent, nil)
+}
+
+// Create creates an upload of Upload-Length bytes and answers 201 with its
+// path in Location. Deferred lengths are not supported.
+func (h *Handler) Create(c cenery.Ctx) error {
+	if err := h.begin(c); err != nil {
+		return err
+	}
+	req := c.Request()
+	size, err := strconv.ParseInt(req.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || size < 0 {
+		return cenery.NewError(http.StatusBadRequest, "invalid Upload-Length")
+	}
+	if h.cfg.MaxSize > 0 && size > h.cfg.MaxSize {
+		return ErrTooLarge
+	}
+	metadata, err := parseMetadata(req.GetHeader("Upload-Metadata"))
+	if err != nil {
+		return err
+	}
+
+	u := Upload{ID: h.cfg.NewID(), Size: size, Metadata: metadata}
+	if h.cfg.Expiration > 0 {
+		u.ExpiresAt = h.cfg.Clock().Add(h.cfg.Expiration).UTC().Truncate(time.Second)
+	}
+	if err := h.cfg.Store.Create(c.Context(), u); err != nil {
+		return err
+	}
+
+	resp := c.Response()
+	resp.SetHeader("Location", strings.TrimRight(req.Path(), "/")+"/"+u.ID)
+	setExpires(resp, u)
+	return c.Send(http.StatusCreated, nil)
+}
+
+// Head reports the offset of an upload.
+func (h *Handler) Head(c cenery.Ctx) error {
+	if err := h.begin(c); err != nil {
+		return err
+	}
+	u, err := h.get(c.Context(), c.Params("id"))
+	if err != nil {
+		return err
+	}
+	resp := c.Response()
+	resp.SetHeader("Cache-Control", "no-store")
+	resp.SetHeader("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	resp.SetHeader("Upload-Length", strconv.FormatInt(u.Size, 10))
+	if len(u.Metadata) > 0 {
+		resp.SetHeader("Upload-Metadata", formatMetadata(u.Metadata))
+	}
+	setExpires(resp, u)
+	return c.Send(http.StatusOK, nil)
+}
+
+// Patch appends a chunk at Upload-Offset and answers 204 with the new
+// offset. The chunk streams into the Store, which keeps what arrived when
+// the request breaks off. A chunk that runs past the end of the upload
+// fails with ErrTooLarge before it completes the upload. A chunk with an
+// Upload-Checksum is spooled to a temporary file first and only stored
+// when it matches.
+func (h *Handler) Patch(c cenery.Ctx) error {
+	if err := h.begin(c); err != nil {
+		return err
+	}
+	req := c.Request()
+	if req.GetHeader("Content-Type") != "application/offset+octet-stream" {
+		return cenery.NewError(http.StatusUnsupportedMediaType)
+	}
+	offset, err := strconv.ParseInt(req.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		return cenery.NewError(http.StatusBadRequest, "invalid Upload-Offset")
+	}
+	newHash, sum, err := parseChecksum(req.GetHeader("Upload-Checksum"))
+	if err != nil {
+		return err
+	}
+
+	id := c.Params("id")
+	if !h.lock(id) {
+		return ErrLocked
+	}
+	defer h.unlock(id)
+
+	ctx := c.Context()
+	u, err := h.get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if offset != u.Offset {
+		return ErrOffsetMismatch
+	}
+	remaining := u.Size - u.Offset
+	if n, err := strconv.ParseInt(req.GetHeader("Content-Length"), 10, 64); err == nil && n > remaining {
+		return ErrTooLarge
+	}
+
+	body := req.BodyStream()
+	defer body.Close()
+	chunk := &chunkReader{r: body, remaining: remaining}
+	var n int64
+	if newHash == nil {
+		n, err = h.cfg.Store.Write(ctx, id, offset, chunk)
+	} else {
+		n, err = h.writeVerified(ctx, id, offset, chunk, newHash(), sum)
+	}
+	u.Offset += n
+	c.Response().SetHeader("Upload-Offset", strconv.FormatInt(u.Offset, 10))
+	if err != nil {
+		return err
+	}
+	if u.Done() && h.cfg.OnComplete != nil {
+		if err := h.cfg.OnComplete(c, u); err != nil {
+			return err
+		}
+	}
+	setExpires(c.Response(), u)
+	return c.Send(http.StatusNoContent, nil)
+}
+
+// chunkReader reads a chunk of at most remaining bytes from r and fails
+// with ErrTooLarge when r holds more. The bytes that would complete the
+// upload are only returned once r is known to end with them.
+type chunkReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	// Read one byte past the end to tell an exact fit from an overflow.
+	if int64(len(p)) > c.remaining+1 {
+		p = p[:c.remaining+1]
+	}
+	n, err := c.r.Read(p)
+	if int64(n) > c.remaining {
+		c.err = ErrTooLarge
+		return 0, c.err
+	}
+	c.remaining -= int64(n)
+	if c.remaining == 0 && err == nil {
+		var b [1]byte
+		switch _, err := io.ReadFull(c.r, b[:]); err {
+		case nil:
+			c.err = ErrTooLarge
+			return 0, c.err
+		case io.EOF:
+		default:
+			c.err = err
+			return 0, c.err
+		}
+		err = io.EOF
+	}
+	return n, err
+}
+
+// writeVerified stores chunk only when its digest is sum.
+func (h *Handler) writeVerified(ctx context.Context, id string, offset int64, chunk io.Reader, digest hash.Hash, sum []byte) (int64, error) {
+	tmp, err := os.CreateTemp("", "cenery-tus-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+	if _, err := io.Copy(io.MultiWriter(tmp, digest), chunk); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(digest.Sum(nil), sum) {
+		return 0, ErrChecksumMismatch
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return h.cfg.Store.Write(ctx, id, offset, tmp)
+}
+
+// Delete terminates an upload, removing it and its data.
+func (h *Handler) Delete(c cenery.Ctx) error {
+	if err := h.begin(c); err != nil {
+		return err
+	}
+	id := c.Params("id")
+	if !h.lock(id) {
+		return ErrLocked
+	}
+	defer h.unlock(id)
+
+	ctx := c.Context()
+	if _, err := h.cfg.Store.Get(ctx, id); err != nil {
+		return err
+	}
+	if err := h.cfg.Store.Delete(ctx, id); err != nil {
+		return err
+	}
+	return c.Send(http.StatusNoContent, nil)
+}
+
+// Override serves a POST to an upload as the PATCH or DELETE named by its
+// X-HTTP-Method-Override header, for clients that cannot send those
+// methods.
+func (h *Handler) Override(c cenery.Ctx) error {
+	switch strings.ToUpper(c.Request().GetHeader("X-HTTP-Method-Override")) {
+	case http.MethodPatch:
+		return h.Patch(c)
+	case http.MethodDelete:
+		return h.Delete(c)
+	}
+	c.Response().SetHeader("Tus-Resumable", Version)
+	return cenery.NewError(http.StatusMethodNotAllowed)
+}
+
+// PurgeExpired deletes the unfinished uploads that expired and returns how
+// many it deleted. Run it periodically when Expiration is set.
+func (h *Handler) PurgeExpired(ctx context.Context) (int, error) {
+	uploads, err := h.cfg.Store.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := h.cfg.Clock()
+	purged := 0
+	for _, u := range uploads {
+		if !h.expired(u, now) || !h.lock(u.ID) {
+			continue
+		}
+		err := h.cfg.Store.Delete(ctx, u.ID)
+		h.unlock(u.ID)
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// begin sets Tus-Resumable on the response and rejects requests for other
+// protocol versions.
+func (h *Handler) begin(c cenery.Ctx) error {
+	resp := c.Response()
+	resp.SetHeader("Tus-Resumable", Version)
+	if c.Request().GetHeader("Tus-Resumable") != Version {
+		resp.SetHeader("Tus-Version", Version)
+		return ErrUnsupportedVersion
+	}
+	return nil
+}
+
+func (h *Handler) get(ctx context.Context, id string) (Upload, error) {
+	u, err := h.cfg.Store.Get(ctx, id)
+	if err != nil {
+		return Upload{}, err
+	}
+	if h.expired(u, h.cfg.Clock()) {
+		return Upload{}, ErrGone
+	}
+	return u, nil
+}
+
+func (h *Handler) expired(u Upload, now time.Time) bool {
+	return !u.ExpiresAt.IsZero() && !u.Done() && !now.Before(u.ExpiresAt)
+}
+
+func (h *Handler) lock(id string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.locked[id]; ok {
+		return false
+	}
+	h.locked[id] = struct{}{}
+	return true
+}
+
+func (h *Handler) unlock(id string) {
+	h.mu.Lock()
+	delete(h.locked, id)
+	h.mu.Unlock()
+}
+
+func setExpires(resp cenery.Response, u Upload) {
+	if !u.ExpiresAt.IsZero() && !u.Done() {
+		resp.SetHeader("Upload-Expires", u.ExpiresAt.UTC().Format(http.TimeFormat))
+	}
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// parseMetadata decodes an Upload-Metadata header: comma-separated pairs
+// of a key and an optional base64 value.
+func parseMetadata(header string) (map[string]string, error) {
+	if strings.TrimSpace(header) == "" {
+		return nil, nil
+	}
+	metadata := make(map[string]string)
+	for pair := range strings.SplitSeq(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" || strings.ContainsAny(value, " ") {
+			return nil, cenery.NewError(http.StatusBadRequest, "invalid Upload-Metadata")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, cenery.NewError(http.StatusBadRequest, "invalid Upload-Metadata")
+		}
+		metadata[key] = string(decoded)
+	}
+	return metadata, nil
+}
+
+func formatMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k
+		if v := metadata[k]; v != "" {
+			pairs[i] += " " + base64.StdEncoding.EncodeToString([]byte(v))
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// parseChecksum decodes an Upload-Checksum header, "<algorithm> <base64
+// digest>". It returns a nil hash constructor when header is empty.
+func parseChecksum(header string) (func() hash.Hash, []byte, error) {
+	if header == "" {
+		return nil, nil, nil
+	}
+	algorithm, encoded, _ := strings.Cut(header, " ")
+	var newHash func() hash.Hash
+	switch algorithm {
+	case "md5":
+		newHash = md5.New
+	case "sha1":
+		newHash = sha1.New
+	case "sha256":
+		newHash = sha256.New
+	default:
+		return nil, nil, cenery.NewError(http.StatusBadRequest, "unsupported checksum algorithm")
+	}
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sum) != newHash().Size() {
+		return nil, nil, cenery.NewError(http.StatusBadRequest, "invalid Upload-Checksum")
+	}
+	return newHash, sum, nil
+}
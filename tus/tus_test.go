@@ -0,0 +1,421 @@
+package tus
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/dreamph/cenery"
+)
+
+type testRequest struct {
+	cenery.Request
+	method  string
+	path    string
+	headers http.Header
+	body    io.Reader
+}
+
+func (r *testRequest) Method() string              { return r.method }
+func (r *testRequest) Path() string                { return r.path }
+func (r *testRequest) GetHeader(key string) string { return r.headers.Get(key) }
+func (r *testRequest) BodyStream() io.ReadCloser   { return io.NopCloser(r.body) }
+
+type testResponse struct {
+	cenery.Response
+	headers http.Header
+}
+
+func (r *testResponse) SetHeader(key string, val string) { r.headers.Set(key, val) }
+func (r *testResponse) GetHeader(key string) string      { return r.headers.Get(key) }
+
+type testCtx struct {
+	cenery.Ctx
+	req    *testRequest
+	resp   *testResponse
+	params map[string]string
+	status int
+}
+
+func newTestCtx(method, path, body string) *testCtx {
+	c := &testCtx{
+		req:    &testRequest{method: method, path: path, headers: http.Header{}, body: strings.NewReader(body)},
+		resp:   &testResponse{headers: http.Header{}},
+		params: map[string]string{},
+	}
+	c.req.headers.Set("Tus-Resumable", Version)
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		c.params["id"] = path[i+1:]
+	}
+	return c
+}
+
+func (c *testCtx) Context() context.Context  { return context.Background() }
+func (c *testCtx) Request() cenery.Request   { return c.req }
+func (c *testCtx) Response() cenery.Response { return c.resp }
+
+func (c *testCtx) Params(key string, _ ...string) string { return c.params[key] }
+
+func (c *testCtx) Send(status int, _ []byte) error {
+	c.status = status
+	return nil
+}
+
+// status returns the status handler answers c with, or the code of its
+// error.
+func status(t *testing.T, handler cenery.Handler, c *testCtx) int {
+	t.Helper()
+	err := handler(c)
+	var e *cenery.Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	if err != nil {
+		return -1
+	}
+	return c.status
+}
+
+func create(t *testing.T, h *Handler, size int, metadata string) string {
+	t.Helper()
+	c := newTestCtx(http.MethodPost, "/files/", "")
+	c.req.headers.Set("Upload-Length", strconv.Itoa(size))
+	c.req.headers.Set("Upload-Metadata", metadata)
+	if got := status(t, h.Create, c); got != http.StatusCreated {
+		t.Fatalf("POST status = %v, want 201", got)
+	}
+	location := c.resp.headers.Get("Location")
+	if !strings.HasPrefix(location, "/files/") || c.resp.headers.Get("Tus-Resumable") != Version {
+		t.Fatalf("POST Location = %q, Tus-Resumable = %q", location, c.resp.headers.Get("Tus-Resumable"))
+	}
+	return location
+}
+
+func patch(location string, offset int, chunk string) *testCtx {
+	c := newTestCtx(http.MethodPatch, location, chunk)
+	c.req.headers.Set("Content-Type", "application/offset+octet-stream")
+	c.req.headers.Set("Upload-Offset", strconv.Itoa(offset))
+	return c
+}
+
+func head(t *testing.T, h *Handler, location string) *testCtx {
+	t.Helper()
+	c := newTestCtx(http.MethodHead, location, "")
+	if got := status(t, h.Head, c); got != http.StatusOK {
+		t.Fatalf("HEAD status = %v, want 200", got)
+	}
+	if c.resp.headers.Get("Cache-Control") != "no-store" {
+		t.Errorf("HEAD Cache-Control = %q", c.resp.headers.Get("Cache-Control"))
+	}
+	return c
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) {
+	return copy(p, "part"), errors.New("connection reset")
+}
+
+// testUpload runs an upload through every request of the protocol.
+func testUpload(t *testing.T, store Store) {
+	t.Helper()
+	var completed Upload
+	h := New(Config{Store: store, OnComplete: func(_ cenery.Ctx, u Upload) error {
+		completed = u
+		return nil
+	}})
+
+	location := create(t, h, 11, "filename aGVsbG8udHh0,private")
+	c := head(t, h, location)
+	if c.resp.headers.Get("Upload-Offset") != "0" || c.resp.headers.Get("Upload-Length") != "11" {
+		t.Errorf("HEAD Upload-Offset = %q, Upload-Length = %q", c.resp.headers.Get("Upload-Offset"), c.resp.headers.Get("Upload-Length"))
+	}
+	if got := c.resp.headers.Get("Upload-Metadata"); got != "filename aGVsbG8udHh0,private" {
+		t.Errorf("HEAD Upload-Metadata = %q", got)
+	}
+
+	c = patch(location, 0, "")
+	c.req.body = failingReader{}
+	if err := h.Patch(c); err == nil {
+		t.Error("interrupted PATCH succeeded")
+	}
+	if got := head(t, h, location).resp.headers.Get("Upload-Offset"); got != "4" {
+		t.Errorf("Upload-Offset after an interrupted PATCH = %q, want 4", got)
+	}
+
+	if got := status(t, h.Patch, patch(location, 0, "part")); got != http.StatusConflict {
+		t.Errorf("PATCH at a stale offset status = %v, want 409", got)
+	}
+	c = patch(location, 4, " one")
+	if got := status(t, h.Patch, c); got != http.StatusNoContent || c.resp.headers.Get("Upload-Offset") != "8" {
+		t.Errorf("PATCH status = %v, Upload-Offset = %q", got, c.resp.headers.Get("Upload-Offset"))
+	}
+	if completed.ID != "" {
+		t.Error("OnComplete called before the last chunk")
+	}
+	c = patch(location, 8, "two!")
+	c.req.headers.Set("Content-Length", "4")
+	if got := status(t, h.Patch, c); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("PATCH past the end status = %v, want 413", got)
+	}
+	if got := status(t, h.Patch, patch(location, 8, "two")); got != http.StatusNoContent || completed.Offset != 11 {
+		t.Errorf("last PATCH status = %v, completed = %+v", got, completed)
+	}
+
+	rc, err := store.Open(context.Background(), completed.ID)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "part onetwo" || completed.Metadata["filename"] != "hello.txt" {
+		t.Errorf("upload = %q, metadata = %v", data, completed.Metadata)
+	}
+
+	if got := status(t, h.Delete, newTestCtx(http.MethodDelete, location, "")); got != http.StatusNoContent {
+		t.Errorf("DELETE status = %v, want 204", got)
+	}
+	if got := status(t, h.Head, newTestCtx(http.MethodHead, location, "")); got != http.StatusNotFound {
+		t.Errorf("HEAD after DELETE status = %v, want 404", got)
+	}
+	if got := status(t, h.Delete, newTestCtx(http.MethodDelete, location, "")); got != http.StatusNotFound {
+		t.Errorf("second DELETE status = %v, want 404", got)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testUpload(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	testUpload(t, NewFileStore(t.TempDir()))
+
+	h := New(Config{Store: NewFileStore(t.TempDir())})
+	if got := status(t, h.Head, newTestCtx(http.MethodHead, "/files/..", "")); got != http.StatusNotFound {
+		t.Errorf("HEAD of an invalid ID status = %v, want 404", got)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	h := New(Config{MaxSize: 1 << 20})
+	c := newTestCtx(http.MethodOptions, "/files", "")
+	c.req.headers.Del("Tus-Resumable")
+	if got := status(t, h.Options, c); got != http.StatusNoContent {
+		t.Errorf("OPTIONS status = %v, want 204", got)
+	}
+	want := map[string]string{
+		"Tus-Resumable":          Version,
+		"Tus-Version":            Version,
+		"Tus-Extension":          Extensions,
+		"Tus-Checksum-Algorithm": ChecksumAlgorithms,
+		"Tus-Max-Size":           "1048576",
+	}
+	for k, v := range want {
+		if got := c.resp.headers.Get(k); got != v {
+			t.Errorf("%v = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	h := New(Config{MaxSize: 10})
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		expected int
+	}{
+		{"no version", map[string]string{"Tus-Resumable": "", "Upload-Length": "5"}, http.StatusPreconditionFailed},
+		{"no length", map[string]string{}, http.StatusBadRequest},
+		{"negative length", map[string]string{"Upload-Length": "-1"}, http.StatusBadRequest},
+		{"too large", map[string]string{"Upload-Length": "11"}, http.StatusRequestEntityTooLarge},
+		{"bad metadata", map[string]string{"Upload-Length": "5", "Upload-Metadata": "name !!"}, http.StatusBadRequest},
+		{"empty", map[string]string{"Upload-Length": "0"}, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		c := newTestCtx(http.MethodPost, "/files", "")
+		for k, v := range tt.headers {
+			c.req.headers.Set(k, v)
+		}
+		if got := status(t, h.Create, c); got != tt.expected {
+			t.Errorf("%v: status = %v, want %v", tt.name, got, tt.expected)
+		}
+	}
+
+	c := newTestCtx(http.MethodPost, "/files", "")
+	c.req.headers.Del("Tus-Resumable")
+	_ = h.Create(c)
+	if c.resp.headers.Get("Tus-Version") != Version {
+		t.Errorf("Tus-Version = %q", c.resp.headers.Get("Tus-Version"))
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	h := New()
+	location := create(t, h, 10, "")
+	sum := sha1.Sum([]byte("hello"))
+	digest := base64.StdEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name     string
+		checksum string
+		expected int
+		offset   string
+	}{
+		{"unknown algorithm", "crc32 AAAAAA==", http.StatusBadRequest, "0"},
+		{"bad digest", "sha1 !!", http.StatusBadRequest, "0"},
+		{"mismatch", "sha1 " + base64.StdEncoding.EncodeToString(make([]byte, sha1.Size)), StatusChecksumMismatch, "0"},
+		{"match", "sha1 " + digest, http.StatusNoContent, "5"},
+	}
+	for _, tt := range tests {
+		c := patch(location, 0, "hello")
+		c.req.headers.Set("Upload-Checksum", tt.checksum)
+		if got := status(t, h.Patch, c); got != tt.expected {
+			t.Errorf("%v: status = %v, want %v", tt.name, got, tt.expected)
+		}
+		if got := head(t, h, location).resp.headers.Get("Upload-Offset"); got != tt.offset {
+			t.Errorf("%v: Upload-Offset = %q, want %q", tt.name, got, tt.offset)
+		}
+	}
+}
+
+func TestPatch(t *testing.T) {
+	h := New()
+	location := create(t, h, 10, "")
+
+	c := patch(location, 0, "hello")
+	c.req.headers.Set("Content-Type", "application/octet-stream")
+	if got := status(t, h.Patch, c); got != http.StatusUnsupportedMediaType {
+		t.Errorf("wrong Content-Type status = %v, want 415", got)
+	}
+	c = patch(location, 0, "hello")
+	c.req.headers.Del("Upload-Offset")
+	if got := status(t, h.Patch, c); got != http.StatusBadRequest {
+		t.Errorf("no Upload-Offset status = %v, want 400", got)
+	}
+	c = patch(location, 0, "hello")
+	c.req.headers.Set("Content-Length", "11")
+	if got := status(t, h.Patch, c); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("long Content-Length status = %v, want 413", got)
+	}
+	long := create(t, h, 10, "")
+	c = patch(long, 0, "")
+	c.req.body = iotest.OneByteReader(strings.NewReader("hello world"))
+	if got := status(t, h.Patch, c); got != http.StatusRequestEntityTooLarge {
+		t.Errorf("long chunk status = %v, want 413", got)
+	}
+	if got := head(t, h, long).resp.headers.Get("Upload-Offset"); got != "9" {
+		t.Errorf("Upload-Offset after a long chunk = %q, want %q", got, "9")
+	}
+	if got := status(t, h.Patch, patch(long, 9, "d")); got != http.StatusNoContent {
+		t.Errorf("last byte status = %v, want 204", got)
+	}
+	if got := status(t, h.Patch, patch("/files/missing", 0, "hello")); got != http.StatusNotFound {
+		t.Errorf("unknown upload status = %v, want 404", got)
+	}
+
+	id := location[len("/files/"):]
+	h.lock(id)
+	if got := status(t, h.Patch, patch(location, 0, "hello")); got != http.StatusLocked {
+		t.Errorf("locked upload status = %v, want 423", got)
+	}
+	h.unlock(id)
+
+	c = patch(location, 0, "hello")
+	c.req.method = http.MethodPost
+	c.req.headers.Set("X-HTTP-Method-Override", "PATCH")
+	if got := status(t, h.Override, c); got != http.StatusNoContent || c.resp.headers.Get("Upload-Offset") != "5" {
+		t.Errorf("overridden PATCH status = %v, Upload-Offset = %q", got, c.resp.headers.Get("Upload-Offset"))
+	}
+	c = newTestCtx(http.MethodPost, location, "")
+	if got := status(t, h.Override, c); got != http.StatusMethodNotAllowed {
+		t.Errorf("POST without override status = %v, want 405", got)
+	}
+}
+
+func TestExpiration(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	h := New(Config{Expiration: time.Hour, Clock: func() time.Time { return now }})
+	location := create(t, h, 5, "")
+	done := create(t, h, 5, "")
+
+	c := head(t, h, location)
+	if got := c.resp.headers.Get("Upload-Expires"); got != "Mon, 01 Jan 2024 01:00:00 GMT" {
+		t.Errorf("Upload-Expires = %q", got)
+	}
+	if got := status(t, h.Patch, patch(done, 0, "hello")); got != http.StatusNoContent {
+		t.Fatalf("PATCH status = %v", got)
+	}
+
+	now = now.Add(time.Hour)
+	if got := status(t, h.Head, newTestCtx(http.MethodHead, location, "")); got != http.StatusGone {
+		t.Errorf("expired HEAD status = %v, want 410", got)
+	}
+	if got := status(t, h.Patch, patch(location, 0, "hello")); got != http.StatusGone {
+		t.Errorf("expired PATCH status = %v, want 410", got)
+	}
+	if c := head(t, h, done); c.resp.headers.Get("Upload-Expires") != "" {
+		t.Errorf("finished upload Upload-Expires = %q", c.resp.headers.Get("Upload-Expires"))
+	}
+
+	purged, err := h.PurgeExpired(context.Background())
+	if err != nil || purged != 1 {
+		t.Errorf("PurgeExpired() = %v, %v, want 1", purged, err)
+	}
+	if got := status(t, h.Head, newTestCtx(http.MethodHead, location, "")); got != http.StatusNotFound {
+		t.Errorf("purged HEAD status = %v, want 404", got)
+	}
+}
+
+type testRouter struct {
+	routes []string
+}
+
+func (r *testRouter) add(method, path string, handlers []cenery.Handler) *cenery.Route {
+	r.routes = append(r.routes, method+" "+path+" "+strconv.Itoa(len(handlers)))
+	return nil
+}
+
+func (r *testRouter) Post(path string, handlers ...cenery.Handler) *cenery.Route {
+	return r.add(http.MethodPost, path, handlers)
+}
+
+func (r *testRouter) Head(path string, handlers ...cenery.Handler) *cenery.Route {
+	return r.add(http.MethodHead, path, handlers)
+}
+
+func (r *testRouter) Patch(path string, handlers ...cenery.Handler) *cenery.Route {
+	return r.add(http.MethodPatch, path, handlers)
+}
+
+func (r *testRouter) Delete(path string, handlers ...cenery.Handler) *cenery.Route {
+	return r.add(http.MethodDelete, path, handlers)
+}
+
+func (r *testRouter) Options(path string, handlers ...cenery.Handler) *cenery.Route {
+	return r.add(http.MethodOptions, path, handlers)
+}
+
+func TestMount(t *testing.T) {
+	r := &testRouter{}
+	New().Mount(r, "/files/", func(c cenery.Ctx) error { return c.Next() })
+	want := []string{
+		"OPTIONS /files 2",
+		"POST /files 2",
+		"OPTIONS /files/:id 2",
+		"HEAD /files/:id 2",
+		"PATCH /files/:id 2",
+		"DELETE /files/:id 2",
+		"POST /files/:id 2",
+	}
+	if strings.Join(r.routes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("routes = %v, want %v", r.routes, want)
+	}
+}
@@ -0,0 +1,183 @@
+package cenery
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebSocket message types, as defined by RFC 6455.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+)
+
+// WebSocket close codes, as defined by RFC 6455.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseInternalServerErr       = 1011
+)
+
+// ErrWSOrigin is the cause of the 403 response to a WebSocket handshake
+// whose Origin is not allowed.
+var ErrWSOrigin = errors.New("cenery: websocket origin not allowed")
+
+// WSCloseError is returned by WSConn.ReadMessage once the peer has closed
+// the connection. A WSHandler returning one closes the connection with its
+// code and text.
+type WSCloseError struct {
+	Code int
+	Text string
+}
+
+func (e *WSCloseError) Error() string {
+	if e.Text == "" {
+		return "websocket: close " + strconv.Itoa(e.Code)
+	}
+	return "websocket: close " + strconv.Itoa(e.Code) + ": " + e.Text
+}
+
+// IsWSCloseError reports whether err is a *WSCloseError with one of codes,
+// or with any code when none are given.
+func IsWSCloseError(err error, codes ...int) bool {
+	var ce *WSCloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// WSConn is an upgraded WebSocket connection. Only one goroutine may read
+// and one may write messages at a time; Ping and Close may be called
+// concurrently with both.
+type WSConn interface {
+	// ReadMessage returns the next message and its type, TextMessage or
+	// BinaryMessage. Once the peer closes, it returns a *WSCloseError.
+	ReadMessage() (messageType int, data []byte, err error)
+	WriteMessage(messageType int, data []byte) error
+
+	// Ping sends a ping; the peer answers with a pong.
+	Ping(data []byte) error
+	// SetPingHandler replaces the default ping handler, which answers
+	// with a pong. Handlers run while ReadMessage is called.
+	SetPingHandler(h func(data string) error)
+	SetPongHandler(h func(data string) error)
+
+	// Close sends a close frame with code and text and closes the
+	// connection.
+	Close(code int, text string) error
+
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	// SetReadLimit caps the size of a message read from the peer. A larger
+	// message closes the connection with CloseMessageTooBig.
+	SetReadLimit(limit int64)
+	// EnableWriteCompression toggles compression of later messages when
+	// compression was negotiated.
+	EnableWriteCompression(enable bool)
+
+	// Subprotocol returns the negotiated subprotocol, or "".
+	Subprotocol() string
+
+	// Params, QueryParam, Locals and IP report what the upgrade request
+	// carried, so handlers need not hold on to the Ctx.
+	Params(key string, defaultValue ...string) string
+	QueryParam(key string, defaultValue ...string) string
+	Locals(key string) any
+	IP() string
+}
+
+// WSHandler serves an upgraded connection. The connection is closed when
+// it returns: normally for nil, with the code of a *WSCloseError, and with
+// CloseInternalServerErr for other errors, which are not passed to the
+// error handler since no HTTP response can be sent.
+type WSHandler = func(WSConn) error
+
+type WSConfig struct {
+	// Subprotocols lists the supported subprotocols in order of preference.
+	Subprotocols []string
+
+	// Origins lists origins other than the request's own host that may
+	// connect, such as "https://app.example.com", or "*" for any.
+	Origins []string
+	// CheckOrigin replaces the Origins check when set.
+	CheckOrigin func(c Ctx) bool
+
+	// EnableCompression negotiates permessage-deflate with clients that
+	// support it.
+	EnableCompression bool
+
+	// ReadBufferSize and WriteBufferSize size the I/O buffers. Default to
+	// 4096 bytes.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// HandshakeTimeout bounds the upgrade handshake.
+	HandshakeTimeout time.Duration
+
+	// ReadLimit caps the size of a message. Defaults to DefaultBodyLimit; a
+	// negative value means no limit.
+	ReadLimit int64
+}
+
+// NewWSConfig returns the first of config with defaults applied.
+func NewWSConfig(config ...WSConfig) WSConfig {
+	var cfg WSConfig
+	if len(config) > 0 {
+		cfg = config[0]
+	}
+	if cfg.ReadLimit == 0 {
+		cfg.ReadLimit = DefaultBodyLimit
+	}
+	return cfg
+}
+
+// AllowOrigin reports whether the handshake of c may proceed. Requests
+// without an Origin header come from non-browser clients and are allowed.
+func (cfg WSConfig) AllowOrigin(c Ctx) bool {
+	if cfg.CheckOrigin != nil {
+		return cfg.CheckOrigin(c)
+	}
+	origin := c.Request().GetHeader("Origin")
+	if origin == "" {
+		return true
+	}
+	for _, allowed := range cfg.Origins {
+		if allowed == "*" || strings.EqualFold(origin, allowed) {
+			return true
+		}
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, c.Request().Host())
+}
+
+// WSCloseCode returns the close code and text for the error a WSHandler
+// returned.
+func WSCloseCode(err error) (int, string) {
+	if err == nil {
+		return CloseNormalClosure, ""
+	}
+	var ce *WSCloseError
+	if errors.As(err, &ce) {
+		return ce.Code, ce.Text
+	}
+	return CloseInternalServerErr, ""
+}